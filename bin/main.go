@@ -5,16 +5,21 @@
 package main
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/csv"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/Yi-Tseng/p4r-perf/metrics"
 	"github.com/Yi-Tseng/p4r-perf/p4rt"
 	"github.com/golang/protobuf/proto"
 	p4 "github.com/p4lang/p4runtime/go/p4/v1"
@@ -25,29 +30,125 @@ var writeReples sync.WaitGroup
 var failedWrites uint32
 var p4infoHelper p4rt.P4InfoHelper
 
+const routingTable = "FabricIngress.forwarding.routing_v4"
+
 func main() {
 
 	target := flag.String("target", "localhost:28000", "")
+	tlsCA := flag.String("tlsCA", "", "PEM CA bundle to verify the switch against; turns on TLS.")
+	tlsCert := flag.String("tlsCert", "", "PEM client certificate for mutual TLS; needs -tlsKey.")
+	tlsKey := flag.String("tlsKey", "", "PEM key of -tlsCert.")
+	tlsServerName := flag.String("tlsServerName", "", "Name to check the switch certificate against instead of the -target host.")
+	tlsSkipVerify := flag.Bool("tlsSkipVerify", false, "Use TLS but accept any switch certificate (lab use only).")
+	token := flag.String("token", "", "Bearer token sent with every RPC.")
 	p4infoPath := flag.String("p4info", "", "")
 	iterations := flag.Int("iterations", 1, "total iterations to run")
 	deviceConfig := flag.String("deviceConfig", "", "")
+	pipelineAction := flag.String("pipelineAction", "VERIFY_AND_COMMIT", "SetForwardingPipelineConfig action: VERIFY, VERIFY_AND_SAVE or VERIFY_AND_COMMIT.")
 	batchSize := flag.Int("batchSize", 100, "Number of table entries per batch.")
 	numThreads := flag.Int("numThreads", 1, "Number of threads to send write request.")
+	connections := flag.Int("connections", 1, "Number of gRPC connections to spread writes over, each with -numThreads threads.")
+	writeRate := flag.Float64("writeRate", 0, "Pace writes to this many updates per second (open loop); 0 writes as fast as possible.")
+	arbitrationTimeout := flag.Duration("arbitrationTimeout", 5*time.Second, "How long to wait for the switch to answer master arbitration.")
+	arbitrationOptional := flag.Bool("arbitrationOptional", false, "Proceed without arbitration if the switch does not implement StreamChannel.")
+	electionID := flag.Uint64("electionID", 1, "Election ID used for master arbitration.")
+	role := flag.Uint64("role", 0, "P4Runtime role ID to arbitrate for; 0 is the default role.")
+	backup := flag.Bool("backup", false, "Run as a backup controller: hold writes until this client becomes primary.")
+	reconnect := flag.Duration("reconnect", 0, "Reopen a broken stream channel, backing off from this duration up to 30s, and re-arbitrate; 0 disables.")
+	replayWrites := flag.Bool("replayWrites", false, "With -reconnect, resend writes that fail with UNAVAILABLE once the stream is back.")
+	retryAttempts := flag.Int("retryAttempts", 1, "Send updates failing with UNAVAILABLE, RESOURCE_EXHAUSTED or ABORTED up to this many times in all; 1 disables retries.")
+	retryBackoff := flag.Duration("retryBackoff", 10*time.Millisecond, "Wait before the first retry of failed updates, doubling up to 1s for later retries.")
+	label := flag.String("label", "", "Free-form label recorded in the result file.")
+	metadata := p4rt.RunMetadata{}
+	flag.Var(metadata, "meta", "key=value describing the run (switch model, firmware, git SHA, ...), recorded in the result file. May be repeated.")
+	reportOccupancy := flag.Bool("reportOccupancy", false, "Report the occupancy of the routing table before and after the run.")
+	reconfigIterations := flag.Int("reconfigIterations", 0, "After the write benchmark, push the pipeline this many times and report commit latency.")
+	altP4infoPath := flag.String("altP4info", "", "P4Info of a second pipeline to alternate with during -reconfigIterations.")
+	altDeviceConfig := flag.String("altDeviceConfig", "", "Device config of the second pipeline for -altP4info.")
+	metricsAddr := flag.String("metricsAddr", "", "Serve Prometheus write metrics on this address (e.g. :9090) at /metrics.")
+	traceCollector := flag.String("traceCollector", "", "Address of a TraceCollector service to stream write traces to.")
+	traceFile := flag.String("traceFile", "", "Export every write trace to this file.")
+	traceFormat := flag.String("traceFormat", "csv", "Format of -traceFile: csv or json (one object per line).")
+	traceFileSize := flag.Int64("traceFileSize", 0, "Rotate -traceFile once it reaches this many bytes, keeping -traceFiles older files; 0 never rotates.")
+	traceFiles := flag.Int("traceFiles", 5, "Number of rotated trace files to keep.")
+	record := flag.String("record", "", "Record every write request of the run to this capture file.")
+	replayCapture := flag.String("replayCapture", "", "After the run, resend the writes of this capture file and report their latency.")
+	replaySpeedup := flag.Float64("replaySpeedup", 0, "Replay -replayCapture with its original timing sped up this many times; 0 sends as fast as possible.")
+	fleetConfig := flag.String("fleet", "", "JSON list of switches to run the insert benchmark against concurrently, instead of -target.")
+	readCounter := flag.String("readCounter", "", "Read this counter array back to back while the inserts run, and report how fast it is served.")
+	readDirectCounters := flag.String("readDirectCounters", "", "Read the direct counters of this table back to back while the inserts run, and report how fast they are served.")
+	statsReaders := flag.Int("statsReaders", 1, "Number of concurrent readers for -readCounter and -readDirectCounters.")
+	digest := flag.String("digest", "", "Subscribe to this digest during the inserts, acking every list, and report digest-to-ack latency.")
+	deleteBenchmark := flag.Bool("deleteBenchmark", false, "After the insert phase, delete the inserted entries and report delete latency and throughput separately.")
 
 	flag.Parse()
 
+	if *fleetConfig != "" {
+		runFleet(*fleetConfig, *p4infoPath, *iterations, *batchSize, *numThreads, *arbitrationTimeout)
+		return
+	}
+
+	err := p4rt.SetClientOptions(*target, p4rt.ClientOptions{
+		CAFile:             *tlsCA,
+		CertFile:           *tlsCert,
+		KeyFile:            *tlsKey,
+		ServerName:         *tlsServerName,
+		InsecureSkipVerify: *tlsSkipVerify,
+		Token:              *token,
+	})
+	if err != nil {
+		panic(err)
+	}
 	client, err := p4rt.CreateOrGetP4RuntimeClient(*target, 1, *batchSize, *numThreads)
 	if err != nil {
 		panic(err)
 	}
+	defer client.Close()
+	if err = client.SetWritePipelines(*connections); err != nil {
+		panic(err)
+	}
 
-	err = client.SetMastership(p4.Uint128{High: 0, Low: 1})
-	if err != nil {
+	// Ctrl-C cancels queued and in-flight writes; the results collected so far are still saved
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		fmt.Fprintln(os.Stderr, "Interrupted, cancelling outstanding writes")
+		cancel()
+	}()
+	client.SetContext(ctx)
+	client.SetArbitrationOptional(*arbitrationOptional)
+	client.SetRole(*role)
+	client.SetQueueWritesUntilPrimary(*backup)
+	client.SetWriteRate(*writeRate, *batchSize)
+	client.SetReconnectPolicy(p4rt.ReconnectPolicy{
+		MinBackoff:   *reconnect,
+		MaxBackoff:   30 * time.Second,
+		ReplayWrites: *replayWrites,
+	})
+	client.SetRetryPolicy(p4rt.RetryPolicy{
+		MaxAttempts: *retryAttempts,
+		MinBackoff:  *retryBackoff,
+		MaxBackoff:  time.Second,
+	})
+
+	err = client.Arbitrate(p4.Uint128{High: 0, Low: *electionID}, *arbitrationTimeout)
+	if err != nil && !*backup {
 		panic(err)
+	} else if err != nil {
+		fmt.Printf("Running as backup, waiting to become primary: %v\n", err)
 	}
 
-	err = client.SetForwardingPipelineConfig(*p4infoPath, *deviceConfig)
-	if err != nil {
+	action, ok := p4.SetForwardingPipelineConfigRequest_Action_value[strings.ToUpper(*pipelineAction)]
+	if !ok {
+		panic(fmt.Errorf("unknown pipeline action %s", *pipelineAction))
+	}
+	if *backup {
+		// Only the primary may push a pipeline; a backup uses the one installed
+		fmt.Println("Running as backup, not pushing the pipeline config")
+	} else if err = client.PushPipelineConfig(*p4infoPath, *deviceConfig, p4.SetForwardingPipelineConfigRequest_Action(action)); err != nil {
 		panic(err)
 	}
 
@@ -56,7 +157,56 @@ func main() {
 		panic(err)
 	}
 
+	metadata["target"] = p4rt.TestTarget()
+	metadata["batch_size"] = strconv.Itoa(*batchSize)
+	metadata["iterations"] = strconv.Itoa(*iterations)
+	metadata["num_threads"] = strconv.Itoa(*numThreads)
+	metadata["connections"] = strconv.Itoa(*connections)
+	metadata["start"] = time.Now().UTC().Format(time.RFC3339)
+	if *label != "" {
+		metadata["label"] = *label
+	}
+	if info, err := client.FetchServerInfo(ctx); err == nil {
+		metadata["pipeline"] = info.PipelineName
+		metadata["pipeline_version"] = info.PipelineVersion
+		metadata["p4runtime_api_version"] = info.P4RuntimeAPIVersion
+	} else {
+		fmt.Fprintf(os.Stderr, "Unable to fetch server info: %v\n", err)
+	}
+
 	// Set up write tracing for test
+	var traceSink *p4rt.RemoteTraceSink
+	if *traceCollector != "" {
+		source, _ := os.Hostname()
+		if *label != "" {
+			source = *label
+		}
+		traceSink, err = p4rt.NewRemoteTraceSink(*traceCollector, source, 100, time.Second)
+		if err != nil {
+			panic(err)
+		}
+	}
+	var traceExporter *p4rt.TraceExporter
+	if *traceFile != "" {
+		format, err := p4rt.ParseTraceFormat(*traceFormat)
+		if err != nil {
+			panic(err)
+		}
+		if traceExporter, err = p4rt.NewTraceExporter(*traceFile, format, *traceFileSize, *traceFiles, metadata); err != nil {
+			panic(err)
+		}
+	}
+	var writeMetrics *metrics.WriteMetrics
+	if *metricsAddr != "" {
+		writeMetrics = metrics.NewWriteMetrics()
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", writeMetrics.Handler())
+		go func() {
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				fmt.Fprintf(os.Stderr, "Metrics server: %v\n", err)
+			}
+		}()
+	}
 	writeTraceChan := make(chan p4rt.WriteTrace, 1000)
 	client.SetWriteTraceChan(writeTraceChan)
 	doneChan := make(chan []time.Duration)
@@ -67,6 +217,16 @@ func main() {
 			select {
 			case trace := <-writeTraceChan:
 				durations[currentIteration] = trace.Duration
+				if traceSink != nil {
+					traceSink.Push(trace)
+				}
+				if writeMetrics != nil {
+					writeMetrics.Observe(trace)
+				}
+				if traceExporter != nil {
+					// A failed exporter keeps its error for Close
+					traceExporter.Write(trace)
+				}
 				currentIteration++
 				if currentIteration == *iterations {
 					doneChan <- durations
@@ -75,19 +235,165 @@ func main() {
 					// Should not happened
 					panic(fmt.Errorf("Current iteration %d is greater than target iteration number %d", currentIteration, *iterations))
 				}
+			case <-ctx.Done():
+				doneChan <- durations[:currentIteration]
+				return
 			}
 		}
 	}()
 
+	if *reportOccupancy {
+		printOccupancy(client, "before run")
+	}
+
+	var recorder *p4rt.WriteRecorder
+	if *record != "" {
+		if recorder, err = p4rt.NewWriteRecorder(*record); err != nil {
+			panic(err)
+		}
+		client.SetWriteRecorder(recorder)
+	}
+
+	// Read statistics under the write load, if asked to
+	var statsRead func() (int, error)
+	switch {
+	case *readCounter != "":
+		statsRead = func() (int, error) {
+			entries, err := client.ReadCounters(*readCounter)
+			return len(entries), err
+		}
+	case *readDirectCounters != "":
+		statsRead = func() (int, error) {
+			entries, err := client.ReadDirectCounters(*readDirectCounters)
+			return len(entries), err
+		}
+	}
+	stopStatsReads := make(chan struct{})
+	statsReads := make(chan p4rt.ReadBenchmarkResult, 1)
+	if statsRead != nil {
+		go func() {
+			result, err := p4rt.StatsReadBenchmark(statsRead, *statsReaders, stopStatsReads)
+			if err != nil {
+				panic(err)
+			}
+			statsReads <- result
+		}()
+	}
+
+	// Ack and time digests generated during the run, if asked to
+	var digestTraces chan p4rt.DigestTrace
+	var digestSub *p4rt.DigestSubscription
+	if *digest != "" {
+		digestTraces = make(chan p4rt.DigestTrace, 10000)
+		client.SetDigestTraceChan(digestTraces)
+		if digestSub, err = client.SubscribeDigest(*digest, p4rt.DigestOptions{AutoAck: true}); err != nil {
+			panic(err)
+		}
+		go func() {
+			// The lists are already acked; their traces carry the timing
+			for range digestSub.C {
+			}
+		}()
+	}
+
 	// Send the flow entries
 	writeReples.Add(int(*iterations))
-	SendTableEntries(client, *iterations, *batchSize)
+	insertStart := time.Now()
+	requests := SendTableEntries(client, *iterations, *batchSize)
 
 	// Wait for all writes to finish
 	durations := <-doneChan
 
 	writeReples.Wait()
+	insertElapsed := time.Since(insertStart)
 	fmt.Printf("Number of failed writes: %d\n", failedWrites)
+	if digestSub != nil {
+		if err := digestSub.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Unsubscribing from digest: %v\n", err)
+		}
+		client.SetDigestTraceChan(nil)
+		latencies := make([]time.Duration, 0, len(digestTraces))
+		for len(digestTraces) > 0 {
+			latencies = append(latencies, (<-digestTraces).Latency)
+		}
+		stats := client.Stats()
+		fmt.Printf("Digest lists: %d received, %d dropped, digest-to-ack latency %v (%d traces dropped)\n",
+			stats.DigestListsReceived, stats.DigestListsDropped, p4rt.SummarizeLatencies(latencies), stats.DigestTracesDropped)
+	}
+	if statsRead != nil {
+		close(stopStatsReads)
+		fmt.Printf("Statistics reads during inserts: %v\n", <-statsReads)
+	}
+	if recorder != nil {
+		client.SetWriteRecorder(nil)
+		if err := recorder.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Recording writes: %v\n", err)
+		}
+		fmt.Printf("Writes recorded to %s: %d\n", *record, recorder.Count())
+	}
+	if traceSink != nil {
+		traceSink.Close()
+		fmt.Printf("Traces sent to collector: %d, dropped: %d\n", traceSink.Sent(), traceSink.Dropped())
+		if err := traceSink.Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "Trace collector: %v\n", err)
+		}
+	}
+	if traceExporter != nil {
+		if err := traceExporter.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Exporting traces: %v\n", err)
+		}
+		fmt.Printf("Traces exported to %s: %d\n", *traceFile, traceExporter.Count())
+	}
+	if *reportOccupancy {
+		printOccupancy(client, "after run")
+	}
+
+	if *deleteBenchmark && ctx.Err() == nil {
+		client.SetWriteTraceChan(nil)
+		var entries []*p4.TableEntry
+		for _, req := range requests {
+			for _, update := range req.Updates {
+				entries = append(entries, update.GetEntity().GetTableEntry())
+			}
+		}
+		insert := p4rt.BenchmarkResult{
+			Updates: len(entries),
+			Failed:  int(failedWrites),
+			Elapsed: insertElapsed,
+			Latency: p4rt.SummarizeLatencies(durations),
+		}
+		del, err := p4rt.DeleteBenchmark(client, entries, *batchSize, *numThreads)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Insert: %v\n", insert)
+		fmt.Printf("Delete: %v\n", del)
+	}
+
+	if *replayCapture != "" && ctx.Err() == nil {
+		client.SetWriteTraceChan(nil)
+		writes, err := p4rt.LoadCapture(*replayCapture)
+		if err != nil {
+			panic(err)
+		}
+		replay, err := client.ReplayCapture(writes, *replaySpeedup)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Replay of %s: %v\n", *replayCapture, replay)
+	}
+
+	if *reconfigIterations > 0 && ctx.Err() == nil {
+		pipelines := []p4rt.PipelinePaths{{P4Info: *p4infoPath, DeviceConfig: *deviceConfig}}
+		if *altP4infoPath != "" {
+			pipelines = append(pipelines, p4rt.PipelinePaths{P4Info: *altP4infoPath, DeviceConfig: *altDeviceConfig})
+		}
+		reconfig, err := client.PipelineReconfigBenchmark(pipelines, *reconfigIterations)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Pipeline commit: %v\n", reconfig)
+	}
 
 	fileName := fmt.Sprintf("test-result-%s-%d-%d-%d.csv", p4rt.TestTarget(), *batchSize, *iterations, time.Now().Unix())
 	fmt.Printf("Saving results to %s\n", fileName)
@@ -97,6 +403,9 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	if err := metadata.WriteComments(csvFile); err != nil {
+		panic(err)
+	}
 	resultWriter := csv.NewWriter(csvFile)
 
 	for i, d := range durations {
@@ -107,13 +416,23 @@ func main() {
 }
 
 // SendTableEntries writes multiple table entries to the routing_v4
-// table.
-func SendTableEntries(client p4rt.P4RuntimeClient, iterations int, batchSize int) {
+// table and returns the requests it sent.
+func SendTableEntries(client p4rt.P4RuntimeClient, iterations int, batchSize int) []*p4.WriteRequest {
+	requests := tableEntryRequests(client, iterations, batchSize)
+	for _, req := range requests {
+		res := client.Write(req)
+		go CountFailed(proto.Clone(req).(*p4.WriteRequest), res)
+	}
+	return requests
+}
 
+// tableEntryRequests builds iterations requests of batchSize routing_v4
+// entries each.
+func tableEntryRequests(client p4rt.P4RuntimeClient, iterations int, batchSize int) []*p4.WriteRequest {
 	// Prepare write requests for all iterations
 	requests := make([]*p4.WriteRequest, iterations)
 	for i := 0; i < iterations; i++ {
-		tableID, err := p4infoHelper.GetP4Id("FabricIngress.forwarding.routing_v4")
+		tableID, err := p4infoHelper.GetP4Id(routingTable)
 		if err != nil {
 			panic(err)
 		}
@@ -129,7 +448,7 @@ func SendTableEntries(client p4rt.P4RuntimeClient, iterations int, batchSize int
 				{
 					FieldId: 1, // ipv4_dst
 					FieldMatchType: &p4.FieldMatch_Lpm{
-						&p4.FieldMatch_LPM{
+						Lpm: &p4.FieldMatch_LPM{
 							Value:     Uint64(uint64(i*batchSize + j))[4:8],
 							PrefixLen: 32,
 						},
@@ -163,10 +482,48 @@ func SendTableEntries(client p4rt.P4RuntimeClient, iterations int, batchSize int
 		}
 		requests[i] = req
 	}
+	return requests
+}
 
-	for _, req := range requests {
-		res := client.Write(req)
-		go CountFailed(proto.Clone(req).(*p4.WriteRequest), res)
+// runFleet runs the insert benchmark against every switch of the fleet
+// config at once and prints per-switch and fleet-wide results.
+func runFleet(path, p4infoPath string, iterations, batchSize, numThreads int, arbitrationTimeout time.Duration) {
+	config, err := p4rt.LoadFleetConfig(path)
+	if err != nil {
+		panic(err)
+	}
+	if err = p4infoHelper.Init(p4infoPath); err != nil {
+		panic(err)
+	}
+	fleet, err := p4rt.NewFleet(config, batchSize, numThreads, arbitrationTimeout)
+	if err != nil {
+		panic(err)
+	}
+	defer fleet.Close()
+
+	result := fleet.Run(func(device p4rt.FleetDevice) error {
+		var responses []<-chan []*p4.Error
+		for _, req := range tableEntryRequests(device.Client, iterations, batchSize) {
+			responses = append(responses, device.Client.Write(req))
+		}
+		for _, res := range responses {
+			<-res
+		}
+		return nil
+	})
+	result.WriteReport(os.Stdout)
+}
+
+func printOccupancy(client p4rt.P4RuntimeClient, when string) {
+	used, max, err := client.TableOccupancy(routingTable)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read occupancy of %s: %v\n", routingTable, err)
+		return
+	}
+	if max > 0 {
+		fmt.Printf("Occupancy of %s %s: %d/%d (%.1f%%)\n", routingTable, when, used, max, 100*float64(used)/float64(max))
+	} else {
+		fmt.Printf("Occupancy of %s %s: %d\n", routingTable, when, used)
 	}
 }
 
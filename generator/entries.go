@@ -0,0 +1,465 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+// Package generator produces reproducible table entry workloads from P4Info.
+package generator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+
+	"github.com/Yi-Tseng/p4r-perf/p4rt"
+	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// EntryGenerator builds entries for one table, all with the same action
+// unless SetActions gives a weighted mix. Entry n is a pure function of n,
+// the seed and the configuration, so runs are reproducible.
+//
+// Match fields given a cardinality with SetCardinality, or a FieldSpec with
+// SetFieldSpec, take that many distinct values, and the generated keys walk
+// their cross-product. Every other ("free") match field takes the entry's
+// position in that walk divided by the cross-product size, so keys never
+// repeat.
+type EntryGenerator struct {
+	p4info   *p4rt.P4InfoHelper
+	table    *p4_config.Table
+	action   *p4.Action
+	actions  []weightedAction // if set, replaces action
+	weights  uint64           // sum of the actions' weights
+	fields   []*field
+	priority int32
+	metadata []byte
+	seed     int64
+
+	sample  uint64   // if non-zero, entries are a subset of this size
+	permA   *big.Int // permute multiplier, coprime with product
+	permB   *big.Int
+	space   *p4rt.IDSpace
+	worker  int
+	product uint64 // cross-product size of the bounded fields
+}
+
+type field struct {
+	info        *p4_config.MatchField
+	spec        specKind
+	cardinality uint64 // 0 for a free field
+	low         uint64 // first value of a Range field, the value of a Fixed one
+	multiplier  uint64 // odd, so value scrambling is a bijection
+	offset      uint64
+}
+
+type specKind int
+
+const (
+	specScrambled specKind = iota // free, or bounded by SetCardinality
+	specFixed
+	specRange
+	specWildcard
+)
+
+// FieldSpec says how the values of one match field are generated; see
+// SetFieldSpec.
+type FieldSpec struct {
+	kind      specKind
+	low, high uint64
+}
+
+// Fixed gives a match field the same value in every entry.
+func Fixed(value uint64) FieldSpec {
+	return FieldSpec{kind: specFixed, low: value, high: value}
+}
+
+// Range makes a match field walk the values low to high, in order.
+func Range(low, high uint64) FieldSpec {
+	return FieldSpec{kind: specRange, low: low, high: high}
+}
+
+// Wildcard leaves a match field out of every entry, which matches any
+// value. Only ternary, range and optional fields may be wildcards.
+func Wildcard() FieldSpec {
+	return FieldSpec{kind: specWildcard}
+}
+
+// NewEntryGenerator returns a generator for tableName whose entries run
+// actionName with params (keyed by parameter name).
+func NewEntryGenerator(p4info *p4rt.P4InfoHelper, tableName, actionName string, params map[string][]byte, seed int64) (*EntryGenerator, error) {
+	table, err := p4info.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	action, err := p4info.GetAction(actionName)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) != len(action.GetParams()) {
+		return nil, fmt.Errorf("action %s takes %d parameters, got %d",
+			actionName, len(action.GetParams()), len(params))
+	}
+	p4Action := &p4.Action{ActionId: action.GetPreamble().GetId()}
+	for _, param := range action.GetParams() {
+		value, ok := params[param.GetName()]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter %s for action %s", param.GetName(), actionName)
+		}
+		p4Action.Params = append(p4Action.Params, &p4.Action_Param{ParamId: param.GetId(), Value: value})
+	}
+
+	g := &EntryGenerator{p4info: p4info, table: table, action: p4Action, seed: seed, product: 1}
+	for _, mf := range table.GetMatchFields() {
+		mix := splitmix64(uint64(seed) ^ uint64(mf.GetId()))
+		g.fields = append(g.fields, &field{
+			info:       mf,
+			multiplier: mix | 1,
+			offset:     splitmix64(mix),
+		})
+		switch mf.GetMatchType() {
+		case p4_config.MatchField_TERNARY, p4_config.MatchField_RANGE, p4_config.MatchField_OPTIONAL:
+			g.priority = 1
+		}
+	}
+	return g, nil
+}
+
+// SetCardinality limits fieldName to n distinct values, spread over its
+// width.
+func (g *EntryGenerator) SetCardinality(fieldName string, n uint64) error {
+	if n == 0 {
+		return fmt.Errorf("invalid cardinality 0 for match field %s", fieldName)
+	}
+	f, err := g.field(fieldName)
+	if err != nil {
+		return err
+	}
+	if f.info.GetBitwidth() < 64 && n > 1<<uint(f.info.GetBitwidth()) {
+		return fmt.Errorf("match field %s has %d bits, too few for %d distinct values",
+			fieldName, f.info.GetBitwidth(), n)
+	}
+	f.spec, f.cardinality, f.low = specScrambled, n, 0
+	return g.updateProduct()
+}
+
+// SetFieldSpec replaces how fieldName's values are generated: a Fixed
+// value, a Range of values walked like a cardinality, or a Wildcard. It
+// fails if the values do not fit the field, or for a wildcard on an exact
+// or LPM field, which every entry must match.
+func (g *EntryGenerator) SetFieldSpec(fieldName string, spec FieldSpec) error {
+	f, err := g.field(fieldName)
+	if err != nil {
+		return err
+	}
+	switch spec.kind {
+	case specWildcard:
+		switch f.info.GetMatchType() {
+		case p4_config.MatchField_EXACT, p4_config.MatchField_LPM:
+			return fmt.Errorf("%v match field %s cannot be a wildcard", f.info.GetMatchType(), fieldName)
+		}
+		f.spec, f.cardinality, f.low = specWildcard, 1, 0
+	case specFixed, specRange:
+		if spec.high < spec.low {
+			return fmt.Errorf("invalid range %d..%d for match field %s", spec.low, spec.high, fieldName)
+		}
+		if width := f.info.GetBitwidth(); width < 64 && spec.high>>uint(width) != 0 {
+			return fmt.Errorf("value %d does not fit in the %d bits of match field %s", spec.high, width, fieldName)
+		}
+		if spec.low == 0 && spec.high == math.MaxUint64 {
+			return fmt.Errorf("range of match field %s has more than 2^64-1 values", fieldName)
+		}
+		f.spec, f.cardinality, f.low = spec.kind, spec.high-spec.low+1, spec.low
+	default:
+		return fmt.Errorf("invalid spec for match field %s", fieldName)
+	}
+	return g.updateProduct()
+}
+
+func (g *EntryGenerator) field(fieldName string) (*field, error) {
+	for _, f := range g.fields {
+		if f.info.GetName() == fieldName {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("Unable to find match field %s in table %s", fieldName, g.table.GetPreamble().GetName())
+}
+
+// updateProduct recomputes the cross-product size after a field changed.
+func (g *EntryGenerator) updateProduct() error {
+	g.product = 1
+	g.sample = 0 // the sample was drawn from the old cross-product
+	for _, f := range g.fields {
+		if f.cardinality > 0 {
+			hi, lo := bits.Mul64(g.product, f.cardinality)
+			if hi != 0 {
+				return fmt.Errorf("cross-product of field cardinalities overflows")
+			}
+			g.product = lo
+		}
+	}
+	return nil
+}
+
+// SetSample makes the generator produce a deterministic subset of n keys,
+// spread over the whole cross-product, instead of walking it in order. It
+// requires every match field to have a cardinality, and changing a
+// cardinality afterwards cancels the sample.
+func (g *EntryGenerator) SetSample(n uint64) error {
+	if g.free() {
+		return fmt.Errorf("sampling needs a cardinality on every match field")
+	}
+	if n > g.product {
+		return fmt.Errorf("sample of %d is larger than the %d-key cross-product", n, g.product)
+	}
+	size := new(big.Int).SetUint64(g.product)
+	a := new(big.Int).SetUint64(splitmix64(uint64(g.seed))%g.product | 1)
+	one := big.NewInt(1)
+	for new(big.Int).GCD(nil, nil, a, size).Cmp(one) != 0 {
+		a.Add(a, one)
+	}
+	g.permA = a
+	g.permB = new(big.Int).SetUint64(splitmix64(uint64(g.seed) + 1))
+	g.sample = n
+	return nil
+}
+
+// ParamGenerator returns an action parameter's value for the entry with
+// key index n. It must be a pure function of n for entries to be
+// reproducible.
+type ParamGenerator func(n uint64) []byte
+
+// ConstParam gives a parameter the same value in every entry.
+func ConstParam(value []byte) ParamGenerator {
+	return func(uint64) []byte { return value }
+}
+
+// CycleParams gives the entry with key index n the value values[n %
+// len(values)]. values must not be empty.
+func CycleParams(values ...[]byte) ParamGenerator {
+	return func(n uint64) []byte { return values[n%uint64(len(values))] }
+}
+
+// WeightedAction is one action of a SetActions mix. Weight is relative to
+// the other actions', and Params generates each parameter, keyed by name.
+type WeightedAction struct {
+	Name   string
+	Weight int
+	Params map[string]ParamGenerator
+}
+
+type weightedAction struct {
+	id       uint32
+	weight   uint64
+	paramIDs []uint32
+	params   []ParamGenerator
+}
+
+// SetActions makes each entry run one of actions, picked by weight. The
+// pick is drawn from the seed and the entry's key index, so the same seed
+// gives the same sequence of actions. Every parameter of each action needs
+// a generator.
+func (g *EntryGenerator) SetActions(actions []WeightedAction) error {
+	if len(actions) == 0 {
+		return fmt.Errorf("no actions for table %s", g.table.GetPreamble().GetName())
+	}
+	var weighted []weightedAction
+	var total uint64
+	for _, wa := range actions {
+		if wa.Weight <= 0 {
+			return fmt.Errorf("invalid weight %d for action %s", wa.Weight, wa.Name)
+		}
+		action, err := g.p4info.GetAction(wa.Name)
+		if err != nil {
+			return err
+		}
+		if len(wa.Params) != len(action.GetParams()) {
+			return fmt.Errorf("action %s takes %d parameters, got %d",
+				wa.Name, len(action.GetParams()), len(wa.Params))
+		}
+		a := weightedAction{id: action.GetPreamble().GetId(), weight: uint64(wa.Weight)}
+		for _, param := range action.GetParams() {
+			gen, ok := wa.Params[param.GetName()]
+			if !ok || gen == nil {
+				return fmt.Errorf("missing parameter %s for action %s", param.GetName(), wa.Name)
+			}
+			a.paramIDs = append(a.paramIDs, param.GetId())
+			a.params = append(a.params, gen)
+		}
+		weighted = append(weighted, a)
+		total += a.weight
+	}
+	g.actions = weighted
+	g.weights = total
+	return nil
+}
+
+// entryAction returns the action of the entry with key index n.
+func (g *EntryGenerator) entryAction(n uint64) *p4.Action {
+	if len(g.actions) == 0 {
+		return g.action
+	}
+	r := splitmix64(uint64(g.seed)^splitmix64(n)) % g.weights
+	a := &g.actions[len(g.actions)-1]
+	for i := range g.actions {
+		if r < g.actions[i].weight {
+			a = &g.actions[i]
+			break
+		}
+		r -= g.actions[i].weight
+	}
+	action := &p4.Action{ActionId: a.id, Params: make([]*p4.Action_Param, len(a.paramIDs))}
+	for i, id := range a.paramIDs {
+		action.Params[i] = &p4.Action_Param{ParamId: id, Value: a.params[i](n)}
+	}
+	return action
+}
+
+// SetMetadata sets the metadata cookie (TableEntry.metadata) of every
+// generated entry, for example a generation ID identifying the run.
+func (g *EntryGenerator) SetMetadata(metadata []byte) {
+	g.metadata = metadata
+}
+
+// SetIDSpace makes the free match fields take their values from worker's
+// partition of space, so generators for different workers never collide.
+func (g *EntryGenerator) SetIDSpace(space *p4rt.IDSpace, worker int) {
+	g.space = space
+	g.worker = worker
+}
+
+// Size returns the number of distinct entries the generator can produce, or
+// 0 if there is a free match field and the count is bounded only by its
+// width.
+func (g *EntryGenerator) Size() uint64 {
+	if g.free() {
+		return 0
+	}
+	if g.sample > 0 {
+		return g.sample
+	}
+	return g.product
+}
+
+// Entry returns the n-th entry.
+func (g *EntryGenerator) Entry(n uint64) (*p4.TableEntry, error) {
+	if size := g.Size(); size > 0 && n >= size {
+		return nil, fmt.Errorf("entry %d is out of range; the generator has %d entries", n, size)
+	}
+	index := n
+	if g.sample > 0 {
+		index = g.permute(n)
+	}
+	rest := index / g.product // the part of the index left for free fields
+	digits := index % g.product
+
+	entry := &p4.TableEntry{
+		TableId:  g.table.GetPreamble().GetId(),
+		Priority: g.priority,
+		Metadata: g.metadata,
+		Action:   &p4.TableAction{Type: &p4.TableAction_Action{Action: g.entryAction(index)}},
+	}
+	for _, f := range g.fields {
+		var value uint64
+		if f.spec == specWildcard {
+			continue
+		} else if f.cardinality > 0 {
+			value = f.value(digits % f.cardinality)
+			digits /= f.cardinality
+		} else if g.space != nil {
+			var err error
+			if value, err = g.space.Key(g.worker, rest); err != nil {
+				return nil, err
+			}
+		} else {
+			value = rest
+		}
+		fm, err := fieldMatch(f.info, value)
+		if err != nil {
+			return nil, err
+		}
+		entry.Match = append(entry.Match, fm)
+	}
+	return entry, nil
+}
+
+func (g *EntryGenerator) free() bool {
+	for _, f := range g.fields {
+		if f.cardinality == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// permute maps n to a distinct index of the cross-product with an affine
+// bijection modulo its size.
+func (g *EntryGenerator) permute(n uint64) uint64 {
+	index := new(big.Int).SetUint64(n)
+	index.Mul(index, g.permA).Add(index, g.permB).Mod(index, new(big.Int).SetUint64(g.product))
+	return index.Uint64()
+}
+
+// value returns the k-th of a bounded field's values.
+func (f *field) value(k uint64) uint64 {
+	if f.spec == specScrambled {
+		return f.scramble(k)
+	}
+	return f.low + k
+}
+
+// scramble spreads the k-th value over the field's width. Multiplying by an
+// odd number modulo a power of two is a bijection, so distinct k stay
+// distinct.
+func (f *field) scramble(k uint64) uint64 {
+	v := k*f.multiplier + f.offset
+	if width := f.info.GetBitwidth(); width < 64 {
+		v &= 1<<uint(width) - 1
+	}
+	return v
+}
+
+// fieldMatch matches exactly value on a field of any match type.
+func fieldMatch(mf *p4_config.MatchField, value uint64) (*p4.FieldMatch, error) {
+	width := mf.GetBitwidth()
+	if width < 64 && value>>uint(width) != 0 {
+		return nil, fmt.Errorf("value %d does not fit in the %d bits of match field %s", value, width, mf.GetName())
+	}
+	b := make([]byte, (width+7)/8)
+	for i := len(b) - 1; i >= 0 && value != 0; i-- {
+		b[i] = byte(value)
+		value >>= 8
+	}
+	fm := &p4.FieldMatch{FieldId: mf.GetId()}
+	switch mf.GetMatchType() {
+	case p4_config.MatchField_EXACT:
+		fm.FieldMatchType = &p4.FieldMatch_Exact_{Exact: &p4.FieldMatch_Exact{Value: b}}
+	case p4_config.MatchField_LPM:
+		fm.FieldMatchType = &p4.FieldMatch_Lpm{Lpm: &p4.FieldMatch_LPM{Value: b, PrefixLen: width}}
+	case p4_config.MatchField_TERNARY:
+		mask := make([]byte, len(b))
+		for i := range mask {
+			mask[i] = 0xff
+		}
+		if extra := len(b)*8 - int(width); extra > 0 {
+			mask[0] >>= uint(extra)
+		}
+		fm.FieldMatchType = &p4.FieldMatch_Ternary_{Ternary: &p4.FieldMatch_Ternary{Value: b, Mask: mask}}
+	case p4_config.MatchField_RANGE:
+		fm.FieldMatchType = &p4.FieldMatch_Range_{Range: &p4.FieldMatch_Range{Low: b, High: b}}
+	case p4_config.MatchField_OPTIONAL:
+		fm.FieldMatchType = &p4.FieldMatch_Optional_{Optional: &p4.FieldMatch_Optional{Value: b}}
+	default:
+		return nil, fmt.Errorf("unsupported match type %v for match field %s", mf.GetMatchType(), mf.GetName())
+	}
+	return fm, nil
+}
+
+// splitmix64 is the SplitMix64 mixing function, used to derive per-field
+// constants from the seed.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,196 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package generator
+
+import (
+	"testing"
+
+	"github.com/Yi-Tseng/p4r-perf/p4rt"
+	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
+)
+
+// testP4Info has table "acl", matching an 8-bit exact "port", 32-bit
+// ternary "src" and "dst" and a 16-bit range "l4_port", whose entries may
+// use actions "forward" (an 8-bit "out"), "drop" and "mirror" (a 16-bit
+// "session").
+func testP4Info() *p4rt.P4InfoHelper {
+	matchType := func(t p4_config.MatchField_MatchType) *p4_config.MatchField_MatchType_ {
+		return &p4_config.MatchField_MatchType_{MatchType: t}
+	}
+	helper := &p4rt.P4InfoHelper{}
+	helper.InitFromP4Info(p4_config.P4Info{
+		Tables: []*p4_config.Table{{
+			Preamble: &p4_config.Preamble{Id: 1, Name: "acl"},
+			MatchFields: []*p4_config.MatchField{
+				{Id: 1, Name: "port", Bitwidth: 8, Match: matchType(p4_config.MatchField_EXACT)},
+				{Id: 2, Name: "src", Bitwidth: 32, Match: matchType(p4_config.MatchField_TERNARY)},
+				{Id: 3, Name: "dst", Bitwidth: 32, Match: matchType(p4_config.MatchField_TERNARY)},
+				{Id: 4, Name: "l4_port", Bitwidth: 16, Match: matchType(p4_config.MatchField_RANGE)},
+			},
+			ActionRefs: []*p4_config.ActionRef{{Id: 10}, {Id: 11}, {Id: 12}},
+		}},
+		Actions: []*p4_config.Action{
+			{Preamble: &p4_config.Preamble{Id: 10, Name: "forward"}, Params: []*p4_config.Action_Param{{Id: 1, Name: "out", Bitwidth: 8}}},
+			{Preamble: &p4_config.Preamble{Id: 11, Name: "drop"}},
+			{Preamble: &p4_config.Preamble{Id: 12, Name: "mirror"}, Params: []*p4_config.Action_Param{{Id: 1, Name: "session", Bitwidth: 16}}},
+		},
+	})
+	return helper
+}
+
+func TestSetFieldSpec(t *testing.T) {
+	g, err := NewEntryGenerator(testP4Info(), "acl", "drop", nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetFieldSpec("port", Fixed(7)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetFieldSpec("src", Wildcard()); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetFieldSpec("dst", Range(100, 104)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetFieldSpec("l4_port", Wildcard()); err != nil {
+		t.Fatal(err)
+	}
+	if size := g.Size(); size != 5 {
+		t.Fatalf("generator has %d entries, want 5", size)
+	}
+	for n := uint64(0); n < 5; n++ {
+		entry, err := g.Entry(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Wildcards are left out of the entry
+		if len(entry.GetMatch()) != 2 {
+			t.Fatalf("entry %d has %d match fields, want port and dst", n, len(entry.GetMatch()))
+		}
+		port, dst := entry.GetMatch()[0], entry.GetMatch()[1]
+		if port.GetFieldId() != 1 || port.GetExact().GetValue()[0] != 7 {
+			t.Errorf("entry %d matches port %v, want 7", n, port)
+		}
+		value := dst.GetTernary().GetValue()
+		if dst.GetFieldId() != 3 || value[3] != byte(100+n) || value[0]|value[1]|value[2] != 0 {
+			t.Errorf("entry %d matches dst %v, want %d", n, dst, 100+n)
+		}
+	}
+	if _, err := g.Entry(5); err == nil {
+		t.Error("entry beyond the range was generated")
+	}
+}
+
+func TestSetFieldSpecErrors(t *testing.T) {
+	g, err := NewEntryGenerator(testP4Info(), "acl", "drop", nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		field string
+		spec  FieldSpec
+	}{
+		{"wildcard exact field", "port", Wildcard()},
+		{"fixed value too wide", "port", Fixed(256)},
+		{"range too wide", "l4_port", Range(0, 1<<16)},
+		{"empty range", "dst", Range(5, 4)},
+		{"unknown field", "vlan", Fixed(1)},
+	}
+	for _, test := range tests {
+		if err := g.SetFieldSpec(test.field, test.spec); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+func TestSetActions(t *testing.T) {
+	newGenerator := func(seed int64) *EntryGenerator {
+		g, err := NewEntryGenerator(testP4Info(), "acl", "drop", nil, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = g.SetCardinality("port", 256); err != nil {
+			t.Fatal(err)
+		}
+		err = g.SetActions([]WeightedAction{
+			{Name: "forward", Weight: 70, Params: map[string]ParamGenerator{"out": CycleParams([]byte{1}, []byte{2})}},
+			{Name: "drop", Weight: 20},
+			{Name: "mirror", Weight: 10, Params: map[string]ParamGenerator{"session": ConstParam([]byte{0, 9})}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+	actions := func(g *EntryGenerator, n int) []uint32 {
+		ids := make([]uint32, n)
+		for i := range ids {
+			entry, err := g.Entry(uint64(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			action := entry.GetAction().GetAction()
+			ids[i] = action.GetActionId()
+			switch ids[i] {
+			case 10:
+				if out := action.GetParams()[0].GetValue()[0]; out != byte(1+i%2) {
+					t.Fatalf("entry %d forwards to %d, want %d", i, out, 1+i%2)
+				}
+			case 11:
+				if len(action.GetParams()) != 0 {
+					t.Fatalf("entry %d drops with parameters %v", i, action.GetParams())
+				}
+			}
+		}
+		return ids
+	}
+
+	const entries = 10000
+	first := actions(newGenerator(1), entries)
+	counts := map[uint32]int{}
+	for _, id := range first {
+		counts[id]++
+	}
+	for id, want := range map[uint32]int{10: 7000, 11: 2000, 12: 1000} {
+		if got := counts[id]; got < want*9/10 || got > want*11/10 {
+			t.Errorf("action %d picked for %d of %d entries, want about %d", id, got, entries, want)
+		}
+	}
+
+	same, other := actions(newGenerator(1), entries), actions(newGenerator(2), entries)
+	differs := false
+	for i := range first {
+		if same[i] != first[i] {
+			t.Fatalf("entry %d picked action %d, then %d with the same seed", i, first[i], same[i])
+		}
+		differs = differs || other[i] != first[i]
+	}
+	if !differs {
+		t.Error("a different seed picked the same actions")
+	}
+}
+
+func TestSetActionsErrors(t *testing.T) {
+	g, err := NewEntryGenerator(testP4Info(), "acl", "drop", nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		actions []WeightedAction
+	}{
+		{"no actions", nil},
+		{"zero weight", []WeightedAction{{Name: "drop"}}},
+		{"unknown action", []WeightedAction{{Name: "punt", Weight: 1}}},
+		{"missing parameter", []WeightedAction{{Name: "forward", Weight: 1}}},
+		{"wrong parameter", []WeightedAction{{Name: "forward", Weight: 1, Params: map[string]ParamGenerator{"port": ConstParam([]byte{1})}}}},
+	}
+	for _, test := range tests {
+		if err := g.SetActions(test.actions); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
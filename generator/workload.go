@@ -0,0 +1,225 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package generator
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// Distribution chooses which installed key a modify or delete targets.
+type Distribution int
+
+const (
+	// Sequential walks the installed keys round-robin.
+	Sequential Distribution = iota
+	// Uniform picks any installed key with equal probability.
+	Uniform
+	// Zipfian favours a hot set of keys, at first the earliest installed.
+	Zipfian
+)
+
+func (d Distribution) String() string {
+	switch d {
+	case Sequential:
+		return "sequential"
+	case Uniform:
+		return "uniform"
+	case Zipfian:
+		return "zipfian"
+	}
+	return fmt.Sprintf("Distribution(%d)", int(d))
+}
+
+// ParseDistribution returns the Distribution named s, as printed by String.
+func ParseDistribution(s string) (Distribution, error) {
+	for _, d := range []Distribution{Sequential, Uniform, Zipfian} {
+		if d.String() == s {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown key distribution %s", s)
+}
+
+// Mix gives the relative weights of each update type. {Insert: 1} is a
+// pure fill; {Insert: 2, Modify: 1, Delete: 1} keeps the table growing
+// while churning it.
+type Mix struct {
+	Insert, Modify, Delete int
+}
+
+// defaultZipfS is the skew of Zipfian: the probability of the k-th key is
+// proportional to 1/(k+1)^s.
+const defaultZipfS = 1.1
+
+// Workload is a reproducible stream of updates to one table. Inserts take
+// fresh keys from an EntryGenerator in order; modifies and deletes target
+// keys the workload has inserted and not yet deleted, chosen by the
+// Distribution, so every update is valid against a table that starts
+// empty. Once the generator's keys run out, inserts reuse deleted keys.
+type Workload struct {
+	gen   *EntryGenerator
+	dist  Distribution
+	mix   Mix
+	total int
+	rng   *rand.Rand
+	zipfS float64
+	zipf  *rand.Zipf
+	zipfN int // number of keys zipf was built for
+
+	emitted   int
+	nextKey   uint64
+	installed []uint64 // keys currently in the table; deletes swap in the last
+	deleted   []uint64 // keys free for reuse once the generator runs out
+	cursor    int      // position of the next Sequential pick
+}
+
+// NewWorkload returns a workload of total updates drawn from gen.
+func NewWorkload(gen *EntryGenerator, dist Distribution, mix Mix, total int, seed int64) (*Workload, error) {
+	if mix.Insert < 0 || mix.Modify < 0 || mix.Delete < 0 || mix.Insert+mix.Modify+mix.Delete == 0 {
+		return nil, fmt.Errorf("invalid update mix %+v", mix)
+	}
+	if mix.Insert == 0 {
+		return nil, fmt.Errorf("update mix %+v never inserts, so there is nothing to modify or delete", mix)
+	}
+	if total < 0 {
+		return nil, fmt.Errorf("invalid number of updates %d", total)
+	}
+	return &Workload{
+		gen:   gen,
+		dist:  dist,
+		mix:   mix,
+		total: total,
+		rng:   rand.New(rand.NewSource(seed)),
+		zipfS: defaultZipfS,
+	}, nil
+}
+
+// SetZipfS sets the skew of the Zipfian distribution; it must be > 1.
+func (w *Workload) SetZipfS(s float64) error {
+	if s <= 1 {
+		return fmt.Errorf("invalid zipfian skew %v; it must be greater than 1", s)
+	}
+	w.zipfS = s
+	w.zipf = nil
+	return nil
+}
+
+// Installed returns the number of entries the updates so far leave in the
+// table, assuming all of them succeeded.
+func (w *Workload) Installed() int {
+	return len(w.installed)
+}
+
+// Next returns the next update, or io.EOF once total updates have been
+// returned.
+func (w *Workload) Next() (*p4.Update, error) {
+	if w.emitted >= w.total {
+		return nil, io.EOF
+	}
+	updateType := w.pickType()
+	var key uint64
+	switch updateType {
+	case p4.Update_INSERT:
+		key = w.freshKey()
+		w.installed = append(w.installed, key)
+	case p4.Update_MODIFY:
+		key = w.installed[w.pickInstalled()]
+	case p4.Update_DELETE:
+		i := w.pickInstalled()
+		key = w.installed[i]
+		last := len(w.installed) - 1
+		w.installed[i] = w.installed[last]
+		w.installed = w.installed[:last]
+		if w.gen.Size() > 0 {
+			w.deleted = append(w.deleted, key)
+		}
+	}
+	entry, err := w.gen.Entry(key)
+	if err != nil {
+		return nil, err
+	}
+	w.emitted++
+	return &p4.Update{
+		Type:   updateType,
+		Entity: &p4.Entity{Entity: &p4.Entity_TableEntry{TableEntry: entry}},
+	}, nil
+}
+
+// NextBatch returns up to n updates, fewer only at the end of the workload.
+// It returns io.EOF, with no updates, once the workload is exhausted.
+func (w *Workload) NextBatch(n int) ([]*p4.Update, error) {
+	var batch []*p4.Update
+	for len(batch) < n {
+		update, err := w.Next()
+		if err == io.EOF && len(batch) > 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, update)
+	}
+	return batch, nil
+}
+
+// pickType draws an update type from the mix, falling back to an insert
+// when nothing is installed and to a modify when no key is left to insert.
+func (w *Workload) pickType() p4.Update_Type {
+	updateType := p4.Update_INSERT
+	r := w.rng.Intn(w.mix.Insert + w.mix.Modify + w.mix.Delete)
+	if r >= w.mix.Insert+w.mix.Modify {
+		updateType = p4.Update_DELETE
+	} else if r >= w.mix.Insert {
+		updateType = p4.Update_MODIFY
+	}
+	if len(w.installed) == 0 {
+		return p4.Update_INSERT
+	}
+	if updateType == p4.Update_INSERT && !w.canInsert() {
+		return p4.Update_MODIFY
+	}
+	return updateType
+}
+
+func (w *Workload) canInsert() bool {
+	size := w.gen.Size()
+	return size == 0 || w.nextKey < size || len(w.deleted) > 0
+}
+
+func (w *Workload) freshKey() uint64 {
+	if size := w.gen.Size(); size == 0 || w.nextKey < size {
+		w.nextKey++
+		return w.nextKey - 1
+	}
+	key := w.deleted[0]
+	w.deleted = w.deleted[1:]
+	return key
+}
+
+// pickInstalled returns the position in installed of the next modify or
+// delete target. installed must not be empty.
+func (w *Workload) pickInstalled() int {
+	n := len(w.installed)
+	switch w.dist {
+	case Uniform:
+		return w.rng.Intn(n)
+	case Zipfian:
+		if w.zipf == nil || w.zipfN != n {
+			w.zipf = rand.NewZipf(w.rng, w.zipfS, 1, uint64(n-1))
+			w.zipfN = n
+		}
+		return int(w.zipf.Uint64())
+	default:
+		if w.cursor >= n {
+			w.cursor = 0
+		}
+		w.cursor++
+		return w.cursor - 1
+	}
+}
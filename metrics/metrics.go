@@ -0,0 +1,85 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+// Package metrics aggregates WriteTraces into Prometheus metrics, for
+// graphing write performance during long runs.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/Yi-Tseng/p4r-perf/p4rt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+)
+
+// WriteMetrics exports, labelled by phase (see SetPhase):
+//
+//	p4rt_write_duration_seconds   histogram of Write RPC latency
+//	p4rt_write_requests_total     Write RPCs completed
+//	p4rt_write_updates_total      updates completed, also labelled by
+//	                              canonical code
+//
+// Throughput is rate(p4rt_write_updates_total[1m]) and latency percentiles
+// are histogram_quantile over p4rt_write_duration_seconds_bucket.
+type WriteMetrics struct {
+	registry *prometheus.Registry
+	duration *prometheus.HistogramVec
+	requests *prometheus.CounterVec
+	updates  *prometheus.CounterVec
+}
+
+// NewWriteMetrics returns metrics registered on their own registry, so that
+// Handler serves only them and the Go runtime collectors.
+func NewWriteMetrics() *WriteMetrics {
+	m := &WriteMetrics{
+		registry: prometheus.NewRegistry(),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "p4rt_write_duration_seconds",
+			Help: "Latency of P4Runtime Write RPCs.",
+			// 100us to about 6.5s
+			Buckets: prometheus.ExponentialBuckets(100e-6, 2, 17),
+		}, []string{"phase"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "p4rt_write_requests_total",
+			Help: "P4Runtime Write RPCs completed.",
+		}, []string{"phase"}),
+		updates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "p4rt_write_updates_total",
+			Help: "Updates completed, by canonical code.",
+		}, []string{"phase", "code"}),
+	}
+	m.registry.MustRegister(m.duration, m.requests, m.updates,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	return m
+}
+
+// Observe adds trace to the metrics.
+func (m *WriteMetrics) Observe(trace p4rt.WriteTrace) {
+	m.duration.WithLabelValues(trace.Phase).Observe(trace.Duration.Seconds())
+	m.requests.WithLabelValues(trace.Phase).Inc()
+	if trace.ErrorCount == 0 {
+		// Every update succeeded; Errors holds one OK per update
+		m.updates.WithLabelValues(trace.Phase, codes.OK.String()).Add(float64(trace.SuccessCount))
+		return
+	}
+	for _, p4Err := range trace.Errors {
+		code := codes.Code(p4Err.GetCanonicalCode())
+		m.updates.WithLabelValues(trace.Phase, code.String()).Inc()
+	}
+}
+
+// Consume observes every trace received on traceChan until it is closed.
+func (m *WriteMetrics) Consume(traceChan <-chan p4rt.WriteTrace) {
+	for trace := range traceChan {
+		m.Observe(trace)
+	}
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *WriteMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,33 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/Yi-Tseng/p4r-perf/p4rt"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+)
+
+func TestObserveCountsUpdatesByCode(t *testing.T) {
+	m := NewWriteMetrics()
+	ok := &p4.Error{CanonicalCode: int32(codes.OK)}
+	exists := &p4.Error{CanonicalCode: int32(codes.AlreadyExists)}
+	m.Observe(p4rt.WriteTrace{Phase: "fill", BatchSize: 3, Errors: []*p4.Error{ok, ok, ok}, SuccessCount: 3})
+	m.Observe(p4rt.WriteTrace{Phase: "fill", BatchSize: 2, Errors: []*p4.Error{ok, exists}, SuccessCount: 1, ErrorCount: 1})
+	m.Observe(p4rt.WriteTrace{Phase: "fill"})
+
+	if n := testutil.ToFloat64(m.updates.WithLabelValues("fill", codes.OK.String())); n != 4 {
+		t.Errorf("%v OK updates, want 4", n)
+	}
+	if n := testutil.ToFloat64(m.updates.WithLabelValues("fill", codes.AlreadyExists.String())); n != 1 {
+		t.Errorf("%v ALREADY_EXISTS updates, want 1", n)
+	}
+	if n := testutil.ToFloat64(m.requests.WithLabelValues("fill")); n != 3 {
+		t.Errorf("%v requests, want 3", n)
+	}
+}
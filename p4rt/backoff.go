@@ -0,0 +1,49 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"time"
+)
+
+// backoff is an exponential backoff schedule: min before the first retry,
+// doubling for each further retry, up to max.
+type backoff struct {
+	min time.Duration
+	max time.Duration
+}
+
+func newBackoff(min, max time.Duration) backoff {
+	if max < min {
+		max = min
+	}
+	return backoff{min: min, max: max}
+}
+
+// delay returns the wait before attempt (counting from 0).
+func (b backoff) delay(attempt int) time.Duration {
+	d := b.min
+	for i := 0; i < attempt && d < b.max; i++ {
+		d *= 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+	return d
+}
+
+// wait sleeps for the delay before attempt. It returns ctx's error if ctx
+// is done first.
+func (b backoff) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(b.delay(attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := newBackoff(10*time.Millisecond, 50*time.Millisecond)
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for attempt, w := range want {
+		if d := b.delay(attempt); d != w*time.Millisecond {
+			t.Errorf("delay(%d) = %v, want %v", attempt, d, w*time.Millisecond)
+		}
+	}
+	// A maximum below the minimum is raised to it
+	if d := newBackoff(time.Second, 0).delay(3); d != time.Second {
+		t.Errorf("delay with max below min = %v, want %v", d, time.Second)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// BenchmarkResult describes one benchmarked phase.
+type BenchmarkResult struct {
+	Updates int            // updates submitted
+	Failed  int            // updates that did not return OK
+	Elapsed time.Duration  // wall-clock time for the whole phase
+	Latency LatencySummary // per-batch latency
+}
+
+// Throughput returns the successful updates per second over the phase.
+func (r BenchmarkResult) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Updates-r.Failed) / r.Elapsed.Seconds()
+}
+
+func (r BenchmarkResult) String() string {
+	return fmt.Sprintf("%d updates (%d failed) in %v, %.1f updates/sec, batch latency %v",
+		r.Updates, r.Failed, r.Elapsed, r.Throughput(), r.Latency)
+}
+
+// DeleteBenchmark deletes entries, which must already be installed, in
+// batches of batchSize and reports delete-only latency and throughput.
+// Up to concurrency batches are outstanding at a time; keeping it at or
+// below the client's thread count keeps client-side queueing out of the
+// measured latency.
+func DeleteBenchmark(client P4RuntimeClient, entries []*p4.TableEntry, batchSize int, concurrency int) (BenchmarkResult, error) {
+	if batchSize < 1 || concurrency < 1 {
+		return BenchmarkResult{}, fmt.Errorf("invalid batch size %d or concurrency %d", batchSize, concurrency)
+	}
+
+	var requests []*p4.WriteRequest
+	for start := 0; start < len(entries); start += batchSize {
+		end := start + batchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		requests = append(requests, tableEntryRequest(client, p4.Update_DELETE, entries[start:end]))
+	}
+
+	result := BenchmarkResult{Updates: len(entries)}
+	latencies := make([]time.Duration, len(requests))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	next := make(chan int)
+
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				submitted := time.Now()
+				errors := <-client.Write(requests[i])
+				latencies[i] = time.Since(submitted)
+				failed := countFailed(errors)
+				mu.Lock()
+				result.Failed += failed
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range requests {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+	result.Latency = SummarizeLatencies(latencies)
+	return result, nil
+}
+
+// InsertRunner inserts entries in batches, keeping up to Concurrency
+// batches outstanding, and reports insert latency and throughput. It runs
+// until Entries entries have been inserted, or for as long as
+// SetMaxDuration allows, whichever ends first.
+type InsertRunner struct {
+	Client P4RuntimeClient
+	// Entry returns the n-th entry. Distinct n must give distinct match keys.
+	Entry       func(n int) *p4.TableEntry
+	Entries     int // entries to insert; 0 for no limit, with SetMaxDuration
+	BatchSize   int
+	Concurrency int
+
+	maxDuration time.Duration
+}
+
+// SetMaxDuration bounds the whole run: once d has passed no more batches are
+// submitted, the ones in flight are waited for, and Run reports what was
+// inserted. Unlike SetWriteTimeout it does not cut short any write. Zero
+// (the default) runs until all Entries are inserted.
+func (r *InsertRunner) SetMaxDuration(d time.Duration) {
+	r.maxDuration = d
+}
+
+// Run inserts the entries. The result's Updates counts the updates
+// submitted, all of which have been answered, and its throughput is over
+// the time the run actually took, including the final drain.
+func (r *InsertRunner) Run() (BenchmarkResult, error) {
+	if r.BatchSize < 1 || r.Concurrency < 1 {
+		return BenchmarkResult{}, fmt.Errorf("invalid batch size %d or concurrency %d", r.BatchSize, r.Concurrency)
+	}
+	if r.Entries < 0 || r.maxDuration < 0 || (r.Entries == 0 && r.maxDuration == 0) {
+		return BenchmarkResult{}, fmt.Errorf("invalid run of %d entries for at most %v", r.Entries, r.maxDuration)
+	}
+
+	var result BenchmarkResult
+	var latencies []time.Duration
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	next := make(chan *p4.WriteRequest)
+
+	start := time.Now()
+	var expired <-chan time.Time
+	if r.maxDuration > 0 {
+		timer := time.NewTimer(r.maxDuration)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for w := 0; w < r.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range next {
+				submitted := time.Now()
+				errors := <-r.Client.Write(req)
+				latency := time.Since(submitted)
+				failed := countFailed(errors)
+				mu.Lock()
+				result.Failed += failed
+				latencies = append(latencies, latency)
+				mu.Unlock()
+			}
+		}()
+	}
+submit:
+	for n := 0; r.Entries == 0 || n < r.Entries; {
+		size := r.BatchSize
+		if r.Entries > 0 && r.Entries-n < size {
+			size = r.Entries - n
+		}
+		entries := make([]*p4.TableEntry, size)
+		for i := range entries {
+			entries[i] = r.Entry(n + i)
+		}
+		select {
+		case next <- tableEntryRequest(r.Client, p4.Update_INSERT, entries):
+			n += size
+			result.Updates += size
+		case <-expired:
+			// The batch waiting for a worker is not sent
+			break submit
+		}
+	}
+	close(next)
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+	result.Latency = SummarizeLatencies(latencies)
+	return result, nil
+}
+
+// tableEntryRequest builds a write request applying updateType to entries.
+func tableEntryRequest(client P4RuntimeClient, updateType p4.Update_Type, entries []*p4.TableEntry) *p4.WriteRequest {
+	updates := make([]*p4.Update, 0, len(entries))
+	for _, entry := range entries {
+		updates = append(updates, &p4.Update{
+			Type:   updateType,
+			Entity: &p4.Entity{Entity: &p4.Entity_TableEntry{TableEntry: entry}},
+		})
+	}
+	return &p4.WriteRequest{
+		DeviceId:   client.DeviceID(),
+		ElectionId: client.ElectionID(),
+		Updates:    updates,
+	}
+}
+
+// countFailed returns the number of errors with a non-OK canonical code.
+func countFailed(errors []*p4.Error) int {
+	failed := 0
+	for _, p4Err := range errors {
+		if p4Err.GetCanonicalCode() != int32(codes.OK) {
+			failed++
+		}
+	}
+	return failed
+}
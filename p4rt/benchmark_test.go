@@ -0,0 +1,64 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"testing"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func newInsertRunner(c *p4rtClient, entries int) *InsertRunner {
+	return &InsertRunner{
+		Client: c,
+		Entry: func(n int) *p4.TableEntry {
+			return &p4.TableEntry{TableId: 1, Match: []*p4.FieldMatch{{
+				FieldId:        1,
+				FieldMatchType: &p4.FieldMatch_Exact_{Exact: &p4.FieldMatch_Exact{Value: []byte{byte(n >> 16), byte(n >> 8), byte(n)}}},
+			}}}
+		},
+		Entries:     entries,
+		BatchSize:   10,
+		Concurrency: 2,
+	}
+}
+
+func TestInsertRunner(t *testing.T) {
+	c, fake := newTestClient(t, 10, 2)
+	result, err := newInsertRunner(c, 95).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Updates != 95 || result.Failed != 0 || result.Latency.Count != 10 {
+		t.Errorf("inserted %d entries (%d failed) in %d batches, want 95 in 10", result.Updates, result.Failed, result.Latency.Count)
+	}
+	if _, updates := fake.counts(); updates != 95 {
+		t.Errorf("switch got %d updates, want 95", updates)
+	}
+}
+
+// TestInsertRunnerMaxDuration checks that the run stops at its maximum
+// duration, with everything submitted answered, and that its throughput is
+// over the time actually taken.
+func TestInsertRunnerMaxDuration(t *testing.T) {
+	c, fake := newTestClient(t, 10, 2)
+	c.SetWriteRate(1000, 10)
+	runner := newInsertRunner(c, 0)
+	runner.SetMaxDuration(100 * time.Millisecond)
+	result, err := runner.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Elapsed < 100*time.Millisecond || result.Elapsed > time.Second {
+		t.Errorf("run took %v, want just over 100ms", result.Elapsed)
+	}
+	if _, updates := fake.counts(); updates != result.Updates || result.Updates == 0 {
+		t.Errorf("switch got %d updates, run reported %d", updates, result.Updates)
+	}
+	if want := float64(result.Updates) / result.Elapsed.Seconds(); result.Throughput() != want {
+		t.Errorf("throughput %v, want %v", result.Throughput(), want)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+
+	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// GroupMember is a member of an action profile group and its weight. A
+// zero weight is sent as 1.
+type GroupMember struct {
+	ID     uint32
+	Weight int32
+}
+
+// ActionProfileMember builds member memberID of profileName, running
+// actionName with text parameters parsed with EncodeValue. The action must
+// be usable by entries of a table implemented by the profile.
+func (p4infoHelper *P4InfoHelper) ActionProfileMember(profileName string, memberID uint32,
+	actionName string, params map[string]string) (*p4.ActionProfileMember, error) {
+	profile, err := p4infoHelper.GetActionProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	action, err := p4infoHelper.GetAction(actionName)
+	if err != nil {
+		return nil, err
+	}
+	if !p4infoHelper.profileAllowsAction(profile, action.GetPreamble().GetId()) {
+		return nil, fmt.Errorf("action %s is not an action of a table implemented by action profile %s",
+			actionName, profileName)
+	}
+	p4Action, err := buildAction(action, params)
+	if err != nil {
+		return nil, err
+	}
+	return &p4.ActionProfileMember{
+		ActionProfileId: profile.GetPreamble().GetId(),
+		MemberId:        memberID,
+		Action:          p4Action,
+	}, nil
+}
+
+func (p4infoHelper *P4InfoHelper) profileAllowsAction(profile *p4_config.ActionProfile, actionID uint32) bool {
+	for _, tableID := range profile.GetTableIds() {
+		for _, table := range p4infoHelper.tables {
+			if table.GetPreamble().GetId() != tableID {
+				continue
+			}
+			for _, ref := range table.GetActionRefs() {
+				if ref.GetId() == actionID && ref.GetScope() != p4_config.ActionRef_DEFAULT_ONLY {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ActionProfileGroup builds group groupID of profileName, which must have a
+// selector. The total weight of members must fit the profile's maximum
+// group size, if the P4Info declares one.
+func (p4infoHelper *P4InfoHelper) ActionProfileGroup(profileName string, groupID uint32,
+	members []GroupMember) (*p4.ActionProfileGroup, error) {
+	profile, err := p4infoHelper.GetActionProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	if !profile.GetWithSelector() {
+		return nil, fmt.Errorf("action profile %s has no selector, so it cannot have groups", profileName)
+	}
+	group := &p4.ActionProfileGroup{
+		ActionProfileId: profile.GetPreamble().GetId(),
+		GroupId:         groupID,
+	}
+	totalWeight := int32(0)
+	for _, m := range members {
+		weight := m.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("invalid weight %d for member %d", m.Weight, m.ID)
+		}
+		totalWeight += weight
+		group.Members = append(group.Members, &p4.ActionProfileGroup_Member{MemberId: m.ID, Weight: weight})
+	}
+	if max := profile.GetMaxGroupSize(); max > 0 && totalWeight > max {
+		return nil, fmt.Errorf("group %d has total weight %d, more than the maximum group size %d of action profile %s",
+			groupID, totalWeight, max, profileName)
+	}
+	return group, nil
+}
+
+// TableEntryForMember builds an entry of tableName, matched as in
+// TableEntry, whose action is member memberID of the table's action profile.
+func (p4infoHelper *P4InfoHelper) TableEntryForMember(tableName string, match map[string]MatchValue,
+	priority int32, memberID uint32) (*p4.TableEntry, error) {
+	entry, err := p4infoHelper.indirectEntry(tableName, match, priority)
+	if err != nil {
+		return nil, err
+	}
+	entry.Action = &p4.TableAction{Type: &p4.TableAction_ActionProfileMemberId{ActionProfileMemberId: memberID}}
+	return entry, nil
+}
+
+// TableEntryForGroup builds an entry of tableName, matched as in
+// TableEntry, whose action is group groupID of the table's action profile.
+func (p4infoHelper *P4InfoHelper) TableEntryForGroup(tableName string, match map[string]MatchValue,
+	priority int32, groupID uint32) (*p4.TableEntry, error) {
+	entry, err := p4infoHelper.indirectEntry(tableName, match, priority)
+	if err != nil {
+		return nil, err
+	}
+	entry.Action = &p4.TableAction{Type: &p4.TableAction_ActionProfileGroupId{ActionProfileGroupId: groupID}}
+	return entry, nil
+}
+
+func (p4infoHelper *P4InfoHelper) indirectEntry(tableName string, match map[string]MatchValue,
+	priority int32) (*p4.TableEntry, error) {
+	table, err := p4infoHelper.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if table.GetImplementationId() == 0 {
+		return nil, fmt.Errorf("table %s is not implemented by an action profile", tableName)
+	}
+	entry := &p4.TableEntry{TableId: table.GetPreamble().GetId(), Priority: priority}
+	if entry.Match, err = buildMatch(table, match); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// MeterEntry builds the entry at index of the indirect meter meterName.
+// Rates and bursts are in the meter's unit (bytes or packets).
+func (p4infoHelper *P4InfoHelper) MeterEntry(meterName string, index int64, config *p4.MeterConfig) (*p4.MeterEntry, error) {
+	meter, err := p4infoHelper.GetMeter(meterName)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= meter.GetSize() {
+		return nil, fmt.Errorf("index %d is out of range for meter %s of size %d", index, meterName, meter.GetSize())
+	}
+	return &p4.MeterEntry{
+		MeterId: meter.GetPreamble().GetId(),
+		Index:   &p4.Index{Index: index},
+		Config:  config,
+	}, nil
+}
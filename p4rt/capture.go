@@ -0,0 +1,257 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// A capture file is a sequence of CapturedWrite messages (capture.proto),
+// each preceded by its length as a varint.
+const (
+	captureFieldTime    = 1
+	captureFieldRequest = 2
+)
+
+// CapturedWrite is one WriteRequest of a capture and when it was submitted.
+type CapturedWrite struct {
+	Time    time.Time
+	Request *p4.WriteRequest
+}
+
+// WriteRecorder appends every write submitted through a client to a
+// capture file; see SetWriteRecorder. It is safe for concurrent use.
+type WriteRecorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	count   int
+	err     error
+	scratch []byte
+}
+
+// NewWriteRecorder creates, or truncates, the capture file at path.
+func NewWriteRecorder(path string) (*WriteRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &WriteRecorder{file: file, w: bufio.NewWriter(file)}, nil
+}
+
+// Record appends req, submitted at t. After the first error nothing more
+// is recorded and Record returns that error.
+func (r *WriteRecorder) Record(t time.Time, req *p4.WriteRequest) error {
+	raw, err := proto.Marshal(req)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	if err != nil {
+		r.err = errors.Wrap(err, "error marshaling captured WriteRequest")
+		return r.err
+	}
+	b := r.scratch[:0]
+	b = protowire.AppendTag(b, captureFieldTime, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(t.UnixNano()))
+	b = protowire.AppendTag(b, captureFieldRequest, protowire.BytesType)
+	b = protowire.AppendBytes(b, raw)
+	r.scratch = b
+	if _, err := r.w.Write(protowire.AppendVarint(nil, uint64(len(b)))); err != nil {
+		r.err = err
+		return err
+	}
+	if _, err := r.w.Write(b); err != nil {
+		r.err = err
+		return err
+	}
+	r.count++
+	return nil
+}
+
+// Count returns the number of writes recorded.
+func (r *WriteRecorder) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+// Err returns the error that stopped recording, if any.
+func (r *WriteRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close flushes and closes the capture file. It returns the first error
+// met while recording or closing.
+func (r *WriteRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if r.err == nil {
+		// Record after Close fails instead of writing to a closed file
+		r.err = fmt.Errorf("write recorder is closed")
+		return nil
+	}
+	return r.err
+}
+
+// SetWriteRecorder records every write the client accepts from now on, as
+// the caller submitted it. Passing nil stops recording; the caller still
+// closes the recorder.
+func (c *p4rtClient) SetWriteRecorder(r *WriteRecorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeRecorder = r
+}
+
+func (c *p4rtClient) recordWrite(req *p4.WriteRequest) {
+	c.mu.RLock()
+	r := c.writeRecorder
+	c.mu.RUnlock()
+	if r != nil {
+		// A failed recorder keeps its error for Err
+		r.Record(time.Now(), req)
+	}
+}
+
+// LoadCapture reads the capture file written by a WriteRecorder at path.
+func LoadCapture(path string) ([]CapturedWrite, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+
+	var writes []CapturedWrite
+	for {
+		size, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return writes, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: record %d: %v", path, len(writes)+1, err)
+		}
+		record := make([]byte, size)
+		if _, err := io.ReadFull(reader, record); err != nil {
+			return nil, fmt.Errorf("%s: record %d is truncated: %v", path, len(writes)+1, err)
+		}
+		write, err := decodeCapturedWrite(record)
+		if err != nil {
+			return nil, fmt.Errorf("%s: record %d: %v", path, len(writes)+1, err)
+		}
+		writes = append(writes, write)
+	}
+}
+
+func decodeCapturedWrite(b []byte) (CapturedWrite, error) {
+	write := CapturedWrite{Request: &p4.WriteRequest{}}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return write, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch {
+		case num == captureFieldTime && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return write, protowire.ParseError(n)
+			}
+			write.Time = time.Unix(0, int64(v))
+			b = b[n:]
+		case num == captureFieldRequest && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return write, protowire.ParseError(n)
+			}
+			if err := proto.Unmarshal(v, write.Request); err != nil {
+				return write, err
+			}
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return write, protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return write, nil
+}
+
+// ReplayCapture resends captured writes through this client, rewritten for
+// its device ID and election ID, without waiting for earlier writes to be
+// answered. With speedup 0 they go out as fast as the client takes them;
+// otherwise they keep the captured gaps between submissions, divided by
+// speedup, so 1 is the original timing. Latency is from submission to
+// response.
+func (c *p4rtClient) ReplayCapture(writes []CapturedWrite, speedup float64) (BenchmarkResult, error) {
+	if speedup < 0 {
+		return BenchmarkResult{}, fmt.Errorf("invalid replay speedup %v", speedup)
+	}
+	return c.replayOpenLoop(len(writes), func(i int, start time.Time) (*p4.WriteRequest, time.Time) {
+		write := writes[i]
+		if speedup > 0 && i > 0 {
+			offset := time.Duration(float64(write.Time.Sub(writes[0].Time)) / speedup)
+			if wait := time.Until(start.Add(offset)); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		req := proto.Clone(write.Request).(*p4.WriteRequest)
+		req.DeviceId = c.deviceID
+		req.ElectionId = c.ElectionID()
+		return req, time.Now()
+	}), nil
+}
+
+// replayOpenLoop writes n requests in order without waiting for earlier
+// ones to be answered. next returns the ith request, given when the replay
+// started, and the time its latency is measured from; it may sleep to time
+// the submission. The result's Elapsed runs until the last response.
+func (c *p4rtClient) replayOpenLoop(n int, next func(i int, start time.Time) (*p4.WriteRequest, time.Time)) BenchmarkResult {
+	var result BenchmarkResult
+	latencies := make([]time.Duration, n)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		req, sent := next(i, start)
+		res := c.Write(req)
+		result.Updates += len(req.GetUpdates())
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errors := <-res
+			latencies[i] = time.Since(sent)
+			mu.Lock()
+			result.Failed += countFailed(errors)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+	result.Latency = SummarizeLatencies(latencies)
+	return result
+}
@@ -0,0 +1,54 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"testing"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// TestReplayCapture checks that captured writes are rewritten for the
+// replaying client and keep their gaps, divided by the speedup.
+func TestReplayCapture(t *testing.T) {
+	c, fake := newTestClient(t, 10, 1)
+	var seen []*p4.WriteRequest
+	fake.setFail(func(n int, req *p4.WriteRequest) error {
+		seen = append(seen, req)
+		return nil
+	})
+	captured := time.Unix(1000, 0)
+	var writes []CapturedWrite
+	for i := 0; i < 3; i++ {
+		req := insertRequest(c, 5)
+		req.DeviceId, req.ElectionId = 99, &p4.Uint128{Low: 99}
+		writes = append(writes, CapturedWrite{Time: captured.Add(time.Duration(i) * 100 * time.Millisecond), Request: req})
+	}
+	result, err := c.ReplayCapture(writes, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Updates != 15 || result.Failed != 0 {
+		t.Errorf("replayed %d updates with %d failed, want 15 and 0", result.Updates, result.Failed)
+	}
+	// The captured 200ms between first and last write, halved
+	if result.Elapsed < 100*time.Millisecond {
+		t.Errorf("replay took %v, want at least 100ms", result.Elapsed)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(seen) != 3 {
+		t.Fatalf("switch got %d writes, want 3", len(seen))
+	}
+	for i, req := range seen {
+		if req.GetDeviceId() != c.deviceID || req.GetElectionId().GetLow() != c.ElectionID().GetLow() {
+			t.Errorf("write %d went to device %d with election ID %v", i, req.GetDeviceId(), req.GetElectionId())
+		}
+	}
+	if writes[0].Request.GetDeviceId() != 99 {
+		t.Error("replay changed the captured request")
+	}
+}
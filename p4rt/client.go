@@ -6,20 +6,105 @@ package p4rt
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc/codes"
 )
 
 var p4rtClients = make(map[p4rtClientKey]P4RuntimeClient)
+var p4rtClientsMu sync.Mutex
 
 type P4RuntimeClient interface {
+	Ping(ctx context.Context) error
+	WaitForReady(ctx context.Context) error
+	PingRTT(ctx context.Context, samples int) (RTTStats, error)
 	SetMastership(electionID p4.Uint128) error
+	Arbitrate(electionID p4.Uint128, timeout time.Duration) error
+	SetArbitrationOptional(optional bool)
+	SetRole(roleID uint64)
+	SetMastershipChan(ch chan MastershipChange)
+	IsPrimary() bool
+	SetQueueWritesUntilPrimary(queue bool)
 	GetForwardingPipelineConfig() (*p4.ForwardingPipelineConfig, error)
+	FetchServerInfo(ctx context.Context) (ServerInfo, error)
 	SetForwardingPipelineConfig(p4InfoPath, deviceConfigPath string) error
+	PushPipelineConfig(p4InfoPath, deviceConfigPath string, action p4.SetForwardingPipelineConfigRequest_Action) error
+	PipelineReconfigBenchmark(pipelines []PipelinePaths, iterations int) (LatencySummary, error)
+	SetP4Info(p4info *P4InfoHelper)
+	ExerciseAllActions(tableName string) ([]*p4.Error, error)
+	CountEntries(tableName string) (int, CountMethod, error)
+	TableOccupancy(tableName string) (used int, max int, err error)
+	SetDefaultAction(tableName string, actionName string, params map[string][]byte) <-chan []*p4.Error
+	ResetCounters(counterName string) error
+	CounterRateSampler(counterName string, interval time.Duration) (*CounterRateSampler, error)
+	WriteValueSet(valueSetName string, members []ValueSetMember) <-chan []*p4.Error
+	ReadValueSet(valueSetName string) ([]ValueSetMember, error)
 	Write(req *p4.WriteRequest) <-chan []*p4.Error
+	WriteContext(ctx context.Context, req *p4.WriteRequest) <-chan []*p4.Error
+	Shutdown(ctx context.Context) error
+	Close() error
+	NewUpdateBatcher(maxBatch int, maxDelay time.Duration) *UpdateBatcher
+	WriteWithTranscript(req *p4.WriteRequest) (WriteResult, Transcript)
+	ReplayAtRate(requests []*p4.WriteRequest, updatesPerSec int) (BenchmarkResult, error)
+	ReplayCapture(writes []CapturedWrite, speedup float64) (BenchmarkResult, error)
+	SetWriteRecorder(r *WriteRecorder)
+	SetWriteRate(updatesPerSecond float64, burst int)
+	SetRateLimitFor(label string, updatesPerSec int)
+	WriteLabeled(label string, req *p4.WriteRequest) <-chan []*p4.Error
+	SetWritePipelines(n int) error
+	WriteOrdered(key uint64, req *p4.WriteRequest) <-chan []*p4.Error
+	SetMaxInFlightBytes(n int64)
+	CancelPending() int
+	BeginTx() error
+	TxWrite(req *p4.WriteRequest) <-chan []*p4.Error
+	CommitTx() <-chan []*p4.Error
+	AbortTx()
+	SetContext(ctx context.Context)
+	Read(req *p4.ReadRequest) (<-chan *p4.ReadResponse, <-chan error)
+	ReadCancelable(ctx context.Context, req *p4.ReadRequest) (<-chan *p4.ReadResponse, func(), <-chan error)
+	ReadWithCallback(req *p4.ReadRequest, fn func(*p4.ReadResponse) error) error
+	ReadIterator(ctx context.Context, req *p4.ReadRequest) *EntityIterator
+	SetReadChannelDepth(n int)
+	SetReadTimeout(d time.Duration)
+	SetWriteTimeout(d time.Duration)
+	TablePager(tableName string, pageSize int) (*Pager, error)
+	ReadTableSnapshot(tableName string) (*TableSnapshot, error)
+	DiffSince(snapshot *TableSnapshot) (TableDiff, error)
+	ReadMulticastGroup(groupID uint32) (*p4.MulticastGroupEntry, error)
+	ReadAllMulticastGroups() ([]*p4.MulticastGroupEntry, error)
+	ReadCloneSession(sessionID uint32) (*p4.CloneSessionEntry, error)
+	ReadAllCloneSessions() ([]*p4.CloneSessionEntry, error)
+	SetBatchSize(n int)
+	SetRetryableCodes(retryCodes ...codes.Code)
+	SetRetryPolicy(policy RetryPolicy)
+	SetReadTraceChan(traceChan chan ReadTrace)
+	ReadCounters(counterName string) ([]*p4.CounterEntry, error)
+	ReadDirectCounters(tableName string) ([]*p4.DirectCounterEntry, error)
+	WriteMeterEntries(entries []*p4.MeterEntry) []*p4.Error
+	WriteDirectMeterEntries(entries []*p4.DirectMeterEntry) []*p4.Error
+	SetIgnoreCodesOnDelete(ignore ...codes.Code)
 	SetWriteTraceChan(traceChan chan WriteTrace)
+	SetPhase(name string)
+	SetTraceBuffer(soft, hard int)
+	SetWriteTraceBatchChan(ch chan []WriteTrace, batchSize int, maxDelay time.Duration)
+	SetTraceIncludeRequest(include bool)
+	PacketIn() <-chan *p4.PacketIn
+	SetPacketInTraceChan(ch chan PacketInTrace)
+	SetReconnectPolicy(policy ReconnectPolicy)
+	SendPacketOut(payload []byte, metadata map[string][]byte) (time.Time, error)
+	SubscribeDigest(digestName string, opts DigestOptions) (*DigestSubscription, error)
+	AckDigestList(list *p4.DigestList) error
+	SetDigestTraceChan(ch chan DigestTrace)
+	Stats() Stats
+	SetTracerProvider(tp trace.TracerProvider)
+	SetWireTimeCapture(capture bool)
 	DeviceID() uint64
 	ElectionID() *p4.Uint128
 }
@@ -29,57 +114,285 @@ type p4rtClientKey struct {
 	deviceID uint64
 }
 
+// p4rtClient is safe for concurrent use. Fields set after Init by the Set*
+// methods are guarded by mu; writers take the lock and the write and read
+// paths take a snapshot under the read lock.
 type p4rtClient struct {
-	client         p4.P4RuntimeClient
-	stream         p4.P4Runtime_StreamChannelClient
-	deviceID       uint64
-	electionID     p4.Uint128
-	writes         chan p4Write
-	writeTraceChan chan WriteTrace
-	batchSize      int
-	numThreads     int
+	host         string
+	client       p4.P4RuntimeClient
+	stream       p4.P4Runtime_StreamChannelClient
+	streamSendMu sync.Mutex // gRPC streams do not allow concurrent Send
+	// reconnectCancel ends stream once installStream has replaced the first
+	// one; guarded by streamSendMu
+	reconnectCancel context.CancelFunc
+	deviceID        uint64
+	writes          chan p4Write
+	numThreads      int
+	writersDone     chan struct{}  // closed by Shutdown to stop the write threads
+	writers         sync.WaitGroup // write threads still running
+	arbitrations    chan *p4.MasterArbitrationUpdate
+	streamDone      chan struct{}
+	streamErr       error
+	streamCtx       context.Context
+	streamCancel    context.CancelFunc
+	packetIns       chan *p4.PacketIn
+	txMu            sync.Mutex
+	tx              *writeTx // open transaction, guarded by txMu
+
+	flightMu         sync.Mutex
+	flightCond       *sync.Cond // signalled when in-flight bytes drop
+	maxInFlightBytes int64      // guarded by flightMu
+	inFlightBytes    int64      // guarded by flightMu
+	unanswered       int64      // writes accepted but not yet answered, guarded by flightMu
+	draining         bool       // Shutdown accepts no more writes, guarded by flightMu
+
+	digestMu       sync.Mutex
+	digestSubs     map[uint32]*DigestSubscription // by digest ID, guarded by digestMu
+	digestReceived map[digestListKey]time.Time    // lists awaiting an explicit ack, guarded by digestMu
+
+	pacer      pacer
+	throughput writeThroughput // across all pipelines
+
+	mu                  sync.RWMutex
+	batchSize           int
+	electionID          p4.Uint128
+	writeTraceChan      chan WriteTrace
+	writeTraceChanSet   chan struct{} // closed when writeTraceChan is replaced
+	tracer              trace.Tracer
+	readChannelDepth    int
+	p4info              *P4InfoHelper
+	traceBuffer         *traceBuffer
+	traceBatcher        *traceBatcher
+	traceIncludeRequest bool
+	retryPolicy         *retryState // nil when per-update retries are off
+	arbitrationDuration time.Duration
+	arbitrationOptional bool
+	wireTimeCapture     bool
+	phase               string
+	readTimeout         time.Duration
+	writeTimeout        time.Duration
+	ignoreCodesOnDelete map[codes.Code]bool
+	ctx                 context.Context
+	cancelCtx           context.CancelFunc
+	shutDown            bool
+	roleID              uint64
+	primary             bool
+	becamePrimary       chan struct{} // closed while primary
+	queueUntilPrimary   bool
+	mastershipChan      chan MastershipChange
+	packetInTraceChan   chan PacketInTrace
+	arbitrated          bool // SetMastership has been called
+	reconnect           ReconnectPolicy
+	streamUp            chan struct{} // closed while the stream is connected
+	pipelines           []*writePipeline
+	writeRecorder       *WriteRecorder
+	readTraceChan       chan ReadTrace
+	digestTraceChan     chan DigestTrace
+	labelPacers         map[string]*pacer // by WriteLabeled label
+
+	reconnectFailures int // consecutive failed reconnects, used only by the stream receiver
+
+	tracesDropped     uint64 // accessed atomically
+	packetInsDropped  uint64 // accessed atomically
+	deletesIgnored    uint64 // accessed atomically
+	reconnects        uint64 // accessed atomically
+	writesReplayed    uint64 // accessed atomically
+	updateRetries     uint64 // accessed atomically
+	readTracesDropped uint64 // accessed atomically
+
+	digestListsReceived uint64 // accessed atomically
+	digestListsDropped  uint64 // accessed atomically
+	digestTracesDropped uint64 // accessed atomically
+}
+
+// Stats is a snapshot of client-side counters.
+type Stats struct {
+	// TracesDropped counts write traces discarded because the trace
+	// channel, trace buffer or trace batch channel was full.
+	TracesDropped uint64
+	// PacketInsDropped counts packet-ins discarded because the PacketIn
+	// channel or the packet-in trace channel was full.
+	PacketInsDropped uint64
+	// DeleteErrorsIgnored counts DELETE updates whose error was turned into
+	// success by SetIgnoreCodesOnDelete.
+	DeleteErrorsIgnored uint64
+	// TraceBufferHighWater is the largest number of traces held by the
+	// trace buffer at once. It is zero if SetTraceBuffer was not called.
+	TraceBufferHighWater int
+	// Reconnects counts the times the stream channel was reopened after
+	// breaking; see SetReconnectPolicy.
+	Reconnects uint64
+	// WritesReplayed counts Write RPCs resent after failing with
+	// codes.Unavailable.
+	WritesReplayed uint64
+	// UpdateRetries counts updates resent by SetRetryPolicy, once per
+	// retry.
+	UpdateRetries uint64
+	// ReadTracesDropped counts read traces discarded because the read
+	// trace channel was full.
+	ReadTracesDropped uint64
+	// DigestListsReceived counts DigestLists from the switch, and
+	// DigestListsDropped those discarded because no subscription wanted
+	// them or its channel was full.
+	DigestListsReceived uint64
+	DigestListsDropped  uint64
+	// DigestTracesDropped counts digest traces discarded because the
+	// digest trace channel was full.
+	DigestTracesDropped uint64
+	// Pipelines has the traffic of each write pipeline, by index; see
+	// SetWritePipelines.
+	Pipelines []PipelineStats
+	// Setup is how long connection setup took.
+	Setup SetupTimings
+}
+
+// SetupTimings breaks down the cost of bringing up a client. A duration is
+// zero if that step has not completed.
+type SetupTimings struct {
+	// Connect is the time from dialing until the gRPC connection was first
+	// ready, including the TLS handshake if SetClientOptions turned TLS on.
+	// The connection is shared by all clients of the same address.
+	Connect time.Duration
+	// Arbitration is the time from sending the last MasterArbitrationUpdate
+	// in Arbitrate until the switch answered.
+	Arbitration time.Duration
 }
 
 func (c *p4rtClient) Init() (err error) {
 	// Initialize stream for mastership and packet I/O
-	c.stream, err = c.client.StreamChannel(context.Background())
+	c.streamCtx, c.streamCancel = context.WithCancel(context.Background())
+	c.stream, err = c.client.StreamChannel(c.streamCtx)
 	if err != nil {
+		c.streamCancel()
 		return
 	}
-	go func() {
-		for {
-			res, err := c.stream.Recv()
-			if err != nil {
-				fmt.Printf("stream recv error: %v\n", err)
-			} else if arb := res.GetArbitration(); arb != nil {
-				if code.Code(arb.Status.Code) == code.Code_OK {
-					fmt.Println("client is master")
-				} else {
-					fmt.Println("client is not master")
-				}
-			} else {
-				fmt.Printf("stream recv: %v\n", res)
-			}
-
-		}
-	}()
+	c.arbitrations = make(chan *p4.MasterArbitrationUpdate, 1)
+	c.becamePrimary = make(chan struct{})
+	c.streamDone = make(chan struct{})
+	c.streamUp = make(chan struct{})
+	close(c.streamUp)
+	c.packetIns = make(chan *p4.PacketIn, packetInChannelDepth)
+	c.flightCond = sync.NewCond(&c.flightMu)
+	go c.receiveStreamMessages()
 
+	// The write queue is sized once, from the batch size given at construction
 	var writeBufferSize = c.batchSize * c.numThreads * 10
 	// Initialize Write thread
 	c.writes = make(chan p4Write, writeBufferSize)
-	for i := 0; i < c.numThreads; i++ {
-		go c.ListenForWrites()
-	}
+	c.writersDone = make(chan struct{})
+	c.pipelines = []*writePipeline{newWritePipeline(0, c.client, nil, writeBufferSize)}
+	c.startWritePipeline(c.pipelines[0])
 
 	return
 }
 
+func (c *p4rtClient) receiveStreamMessages() {
+	defer close(c.streamDone)
+	defer close(c.packetIns)
+	defer c.closeDigestSubscriptions()
+	stream := c.stream
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			fmt.Printf("stream recv error: %v\n", err)
+			if stream = c.reconnectStream(err); stream == nil {
+				c.streamErr = err
+				return
+			}
+			continue
+		}
+		c.reconnectFailures = 0
+		if arb := res.GetArbitration(); arb != nil {
+			if code.Code(arb.GetStatus().GetCode()) == code.Code_OK {
+				fmt.Println("client is master")
+			} else {
+				fmt.Printf("client is not master; primary election ID is %v\n", arb.GetElectionId())
+			}
+			c.updateMastership(arb)
+			// Hand the update to Arbitrate, if it is waiting; never block the stream
+			select {
+			case c.arbitrations <- arb:
+			default:
+			}
+		} else if packet := res.GetPacket(); packet != nil {
+			c.tracePacketIn(time.Now(), packet)
+			// Never block the stream on a slow packet-in consumer either
+			select {
+			case c.packetIns <- packet:
+			default:
+				atomic.AddUint64(&c.packetInsDropped, 1)
+			}
+		} else if digest := res.GetDigest(); digest != nil {
+			c.handleDigestList(time.Now(), digest)
+		} else {
+			fmt.Printf("stream recv: %v\n", res)
+		}
+	}
+}
+
+// SetP4Info sets the P4Info used to resolve names in helpers such as
+// SetDefaultAction. SetForwardingPipelineConfig sets it automatically.
+func (c *p4rtClient) SetP4Info(p4info *P4InfoHelper) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.p4info = p4info
+}
+
+func (c *p4rtClient) p4infoHelper() (*P4InfoHelper, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.p4info == nil {
+		return nil, errors.New("no P4Info loaded; set a pipeline config or call SetP4Info first")
+	}
+	return c.p4info, nil
+}
+
+func (c *p4rtClient) Stats() Stats {
+	stats := Stats{
+		TracesDropped:       atomic.LoadUint64(&c.tracesDropped),
+		PacketInsDropped:    atomic.LoadUint64(&c.packetInsDropped),
+		DeleteErrorsIgnored: atomic.LoadUint64(&c.deletesIgnored),
+		Reconnects:          atomic.LoadUint64(&c.reconnects),
+		WritesReplayed:      atomic.LoadUint64(&c.writesReplayed),
+		UpdateRetries:       atomic.LoadUint64(&c.updateRetries),
+		ReadTracesDropped:   atomic.LoadUint64(&c.readTracesDropped),
+		DigestListsReceived: atomic.LoadUint64(&c.digestListsReceived),
+		DigestListsDropped:  atomic.LoadUint64(&c.digestListsDropped),
+		DigestTracesDropped: atomic.LoadUint64(&c.digestTracesDropped),
+	}
+	stats.Setup.Connect, _ = ConnectDuration(c.host)
+	c.mu.RLock()
+	buffer := c.traceBuffer
+	stats.Setup.Arbitration = c.arbitrationDuration
+	c.mu.RUnlock()
+	for _, pipeline := range c.writePipelines() {
+		stats.Pipelines = append(stats.Pipelines, pipeline.throughput.stats())
+	}
+	if buffer != nil {
+		highWater, dropped := buffer.stats()
+		stats.TraceBufferHighWater = highWater
+		stats.TracesDropped += dropped
+	}
+	return stats
+}
+
+// PacketIn returns the channel of packet-ins received on the stream. It holds
+// up to packetInChannelDepth packets; further packets are dropped until it is
+// drained. The channel is closed when the stream ends.
+func (c *p4rtClient) PacketIn() <-chan *p4.PacketIn {
+	return c.packetIns
+}
+
 func (c *p4rtClient) DeviceID() uint64 {
 	return c.deviceID
 }
 
+// ElectionID returns a copy of the current election ID.
 func (c *p4rtClient) ElectionID() *p4.Uint128 {
-	return &c.electionID
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	electionID := c.electionID
+	return &electionID
 }
 
 func CreateOrGetP4RuntimeClient(host string, deviceID uint64, batchSize int, numThreads int) (P4RuntimeClient, error) {
@@ -88,26 +401,42 @@ func CreateOrGetP4RuntimeClient(host string, deviceID uint64, batchSize int, num
 		deviceID: deviceID,
 	}
 
+	p4rtClientsMu.Lock()
+	defer p4rtClientsMu.Unlock()
+
 	// First, return a P4RT client if one exists
 	if p4rtClient, ok := p4rtClients[key]; ok {
 		return p4rtClient, nil
 	}
 
 	// Second, check to see if we can reuse the gRPC connection for a new P4RT client
+	client, err := newP4RuntimeClient(host, deviceID, batchSize, numThreads)
+	if err != nil {
+		return nil, err
+	}
+	p4rtClients[key] = client
+	return client, nil
+}
+
+// newP4RuntimeClient returns a new client of deviceID, with its own stream,
+// that is not cached. It shares the gRPC connection to host.
+func newP4RuntimeClient(host string, deviceID uint64, batchSize int, numThreads int) (*p4rtClient, error) {
 	conn, err := GetConnection(host)
 	if err != nil {
 		return nil, err
 	}
 	client := &p4rtClient{
-		client:     p4.NewP4RuntimeClient(conn),
-		deviceID:   deviceID,
-		batchSize:  batchSize,
-		numThreads: numThreads,
+		host:             host,
+		client:           p4.NewP4RuntimeClient(conn),
+		deviceID:         deviceID,
+		batchSize:        batchSize,
+		numThreads:       numThreads,
+		readChannelDepth: defaultReadChannelDepth,
 	}
+	client.SetContext(context.Background())
 	err = client.Init()
 	if err != nil {
 		return nil, err
 	}
-	p4rtClients[key] = client
 	return client, nil
 }
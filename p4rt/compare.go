@@ -0,0 +1,97 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultRegressionThreshold is the relative change CompareRuns flags as a
+// regression: 10% worse.
+const DefaultRegressionThreshold = 0.10
+
+// MetricDelta compares one metric of two runs. Change is relative to
+// Before, positive when the metric grew; going from zero to any non-zero
+// value counts as +100%. Latencies are in nanoseconds, throughput in
+// updates per second and the error rate a fraction of updates.
+type MetricDelta struct {
+	Name       string  `json:"name"`
+	Unit       string  `json:"unit"`
+	Before     float64 `json:"before"`
+	After      float64 `json:"after"`
+	Change     float64 `json:"change"`
+	Regression bool    `json:"regression"`
+}
+
+// ComparisonReport is the difference between a baseline run and a new one.
+// It marshals to JSON for CI gating on Regression.
+type ComparisonReport struct {
+	Threshold  float64       `json:"threshold"`
+	Metrics    []MetricDelta `json:"metrics"`
+	Regression bool          `json:"regression"` // any metric regressed
+}
+
+// CompareRuns compares run b against baseline a, flagging changes for the
+// worse beyond DefaultRegressionThreshold.
+func CompareRuns(a, b RunSummary) ComparisonReport {
+	return CompareRunsThreshold(a, b, DefaultRegressionThreshold)
+}
+
+// CompareRunsThreshold is CompareRuns with its own threshold: a metric
+// regresses if it gets worse by more than threshold relative to a. Higher
+// latency and error rate are worse, and lower throughput.
+func CompareRunsThreshold(a, b RunSummary, threshold float64) ComparisonReport {
+	report := ComparisonReport{Threshold: threshold}
+	add := func(name, unit string, before, after float64, higherIsWorse bool) {
+		delta := MetricDelta{Name: name, Unit: unit, Before: before, After: after}
+		switch {
+		case before != 0:
+			delta.Change = (after - before) / before
+		case after > 0:
+			delta.Change = 1
+		}
+		worse := delta.Change
+		if !higherIsWorse {
+			worse = -worse
+		}
+		delta.Regression = worse > threshold
+		report.Regression = report.Regression || delta.Regression
+		report.Metrics = append(report.Metrics, delta)
+	}
+	add("p50_latency", "ns", float64(a.Latency.P50), float64(b.Latency.P50), true)
+	add("p99_latency", "ns", float64(a.Latency.P99), float64(b.Latency.P99), true)
+	add("throughput", "updates/s", a.Throughput, b.Throughput, false)
+	add("error_rate", "fraction", a.ErrorRate(), b.ErrorRate(), true)
+	return report
+}
+
+func (r ComparisonReport) String() string {
+	var b strings.Builder
+	for _, m := range r.Metrics {
+		fmt.Fprintf(&b, "%-12s %12s -> %-12s %+7.1f%%", m.Name, m.format(m.Before), m.format(m.After), 100*m.Change)
+		if m.Regression {
+			b.WriteString("  REGRESSION")
+		}
+		b.WriteByte('\n')
+	}
+	if r.Regression {
+		fmt.Fprintf(&b, "regressed beyond %.0f%%", 100*r.Threshold)
+	} else {
+		fmt.Fprintf(&b, "no regression beyond %.0f%%", 100*r.Threshold)
+	}
+	return b.String()
+}
+
+func (m MetricDelta) format(v float64) string {
+	switch m.Unit {
+	case "ns":
+		return time.Duration(v).String()
+	case "fraction":
+		return fmt.Sprintf("%.2f%%", 100*v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
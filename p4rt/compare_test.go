@@ -0,0 +1,75 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runSummary(p50, p99 time.Duration, throughput float64, updates, failed int) RunSummary {
+	var s RunSummary
+	s.Latency.P50, s.Latency.P99 = p50, p99
+	s.Throughput, s.Updates, s.FailedUpdates = throughput, updates, failed
+	return s
+}
+
+func TestCompareRuns(t *testing.T) {
+	before := runSummary(time.Millisecond, 4*time.Millisecond, 1000, 1000, 0)
+	tests := []struct {
+		name      string
+		after     RunSummary
+		regressed []string
+	}{
+		{"unchanged", before, nil},
+		{"within threshold", runSummary(1050*time.Microsecond, 4200*time.Microsecond, 950, 1000, 0), nil},
+		{"p99 up", runSummary(time.Millisecond, 5*time.Millisecond, 1000, 1000, 0), []string{"p99_latency"}},
+		{"faster", runSummary(500*time.Microsecond, 2*time.Millisecond, 2000, 1000, 0), nil},
+		{"slower", runSummary(time.Millisecond, 4*time.Millisecond, 800, 1000, 0), []string{"throughput"}},
+		// Any error where there were none is a regression
+		{"new errors", runSummary(time.Millisecond, 4*time.Millisecond, 1000, 1000, 1), []string{"error_rate"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := CompareRuns(before, test.after)
+			var regressed []string
+			for _, m := range report.Metrics {
+				if m.Regression {
+					regressed = append(regressed, m.Name)
+				}
+			}
+			if strings.Join(regressed, ",") != strings.Join(test.regressed, ",") {
+				t.Errorf("regressed %v, want %v\n%v", regressed, test.regressed, report)
+			}
+			if report.Regression != (len(test.regressed) > 0) {
+				t.Errorf("report regression is %v\n%v", report.Regression, report)
+			}
+		})
+	}
+}
+
+func TestComparisonReportJSON(t *testing.T) {
+	report := CompareRuns(runSummary(time.Millisecond, 4*time.Millisecond, 1000, 1000, 0),
+		runSummary(time.Millisecond, 6*time.Millisecond, 1000, 1000, 0))
+	b, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ComparisonReport
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Regression || decoded.Threshold != DefaultRegressionThreshold || len(decoded.Metrics) != 4 {
+		t.Errorf("decoded %s as %+v", b, decoded)
+	}
+	if p99 := decoded.Metrics[1]; p99.Name != "p99_latency" || p99.Change != 0.5 {
+		t.Errorf("p99 delta decoded as %+v", p99)
+	}
+	if s := report.String(); !strings.Contains(s, "p99_latency") || !strings.Contains(s, "REGRESSION") {
+		t.Errorf("report prints as:\n%s", s)
+	}
+}
@@ -7,6 +7,8 @@ package p4rt
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
@@ -14,6 +16,10 @@ import (
 
 // Cache of address to gRPC client
 var grpcClients = make(map[string]*grpc.ClientConn)
+var grpcClientsMu sync.Mutex
+
+// Time from dial until each connection first became ready, keyed by address
+var connectDurations = make(map[string]time.Duration)
 
 func MonitorConnection(conn *grpc.ClientConn) {
 	state := conn.GetState()
@@ -27,15 +33,54 @@ func MonitorConnection(conn *grpc.ClientConn) {
 	}
 }
 
+// recordConnectDuration waits for conn to become ready for the first time
+// and records how long that took since dialStart.
+func recordConnectDuration(host string, conn *grpc.ClientConn, dialStart time.Time) {
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if state == connectivity.Shutdown || !conn.WaitForStateChange(context.Background(), state) {
+			return
+		}
+	}
+	grpcClientsMu.Lock()
+	connectDurations[host] = time.Since(dialStart)
+	grpcClientsMu.Unlock()
+}
+
+// ConnectDuration returns how long the connection to host took to become
+// ready after dialing, if it has become ready yet.
+func ConnectDuration(host string) (time.Duration, bool) {
+	grpcClientsMu.Lock()
+	defer grpcClientsMu.Unlock()
+	d, ok := connectDurations[host]
+	return d, ok
+}
+
 func GetConnection(host string) (conn *grpc.ClientConn, err error) {
+	grpcClientsMu.Lock()
+	defer grpcClientsMu.Unlock()
 	conn, ok := grpcClients[host]
 	if !ok {
-		conn, err = grpc.Dial(host, grpc.WithInsecure())
+		dialStart := time.Now()
+		conn, err = dialConnection(host, clientOptions[host])
 		if err != nil {
 			return nil, err
 		}
 		grpcClients[host] = conn
-		go MonitorConnection(conn)
+		go recordConnectDuration(host, conn, dialStart)
 	}
 	return
 }
+
+// dialConnection opens a new connection to host, outside the cache.
+func dialConnection(host string, opts ClientOptions) (*grpc.ClientConn, error) {
+	dialOpts, err := opts.dialOptions()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.Dial(host, append(dialOpts, grpc.WithStatsHandler(wireTimeHandler{}))...)
+	if err != nil {
+		return nil, err
+	}
+	go MonitorConnection(conn)
+	return conn, nil
+}
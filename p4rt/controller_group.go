@@ -0,0 +1,192 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc/codes"
+)
+
+const defaultProbeInterval = 5 * time.Millisecond
+
+// ControllerGroup runs a primary and backup controllers of one switch from
+// one process, to benchmark failover. Each controller is a client with its
+// own stream and election ID; the clients are not cached, so they are not
+// returned by CreateOrGetP4RuntimeClient. The primary has the highest
+// election ID and the backups follow in descending order, so that when the
+// primary's stream closes the switch promotes the first backup.
+type ControllerGroup struct {
+	// Probe, if set, is an installed entry that PromoteBackup modifies on
+	// the backup until the switch accepts the write, to measure how long
+	// writes are rejected. Otherwise the failover ends when the backup is
+	// told it is primary.
+	Probe *p4.TableEntry
+	// ProbeInterval is the least time between the starts of two probe
+	// writes, and so the resolution of FailoverResult.Window; zero means
+	// 5ms.
+	ProbeInterval time.Duration
+
+	mu          sync.Mutex
+	controllers []*p4rtClient // primary first
+	timeout     time.Duration
+}
+
+// FailoverResult describes a failover of a ControllerGroup, timed from the
+// closing of the primary's stream.
+type FailoverResult struct {
+	// Promoted is the time until the backup was told it is primary.
+	Promoted time.Duration
+	// Window is the time until the backup's first accepted write; writes
+	// before then were rejected. It is Promoted if the group has no Probe.
+	Window   time.Duration
+	Rejected int        // probe writes rejected
+	Code     codes.Code // DominantCode of the last rejected probe write
+}
+
+func (r FailoverResult) String() string {
+	if r.Rejected == 0 {
+		return fmt.Sprintf("backup promoted after %v, writes accepted after %v", r.Promoted, r.Window)
+	}
+	return fmt.Sprintf("backup promoted after %v, writes rejected (%v) for %v (%d probe writes)",
+		r.Promoted, r.Code, r.Window, r.Rejected)
+}
+
+// NewControllerGroup connects controllers clients to deviceID at host,
+// arbitrates the primary, then each backup, and checks that exactly one of
+// them is primary. Clients are created with batchSize and numThreads as in
+// CreateOrGetP4RuntimeClient. arbitrationTimeout also bounds each
+// PromoteBackup. If any client fails, the clients already set up are closed
+// and the error is returned.
+func NewControllerGroup(host string, deviceID uint64, controllers, batchSize, numThreads int,
+	arbitrationTimeout time.Duration) (*ControllerGroup, error) {
+	if controllers < 2 {
+		return nil, fmt.Errorf("a controller group needs a primary and at least one backup, not %d controllers", controllers)
+	}
+	g := &ControllerGroup{timeout: arbitrationTimeout}
+	for i := 0; i < controllers; i++ {
+		client, err := newP4RuntimeClient(host, deviceID, batchSize, numThreads)
+		if err != nil {
+			g.Close()
+			return nil, errors.Wrapf(err, "controller %d", i)
+		}
+		g.controllers = append(g.controllers, client)
+		electionID := p4.Uint128{Low: uint64(controllers - i)}
+		arb, err := client.arbitrate(electionID, arbitrationTimeout)
+		if err == nil {
+			primary := arb == nil || code.Code(arb.GetStatus().GetCode()) == code.Code_OK
+			if i == 0 && !primary {
+				err = fmt.Errorf("primary is not master for device %d: %s", deviceID, arb.GetStatus().GetMessage())
+			} else if i > 0 && primary {
+				err = fmt.Errorf("backup with election ID %v was made master of device %d", electionID, deviceID)
+			}
+		}
+		if err != nil {
+			g.Close()
+			return nil, errors.Wrapf(err, "controller %d", i)
+		}
+	}
+	return g, nil
+}
+
+// Primary returns the primary controller.
+func (g *ControllerGroup) Primary() P4RuntimeClient {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.controllers[0]
+}
+
+// Backups returns the backup controllers, in the order they are promoted.
+func (g *ControllerGroup) Backups() []P4RuntimeClient {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	backups := make([]P4RuntimeClient, 0, len(g.controllers)-1)
+	for _, c := range g.controllers[1:] {
+		backups = append(backups, c)
+	}
+	return backups
+}
+
+// PromoteBackup simulates a failover: it closes the primary, leaving its
+// writes unanswered by the switch cancelled, and waits for the switch to
+// promote the first backup, which becomes the group's primary. With a
+// Probe, the backup writes it every ProbeInterval until a write is
+// accepted. It fails
+// if no backup is left, or if the failover does not end within the
+// arbitration timeout.
+func (g *ControllerGroup) PromoteBackup() (FailoverResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.controllers) < 2 {
+		return FailoverResult{}, fmt.Errorf("no backup left to promote")
+	}
+	primary, backup := g.controllers[0], g.controllers[1]
+	changes := make(chan MastershipChange, 1)
+	backup.SetMastershipChan(changes)
+	defer backup.SetMastershipChan(nil)
+
+	var result FailoverResult
+	start := time.Now()
+	primary.Close()
+	g.controllers = g.controllers[1:]
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+
+	if g.Probe != nil {
+		probe := []*p4.TableEntry{g.Probe}
+		interval := g.ProbeInterval
+		if interval <= 0 {
+			interval = defaultProbeInterval
+		}
+		// A probe slower than the interval is followed at once, not by a
+		// burst
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			probeErrors := <-backup.Write(tableEntryRequest(backup, p4.Update_MODIFY, probe))
+			if countFailed(probeErrors) == 0 {
+				result.Window = time.Since(start)
+				break
+			}
+			result.Rejected++
+			result.Code = DominantCode(probeErrors)
+			select {
+			case <-timer.C:
+				return result, fmt.Errorf("writes still rejected (%v) %v after closing the primary", result.Code, g.timeout)
+			case <-ticker.C:
+			}
+		}
+	}
+	// The switch may accept writes before its update reaches the backup.
+	// The backup was not primary before, so the only change is promotion.
+	for promoted := false; !promoted; {
+		select {
+		case change := <-changes:
+			promoted = change.Primary
+			result.Promoted = change.Time.Sub(start)
+		case <-timer.C:
+			return result, fmt.Errorf("backup not promoted within %v of closing the primary", g.timeout)
+		}
+	}
+	if g.Probe == nil {
+		result.Window = result.Promoted
+	}
+	return result, nil
+}
+
+// Close closes every controller of the group.
+func (g *ControllerGroup) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, c := range g.controllers {
+		c.Close()
+	}
+	g.controllers = nil
+}
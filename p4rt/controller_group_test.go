@@ -0,0 +1,180 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// electionSwitch is a P4Runtime server that makes the connected controller
+// with the highest election ID primary and rejects writes from the others.
+// When the primary disconnects, it promotes the next one after
+// promoteDelay.
+type electionSwitch struct {
+	p4.UnimplementedP4RuntimeServer
+
+	promoteDelay time.Duration
+
+	mu      sync.Mutex
+	streams map[p4.P4Runtime_StreamChannelServer]uint64 // election IDs
+	primary uint64                                      // zero while there is none
+}
+
+func (s *electionSwitch) Write(ctx context.Context, req *p4.WriteRequest) (*p4.WriteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.primary == 0 || req.GetElectionId().GetLow() != s.primary {
+		return nil, status.Error(codes.PermissionDenied, "not primary")
+	}
+	return &p4.WriteResponse{}, nil
+}
+
+func (s *electionSwitch) StreamChannel(stream p4.P4Runtime_StreamChannelServer) error {
+	var electionID uint64
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, stream)
+		wasPrimary := electionID != 0 && electionID == s.primary
+		if wasPrimary {
+			s.primary = 0
+		}
+		s.mu.Unlock()
+		if wasPrimary {
+			time.AfterFunc(s.promoteDelay, func() { s.elect(nil) })
+		}
+	}()
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if arb := req.GetArbitration(); arb != nil {
+			electionID = arb.GetElectionId().GetLow()
+			s.mu.Lock()
+			s.streams[stream] = electionID
+			s.mu.Unlock()
+			s.elect(stream)
+		}
+	}
+}
+
+// elect makes the highest election ID primary. Every controller is told
+// if the primary changed; the one arbitrating, if any, is always answered.
+func (s *electionSwitch) elect(arbitrating p4.P4Runtime_StreamChannelServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	highest := uint64(0)
+	for _, electionID := range s.streams {
+		if electionID > highest {
+			highest = electionID
+		}
+	}
+	changed := highest != s.primary
+	s.primary = highest
+	for stream, electionID := range s.streams {
+		if !changed && stream != arbitrating {
+			continue
+		}
+		arbStatus := &spb.Status{}
+		if electionID != s.primary {
+			arbStatus = &spb.Status{Code: int32(code.Code_ALREADY_EXISTS), Message: "not primary"}
+		}
+		stream.Send(&p4.StreamMessageResponse{Update: &p4.StreamMessageResponse_Arbitration{
+			Arbitration: &p4.MasterArbitrationUpdate{
+				ElectionId: &p4.Uint128{Low: s.primary},
+				Status:     arbStatus,
+			},
+		}})
+	}
+}
+
+func TestControllerGroup(t *testing.T) {
+	const promoteDelay = 50 * time.Millisecond
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	fake := &electionSwitch{
+		promoteDelay: promoteDelay,
+		streams:      make(map[p4.P4Runtime_StreamChannelServer]uint64),
+	}
+	p4.RegisterP4RuntimeServer(server, fake)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	if _, err := NewControllerGroup(lis.Addr().String(), 1, 1, 1, 1, time.Second); err == nil {
+		t.Error("group of one controller was created")
+	}
+	g, err := NewControllerGroup(lis.Addr().String(), 1, 3, 1, 1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	check := func(backups int) {
+		t.Helper()
+		if !g.Primary().IsPrimary() {
+			t.Error("primary is not primary")
+		}
+		if n := len(g.Backups()); n != backups {
+			t.Errorf("%d backups, want %d", n, backups)
+		}
+		for _, backup := range g.Backups() {
+			if backup.IsPrimary() {
+				t.Errorf("backup with election ID %v is primary", backup.ElectionID())
+			}
+			if backup.ElectionID().GetLow() >= g.Primary().ElectionID().GetLow() {
+				t.Errorf("backup election ID %v is not below the primary's %v", backup.ElectionID(), g.Primary().ElectionID())
+			}
+		}
+	}
+	check(2)
+	entry := &p4.TableEntry{TableId: 1}
+	if p4Errs := <-g.Backups()[0].Write(tableEntryRequest(g.Backups()[0], p4.Update_MODIFY, []*p4.TableEntry{entry})); countFailed(p4Errs) == 0 {
+		t.Error("backup write was accepted")
+	}
+
+	g.Probe = entry
+	result, err := g.PromoteBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Window < promoteDelay || result.Promoted < promoteDelay {
+		t.Errorf("failover %v, want writes rejected and promotion after at least %v", result, promoteDelay)
+	}
+	if result.Rejected == 0 || result.Code != codes.PermissionDenied {
+		t.Errorf("failover %v, want probe writes rejected with %v", result, codes.PermissionDenied)
+	}
+	// One probe per interval, and one more for a tick left over
+	if max := int(result.Window/defaultProbeInterval) + 1; result.Rejected > max {
+		t.Errorf("failover %v, want at most %d probe writes rejected, one per %v", result, max, defaultProbeInterval)
+	}
+	check(1)
+
+	g.Probe = nil
+	result, err = g.PromoteBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Window != result.Promoted || result.Promoted < promoteDelay || result.Rejected != 0 {
+		t.Errorf("failover without probe %v, want promotion after at least %v", result, promoteDelay)
+	}
+	check(0)
+	if _, err := g.PromoteBackup(); err == nil {
+		t.Error("promoted a backup of a group with none left")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// CounterRate is the rate of one counter index over a sampling interval.
+type CounterRate struct {
+	Index         int64
+	PacketsPerSec float64
+	BytesPerSec   float64
+	// Reset is set when the counter went down since the previous sample
+	// (it was reset or wrapped). The rate then counts from zero.
+	Reset bool
+}
+
+// CounterRates holds the rates of every index of a counter over one interval.
+type CounterRates struct {
+	Time     time.Time     // when the sample ending the interval was read
+	Interval time.Duration // time since the previous sample
+	Rates    []CounterRate // ordered by index
+}
+
+// CounterRateSampler reads a counter array periodically and delivers
+// per-index packet and byte rates on C.
+type CounterRateSampler struct {
+	C <-chan CounterRates
+
+	stop chan struct{}
+	done chan struct{}
+	err  error
+}
+
+// CounterRateSampler starts sampling every index of counterName every
+// interval. The first rates arrive after two reads. Sampling continues
+// until Stop is called or a read fails; C is closed either way.
+func (c *p4rtClient) CounterRateSampler(counterName string, interval time.Duration) (*CounterRateSampler, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid sampling interval %v", interval)
+	}
+	p4info, err := c.p4infoHelper()
+	if err != nil {
+		return nil, err
+	}
+	counter, err := p4info.GetCounter(counterName)
+	if err != nil {
+		return nil, err
+	}
+	wildcard := &p4.Entity{Entity: &p4.Entity_CounterEntry{CounterEntry: &p4.CounterEntry{
+		CounterId: counter.GetPreamble().GetId(),
+	}}}
+
+	ch := make(chan CounterRates, 1)
+	s := &CounterRateSampler{
+		C:    ch,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var previous map[int64]*p4.CounterData
+		var previousTime time.Time
+		for {
+			entities, err := c.readEntities(wildcard)
+			now := time.Now()
+			if err != nil {
+				s.err = fmt.Errorf("reading counter %s: %v", counterName, err)
+				return
+			}
+			current := make(map[int64]*p4.CounterData, len(entities))
+			for _, entity := range entities {
+				entry := entity.GetCounterEntry()
+				current[entry.GetIndex().GetIndex()] = entry.GetData()
+			}
+			if previous != nil {
+				rates := CounterRates{
+					Time:     now,
+					Interval: now.Sub(previousTime),
+					Rates:    counterRates(previous, current, now.Sub(previousTime)),
+				}
+				select {
+				case ch <- rates:
+				case <-s.stop:
+					return
+				}
+			}
+			previous, previousTime = current, now
+
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return s, nil
+}
+
+// Stop ends sampling and returns the read error that ended it early, if any.
+func (s *CounterRateSampler) Stop() error {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	return s.err
+}
+
+// counterRates computes the rate of every index present in both samples.
+func counterRates(previous, current map[int64]*p4.CounterData, interval time.Duration) []CounterRate {
+	seconds := interval.Seconds()
+	rates := make([]CounterRate, 0, len(current))
+	for index, data := range current {
+		before, ok := previous[index]
+		if !ok {
+			continue
+		}
+		packets := data.GetPacketCount() - before.GetPacketCount()
+		bytes := data.GetByteCount() - before.GetByteCount()
+		rate := CounterRate{Index: index}
+		if packets < 0 || bytes < 0 {
+			rate.Reset = true
+			packets, bytes = data.GetPacketCount(), data.GetByteCount()
+		}
+		rate.PacketsPerSec = float64(packets) / seconds
+		rate.BytesPerSec = float64(bytes) / seconds
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Index < rates[j].Index })
+	return rates
+}
@@ -0,0 +1,73 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// ResetCounters zeroes every index of the indirect counter array
+// counterName. The array size comes from the P4Info, and the entries are
+// written in batches of the client's batch size.
+func (c *p4rtClient) ResetCounters(counterName string) error {
+	p4info, err := c.p4infoHelper()
+	if err != nil {
+		return err
+	}
+	counter, err := p4info.GetCounter(counterName)
+	if err != nil {
+		return err
+	}
+	counterID := counter.GetPreamble().GetId()
+
+	batchSize := c.getBatchSize()
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	var responses []<-chan []*p4.Error
+	for start := int64(0); start < counter.GetSize(); start += int64(batchSize) {
+		end := start + int64(batchSize)
+		if end > counter.GetSize() {
+			end = counter.GetSize()
+		}
+		updates := make([]*p4.Update, 0, end-start)
+		for i := start; i < end; i++ {
+			updates = append(updates, &p4.Update{
+				Type: p4.Update_MODIFY,
+				Entity: &p4.Entity{Entity: &p4.Entity_CounterEntry{CounterEntry: &p4.CounterEntry{
+					CounterId: counterID,
+					Index:     &p4.Index{Index: i},
+					Data:      &p4.CounterData{},
+				}}},
+			})
+		}
+		responses = append(responses, c.Write(&p4.WriteRequest{
+			DeviceId:   c.deviceID,
+			ElectionId: c.ElectionID(),
+			Updates:    updates,
+		}))
+	}
+
+	failed := 0
+	var firstErr *p4.Error
+	for _, res := range responses {
+		for _, p4Err := range <-res {
+			if p4Err.GetCanonicalCode() != int32(codes.OK) {
+				if firstErr == nil {
+					firstErr = p4Err
+				}
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to reset %d of %d indices of counter %s: %s",
+			failed, counter.GetSize(), counterName, firstErr.GetMessage())
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// ClientOptions configures how connections to a switch are secured. The
+// zero value dials without TLS, as before.
+type ClientOptions struct {
+	// TLS turns on TLS with the system's root CAs. It is implied by any of
+	// the TLS fields below.
+	TLS bool
+	// CAFile is a PEM bundle of CAs to verify the server against, instead
+	// of the system's.
+	CAFile string
+	// CertFile and KeyFile hold a PEM client certificate and key, for
+	// servers that require mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name the server certificate is checked
+	// against, which is otherwise the host part of the address.
+	ServerName string
+	// InsecureSkipVerify accepts any server certificate. It is meant for
+	// lab switches with self-signed certificates.
+	InsecureSkipVerify bool
+
+	// Token, if set, is sent as a bearer token in the authorization
+	// metadata of every RPC.
+	Token string
+	// PerRPCCredentials, if set, supplies metadata for every RPC, e.g. a
+	// token that expires and must be refreshed. It is used in addition to
+	// Token.
+	PerRPCCredentials credentials.PerRPCCredentials
+}
+
+func (opts ClientOptions) tlsEnabled() bool {
+	return opts.TLS || opts.CAFile != "" || opts.CertFile != "" || opts.KeyFile != "" || opts.ServerName != "" || opts.InsecureSkipVerify
+}
+
+// Options for connections not yet dialled, keyed by address
+var clientOptions = make(map[string]ClientOptions)
+
+// SetClientOptions sets the options used to dial host, for all clients of
+// that address. It must be called before the first client of host is
+// created; the files are read at once, so that mistakes are reported here.
+func SetClientOptions(host string, opts ClientOptions) error {
+	if _, err := opts.dialOptions(); err != nil {
+		return err
+	}
+	grpcClientsMu.Lock()
+	defer grpcClientsMu.Unlock()
+	if _, ok := grpcClients[host]; ok {
+		return fmt.Errorf("already connected to %s; set client options before creating a client", host)
+	}
+	clientOptions[host] = opts
+	return nil
+}
+
+func hostClientOptions(host string) ClientOptions {
+	grpcClientsMu.Lock()
+	defer grpcClientsMu.Unlock()
+	return clientOptions[host]
+}
+
+// dialOptions returns the transport and per-RPC credential options.
+func (opts ClientOptions) dialOptions() ([]grpc.DialOption, error) {
+	var dialOpts []grpc.DialOption
+	if !opts.tlsEnabled() {
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	} else {
+		config, err := opts.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	}
+	if opts.Token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(bearerToken{
+			token:      opts.Token,
+			requireTLS: opts.tlsEnabled(),
+		}))
+	}
+	if opts.PerRPCCredentials != nil {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(opts.PerRPCCredentials))
+	}
+	return dialOpts, nil
+}
+
+func (opts ClientOptions) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+	if opts.CAFile != "" {
+		pem, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading CA bundle")
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CAFile)
+		}
+	}
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, fmt.Errorf("a client certificate needs both a certificate file and a key file")
+	}
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// bearerToken sends a fixed token with every RPC.
+type bearerToken struct {
+	token      string
+	requireTLS bool
+}
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+// RequireTransportSecurity lets a token go in the clear only when the
+// connection is not TLS at all, as on a lab network.
+func (t bearerToken) RequireTransportSecurity() bool {
+	return t.requireTLS
+}
@@ -0,0 +1,118 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// DecodeMatch renders fm, a match on fieldName of tableName, in the syntax
+// LoadEntriesCSV accepts: "value", "value/prefix_len", "value&&&mask" or
+// "low..high" depending on the match kind.
+func (p4infoHelper *P4InfoHelper) DecodeMatch(tableName, fieldName string, fm *p4.FieldMatch) (string, error) {
+	table, err := p4infoHelper.GetTable(tableName)
+	if err != nil {
+		return "", err
+	}
+	for _, mf := range table.GetMatchFields() {
+		if mf.GetName() != fieldName {
+			continue
+		}
+		format := func(b []byte) string {
+			return formatValue(b, mf.GetBitwidth(), mf.GetName(), mf.GetTypeName().GetName())
+		}
+		switch m := fm.GetFieldMatchType().(type) {
+		case *p4.FieldMatch_Exact_:
+			return format(m.Exact.GetValue()), nil
+		case *p4.FieldMatch_Lpm:
+			return fmt.Sprintf("%s/%d", format(m.Lpm.GetValue()), m.Lpm.GetPrefixLen()), nil
+		case *p4.FieldMatch_Ternary_:
+			return fmt.Sprintf("%s&&&%s", format(m.Ternary.GetValue()), formatInteger(m.Ternary.GetMask())), nil
+		case *p4.FieldMatch_Range_:
+			return fmt.Sprintf("%s..%s", format(m.Range.GetLow()), format(m.Range.GetHigh())), nil
+		case *p4.FieldMatch_Optional_:
+			return format(m.Optional.GetValue()), nil
+		default:
+			return "", fmt.Errorf("unsupported field match %T", m)
+		}
+	}
+	return "", fmt.Errorf("Unable to find match field %s in table %s", fieldName, tableName)
+}
+
+// DecodeActionParams renders the parameters of action, keyed by parameter
+// name. action must be an instance of actionName.
+func (p4infoHelper *P4InfoHelper) DecodeActionParams(actionName string, action *p4.Action) (map[string]string, error) {
+	info, err := p4infoHelper.GetAction(actionName)
+	if err != nil {
+		return nil, err
+	}
+	if action.GetActionId() != info.GetPreamble().GetId() {
+		return nil, fmt.Errorf("action ID %d is not the ID of action %s", action.GetActionId(), actionName)
+	}
+	params := make(map[string]string, len(action.GetParams()))
+	for _, param := range action.GetParams() {
+		found := false
+		for _, p := range info.GetParams() {
+			if p.GetId() == param.GetParamId() {
+				params[p.GetName()] = formatValue(param.GetValue(), p.GetBitwidth(), p.GetName(), p.GetTypeName().GetName())
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Unable to find parameter %d of action %s", param.GetParamId(), actionName)
+		}
+	}
+	return params, nil
+}
+
+// formatValue renders b as an address when the field looks like one (by its
+// type name, or by its name and width) and as an integer otherwise.
+func formatValue(b []byte, bitwidth int32, name string, typeName string) string {
+	hint := strings.ToLower(typeName + " " + name)
+	hints := func(words ...string) bool {
+		for _, w := range words {
+			if strings.Contains(hint, w) {
+				return true
+			}
+		}
+		return false
+	}
+	value := new(big.Int).SetBytes(b)
+	switch {
+	case bitwidth == 32 && hints("ipv4", "ip_", "_ip", "addr"):
+		return net.IP(padBytes(value, 4)).String()
+	case bitwidth == 128 && hints("ipv6", "ip_", "_ip", "addr"):
+		return net.IP(padBytes(value, 16)).String()
+	case bitwidth == 48 && hints("mac", "eth", "addr"):
+		return net.HardwareAddr(padBytes(value, 6)).String()
+	}
+	return formatInteger(b)
+}
+
+// formatInteger renders small values in decimal and wide ones in hex.
+func formatInteger(b []byte) string {
+	value := new(big.Int).SetBytes(b)
+	if value.BitLen() <= 32 {
+		return value.String()
+	}
+	return "0x" + value.Text(16)
+}
+
+// padBytes returns value as exactly n big-endian bytes, truncating on the left.
+func padBytes(value *big.Int, n int) []byte {
+	raw := value.Bytes()
+	if len(raw) > n {
+		raw = raw[len(raw)-n:]
+	}
+	b := make([]byte, n)
+	copy(b[n-len(raw):], raw)
+	return b
+}
@@ -0,0 +1,285 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+const defaultDigestChannelDepth = 100
+
+// DigestOptions configures a digest subscription. The zero value lets the
+// switch pick its defaults and leaves acknowledging to the caller.
+type DigestOptions struct {
+	// MaxTimeout, MaxListSize and AckTimeout are the DigestEntry config:
+	// how long the switch may hold digests to fill a list, how many a list
+	// may carry, and how long it waits for an ack before sending the same
+	// digests again.
+	MaxTimeout  time.Duration
+	MaxListSize int32
+	AckTimeout  time.Duration
+	// AutoAck acks every DigestList as soon as the stream receiver gets it,
+	// before it is delivered on C.
+	AutoAck bool
+	// Depth is the capacity of C; 0 means 100. Lists arriving while C is
+	// full are dropped, and counted in Stats.DigestListsDropped.
+	Depth int
+}
+
+// DigestTrace is one DigestList from generation to acknowledgment.
+type DigestTrace struct {
+	DigestID uint32
+	ListID   uint64
+	Entries  int // digests carried by the list
+	// Generated is the switch's timestamp for the list; it is zero if the
+	// switch sent none. Comparing it with the other times assumes the
+	// switch and client clocks are in sync.
+	Generated time.Time
+	Received  time.Time // when the stream receiver got the list
+	Acked     time.Time // when the DigestListAck was handed to gRPC
+	// Latency is Acked minus Generated, or minus Received when the switch
+	// sent no timestamp.
+	Latency time.Duration
+}
+
+// DigestSubscription delivers the DigestLists of one digest on C. C is
+// closed by Close, and when the stream channel ends for good.
+type DigestSubscription struct {
+	C <-chan *p4.DigestList
+
+	client   *p4rtClient
+	digestID uint32
+	autoAck  bool
+	ch       chan *p4.DigestList
+}
+
+// SubscribeDigest configures digestName on the switch with a DigestEntry
+// built from opts and delivers the lists it generates. There may be one
+// subscription per digest.
+func (c *p4rtClient) SubscribeDigest(digestName string, opts DigestOptions) (*DigestSubscription, error) {
+	p4info, err := c.p4infoHelper()
+	if err != nil {
+		return nil, err
+	}
+	digest, err := p4info.GetDigest(digestName)
+	if err != nil {
+		return nil, err
+	}
+	depth := opts.Depth
+	if depth <= 0 {
+		depth = defaultDigestChannelDepth
+	}
+	ch := make(chan *p4.DigestList, depth)
+	sub := &DigestSubscription{
+		C:        ch,
+		client:   c,
+		digestID: digest.GetPreamble().GetId(),
+		autoAck:  opts.AutoAck,
+		ch:       ch,
+	}
+
+	c.digestMu.Lock()
+	if _, ok := c.digestSubs[sub.digestID]; ok {
+		c.digestMu.Unlock()
+		return nil, fmt.Errorf("digest %s already has a subscription", digestName)
+	}
+	if c.digestSubs == nil {
+		c.digestSubs = make(map[uint32]*DigestSubscription)
+		c.digestReceived = make(map[digestListKey]time.Time)
+	}
+	// Registered before the entry is written, so no early list is missed
+	c.digestSubs[sub.digestID] = sub
+	c.digestMu.Unlock()
+
+	entry := &p4.DigestEntry{
+		DigestId: sub.digestID,
+		Config: &p4.DigestEntry_Config{
+			MaxTimeoutNs: int64(opts.MaxTimeout),
+			MaxListSize:  opts.MaxListSize,
+			AckTimeoutNs: int64(opts.AckTimeout),
+		},
+	}
+	p4Err := c.writeDigestEntry(p4.Update_INSERT, entry)
+	if codes.Code(p4Err.GetCanonicalCode()) == codes.AlreadyExists {
+		// Left behind by an earlier run; take it over
+		p4Err = c.writeDigestEntry(p4.Update_MODIFY, entry)
+	}
+	if p4Err.GetCanonicalCode() != int32(codes.OK) {
+		c.removeDigestSubscription(sub)
+		return nil, fmt.Errorf("failed to configure digest %s: %s", digestName, p4Err.GetMessage())
+	}
+	return sub, nil
+}
+
+func (c *p4rtClient) writeDigestEntry(updateType p4.Update_Type, entry *p4.DigestEntry) *p4.Error {
+	results := <-c.Write(&p4.WriteRequest{
+		DeviceId:   c.deviceID,
+		ElectionId: c.ElectionID(),
+		Updates: []*p4.Update{{
+			Type:   updateType,
+			Entity: &p4.Entity{Entity: &p4.Entity_DigestEntry{DigestEntry: entry}},
+		}},
+	})
+	return results[0]
+}
+
+// Ack acknowledges list, so the switch may send its digests again. It is
+// only needed without AutoAck.
+func (s *DigestSubscription) Ack(list *p4.DigestList) error {
+	return s.client.AckDigestList(list)
+}
+
+// Close deletes the digest's DigestEntry from the switch, which stops it
+// generating lists, and closes C.
+func (s *DigestSubscription) Close() error {
+	if !s.client.removeDigestSubscription(s) {
+		return nil
+	}
+	p4Err := s.client.writeDigestEntry(p4.Update_DELETE, &p4.DigestEntry{DigestId: s.digestID})
+	if p4Err.GetCanonicalCode() != int32(codes.OK) {
+		return fmt.Errorf("failed to delete digest entry %d: %s", s.digestID, p4Err.GetMessage())
+	}
+	return nil
+}
+
+// removeDigestSubscription unregisters sub and closes its channel. It
+// returns false if sub was already removed.
+func (c *p4rtClient) removeDigestSubscription(sub *DigestSubscription) bool {
+	c.digestMu.Lock()
+	defer c.digestMu.Unlock()
+	if c.digestSubs[sub.digestID] != sub {
+		return false
+	}
+	delete(c.digestSubs, sub.digestID)
+	for key := range c.digestReceived {
+		if key.digestID == sub.digestID {
+			delete(c.digestReceived, key)
+		}
+	}
+	close(sub.ch)
+	return true
+}
+
+// closeDigestSubscriptions closes every subscription once the stream is
+// gone for good.
+func (c *p4rtClient) closeDigestSubscriptions() {
+	c.digestMu.Lock()
+	defer c.digestMu.Unlock()
+	for id, sub := range c.digestSubs {
+		delete(c.digestSubs, id)
+		close(sub.ch)
+	}
+	c.digestReceived = nil
+}
+
+// digestListKey identifies a DigestList awaiting its ack.
+type digestListKey struct {
+	digestID uint32
+	listID   uint64
+}
+
+// AckDigestList sends a DigestListAck for list on the stream channel.
+func (c *p4rtClient) AckDigestList(list *p4.DigestList) error {
+	c.digestMu.Lock()
+	key := digestListKey{list.GetDigestId(), list.GetListId()}
+	received, ok := c.digestReceived[key]
+	delete(c.digestReceived, key)
+	c.digestMu.Unlock()
+	if !ok {
+		received = time.Now()
+	}
+	return c.sendDigestAck(list, received)
+}
+
+func (c *p4rtClient) sendDigestAck(list *p4.DigestList, received time.Time) error {
+	req := &p4.StreamMessageRequest{Update: &p4.StreamMessageRequest_DigestAck{
+		DigestAck: &p4.DigestListAck{DigestId: list.GetDigestId(), ListId: list.GetListId()},
+	}}
+	c.streamSendMu.Lock()
+	err := c.stream.Send(req)
+	acked := time.Now()
+	c.streamSendMu.Unlock()
+	if err != nil {
+		return errors.Wrap(err, "error sending digest list ack")
+	}
+	c.traceDigest(list, received, acked)
+	return nil
+}
+
+// handleDigestList passes a DigestList from the stream to its subscription,
+// acking it first if the subscription asked for that. It never blocks.
+func (c *p4rtClient) handleDigestList(received time.Time, list *p4.DigestList) {
+	atomic.AddUint64(&c.digestListsReceived, 1)
+	c.digestMu.Lock()
+	sub := c.digestSubs[list.GetDigestId()]
+	c.digestMu.Unlock()
+	if sub == nil {
+		atomic.AddUint64(&c.digestListsDropped, 1)
+		return
+	}
+	if sub.autoAck {
+		if err := c.sendDigestAck(list, received); err != nil {
+			fmt.Printf("digest ack: %v\n", err)
+		}
+	}
+	c.digestMu.Lock()
+	defer c.digestMu.Unlock()
+	if c.digestSubs[list.GetDigestId()] != sub {
+		// Closed while acking
+		return
+	}
+	// Recorded before delivery, so that an Ack racing the send finds it
+	key := digestListKey{list.GetDigestId(), list.GetListId()}
+	if !sub.autoAck {
+		c.digestReceived[key] = received
+	}
+	select {
+	case sub.ch <- list:
+	default:
+		delete(c.digestReceived, key)
+		atomic.AddUint64(&c.digestListsDropped, 1)
+	}
+}
+
+// SetDigestTraceChan delivers a DigestTrace on ch for every acked
+// DigestList. Traces are dropped, and counted in Stats.DigestTracesDropped,
+// if ch is full. Passing nil stops delivery.
+func (c *p4rtClient) SetDigestTraceChan(ch chan DigestTrace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.digestTraceChan = ch
+}
+
+func (c *p4rtClient) traceDigest(list *p4.DigestList, received, acked time.Time) {
+	c.mu.RLock()
+	ch := c.digestTraceChan
+	c.mu.RUnlock()
+	if ch == nil {
+		return
+	}
+	trace := DigestTrace{
+		DigestID: list.GetDigestId(),
+		ListID:   list.GetListId(),
+		Entries:  len(list.GetData()),
+		Received: received,
+		Acked:    acked,
+		Latency:  acked.Sub(received),
+	}
+	if list.GetTimestamp() != 0 {
+		trace.Generated = time.Unix(0, list.GetTimestamp())
+		trace.Latency = acked.Sub(trace.Generated)
+	}
+	select {
+	case ch <- trace:
+	default:
+		atomic.AddUint64(&c.digestTracesDropped, 1)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"testing"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func TestHandleDigestListDrops(t *testing.T) {
+	c := &p4rtClient{}
+	ch := make(chan *p4.DigestList, 1)
+	sub := &DigestSubscription{C: ch, client: c, digestID: 9, ch: ch}
+	c.digestSubs = map[uint32]*DigestSubscription{9: sub}
+	c.digestReceived = make(map[digestListKey]time.Time)
+
+	c.handleDigestList(time.Now(), &p4.DigestList{DigestId: 9, ListId: 1})
+	// The channel is full, so this one is dropped
+	c.handleDigestList(time.Now(), &p4.DigestList{DigestId: 9, ListId: 2})
+	// No subscription
+	c.handleDigestList(time.Now(), &p4.DigestList{DigestId: 10, ListId: 1})
+
+	if _, ok := c.digestReceived[digestListKey{9, 1}]; !ok {
+		t.Error("delivered list is not awaiting its ack")
+	}
+	if _, ok := c.digestReceived[digestListKey{9, 2}]; ok {
+		t.Error("dropped list is awaiting an ack")
+	}
+	stats := c.Stats()
+	if stats.DigestListsReceived != 3 || stats.DigestListsDropped != 2 {
+		t.Errorf("got %d lists received and %d dropped, want 3 and 2", stats.DigestListsReceived, stats.DigestListsDropped)
+	}
+
+	traces := make(chan DigestTrace, 1)
+	c.SetDigestTraceChan(traces)
+	list := <-ch
+	c.traceDigest(list, time.Now(), time.Now())
+	c.traceDigest(list, time.Now(), time.Now())
+	if stats := c.Stats(); stats.DigestTracesDropped != 1 || stats.DigestListsDropped != 2 {
+		t.Errorf("got %d traces dropped and %d lists dropped, want 1 and 2", stats.DigestTracesDropped, stats.DigestListsDropped)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// LoadEntriesCSV reads table entries for tableName from a CSV file (or a
+// TSV file, if path ends in .tsv). The header row names the columns:
+//
+//   - a match field name of the table: the field's MatchValue; an empty
+//     cell leaves a ternary, range or optional field as a wildcard
+//   - "action": the action name
+//   - "priority": the entry priority; empty means 0
+//   - "metadata": the entry's metadata cookie, the cell's bytes as they are
+//   - any other name: a parameter of the row's action
+//
+// Rows are built with TableEntry. Any malformed row fails the whole
+// load with an error naming its line.
+func (p4infoHelper *P4InfoHelper) LoadEntriesCSV(path, tableName string) ([]*p4.TableEntry, error) {
+	table, err := p4infoHelper.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		r.Comma = '\t'
+	}
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading header: %v", path, err)
+	}
+	matchFields := make(map[string]*p4_config.MatchField)
+	for _, mf := range table.GetMatchFields() {
+		matchFields[mf.GetName()] = mf
+	}
+
+	var entries []*p4.TableEntry
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			// A csv.ParseError names its line
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		entry, err := p4infoHelper.csvEntry(table, matchFields, header, row)
+		if err != nil {
+			// The row's first line; blank lines are skipped and quoted
+			// cells may span lines, so rows do not count lines
+			line, _ := r.FieldPos(0)
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func (p4infoHelper *P4InfoHelper) csvEntry(table *p4_config.Table, matchFields map[string]*p4_config.MatchField,
+	header []string, row []string) (*p4.TableEntry, error) {
+	var actionName string
+	var priority int32
+	var metadata []byte
+	match := make(map[string]MatchValue)
+	params := make(map[string]string)
+	for i, column := range header {
+		cell := strings.TrimSpace(row[i])
+		if _, ok := matchFields[column]; ok {
+			if cell != "" {
+				match[column] = MatchValue(cell)
+			}
+			continue
+		}
+		switch column {
+		case "action":
+			actionName = cell
+		case "priority":
+			if cell == "" {
+				continue
+			}
+			p, err := strconv.ParseInt(cell, 0, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid priority %q", cell)
+			}
+			priority = int32(p)
+		case "metadata":
+			if cell != "" {
+				metadata = []byte(cell)
+			}
+		default:
+			if cell != "" {
+				params[column] = cell
+			}
+		}
+	}
+	if actionName == "" {
+		return nil, fmt.Errorf("missing action")
+	}
+	return p4infoHelper.TableEntry(table.GetPreamble().GetName(), match, priority, metadata, actionName, params)
+}
@@ -0,0 +1,63 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "entries_csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadEntriesCSV(t *testing.T) {
+	helper := testP4Info()
+	path := writeTempFile(t, "routes.csv", "port,dst,action,out,priority,metadata\n"+
+		"1,10.0.0.0/8,forward,2,,\n"+
+		"\n"+
+		"2,10.1.0.0/16,forward,3,7,\"gen\n7\"\n")
+	entries, err := helper.LoadEntriesCSV(path, "routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("loaded %d entries, want 2", len(entries))
+	}
+	if p := entries[0].GetPriority(); p != 0 {
+		t.Errorf("entry with an empty priority cell has priority %d, want 0", p)
+	}
+	if p, m := entries[1].GetPriority(), string(entries[1].GetMetadata()); p != 7 || m != "gen\n7" {
+		t.Errorf("second entry has priority %d and metadata %q, want 7 and %q", p, m, "gen\n7")
+	}
+}
+
+// TestLoadEntriesCSVErrorLine checks that errors name the row's physical
+// line, past blank lines and cells spanning lines.
+func TestLoadEntriesCSVErrorLine(t *testing.T) {
+	helper := testP4Info()
+	path := writeTempFile(t, "routes.csv", "port,dst,action,out,metadata\n"+
+		"\n"+
+		"1,10.0.0.0/8,forward,2,\"two\nlines\"\n"+
+		"\n"+
+		"2,10.1.0.0/16,forward,3,\n"+
+		"3,10.2.0.0/16,,4,\n")
+	_, err := helper.LoadEntriesCSV(path, "routes")
+	if err == nil || !strings.Contains(err.Error(), "routes.csv:7: missing action") {
+		t.Errorf("got error %v, want it at line 7", err)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// EntryKey returns a stable string identifying entry by its table ID,
+// priority and match fields. Action, metadata and counter data are ignored.
+// Match bytes are canonicalized (leading zero bytes stripped) and fields are
+// ordered by ID, so equivalent encodings of the same key compare equal.
+func EntryKey(entry *p4.TableEntry) string {
+	matches := make([]*p4.FieldMatch, len(entry.GetMatch()))
+	copy(matches, entry.GetMatch())
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].GetFieldId() < matches[j].GetFieldId()
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d/%d", entry.GetTableId(), entry.GetPriority())
+	for _, fm := range matches {
+		fmt.Fprintf(&b, "/%d:", fm.GetFieldId())
+		switch m := fm.GetFieldMatchType().(type) {
+		case *p4.FieldMatch_Exact_:
+			fmt.Fprintf(&b, "exact=%x", canonicalBytes(m.Exact.GetValue()))
+		case *p4.FieldMatch_Lpm:
+			fmt.Fprintf(&b, "lpm=%x/%d", canonicalBytes(m.Lpm.GetValue()), m.Lpm.GetPrefixLen())
+		case *p4.FieldMatch_Ternary_:
+			fmt.Fprintf(&b, "ternary=%x&%x", canonicalBytes(m.Ternary.GetValue()), canonicalBytes(m.Ternary.GetMask()))
+		case *p4.FieldMatch_Range_:
+			fmt.Fprintf(&b, "range=%x-%x", canonicalBytes(m.Range.GetLow()), canonicalBytes(m.Range.GetHigh()))
+		case *p4.FieldMatch_Optional_:
+			fmt.Fprintf(&b, "optional=%x", canonicalBytes(m.Optional.GetValue()))
+		case *p4.FieldMatch_Other:
+			fmt.Fprintf(&b, "other=%s:%x", m.Other.GetTypeUrl(), m.Other.GetValue())
+		}
+	}
+	return b.String()
+}
+
+// EntriesEqual reports whether a and b identify the same table entry, i.e.
+// whether they have the same EntryKey.
+func EntriesEqual(a, b *p4.TableEntry) bool {
+	return EntryKey(a) == EntryKey(b)
+}
+
+// canonicalBytes strips leading zero bytes, as required for the canonical
+// P4Runtime bytestring representation. Zero is represented by a single byte.
+func canonicalBytes(value []byte) []byte {
+	i := 0
+	for i < len(value)-1 && value[i] == 0 {
+		i++
+	}
+	return value[i:]
+}
@@ -0,0 +1,160 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// ExerciseAllActions inserts one entry per action of tableName, to check
+// that the target accepts every action. Entries get distinct synthesized
+// keys and every action parameter is set to 1. Entries that were inserted
+// are deleted again afterwards.
+//
+// The result has one p4.Error per exercised action, in P4Info order;
+// the message of a failed one starts with the action name. Default-only
+// actions cannot be used by entries and are skipped.
+func (c *p4rtClient) ExerciseAllActions(tableName string) ([]*p4.Error, error) {
+	p4info, err := c.p4infoHelper()
+	if err != nil {
+		return nil, err
+	}
+	table, err := p4info.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if table.GetIsConstTable() {
+		return nil, fmt.Errorf("table %s is const", tableName)
+	}
+	if len(table.GetMatchFields()) == 0 {
+		return nil, fmt.Errorf("table %s has no match fields; only its default action can be set", tableName)
+	}
+
+	var priority int32
+	for _, mf := range table.GetMatchFields() {
+		switch mf.GetMatchType() {
+		case p4_config.MatchField_TERNARY, p4_config.MatchField_RANGE, p4_config.MatchField_OPTIONAL:
+			priority = 1
+		}
+	}
+
+	var actionNames []string
+	var updates []*p4.Update
+	for _, ref := range table.GetActionRefs() {
+		if ref.GetScope() == p4_config.ActionRef_DEFAULT_ONLY {
+			continue
+		}
+		action, ok := p4info.actionIDs[ref.GetId()]
+		if !ok {
+			return nil, fmt.Errorf("Unable to find action %d of table %s", ref.GetId(), tableName)
+		}
+		match, err := exerciseMatch(table, uint64(len(updates)))
+		if err != nil {
+			return nil, err
+		}
+		params := make(map[string][]byte, len(action.GetParams()))
+		for _, param := range action.GetParams() {
+			params[param.GetName()], _ = EncodeValue("1", param.GetBitwidth())
+		}
+		actionParams, err := buildActionParams(action, params)
+		if err != nil {
+			return nil, err
+		}
+		actionNames = append(actionNames, action.GetPreamble().GetName())
+		updates = append(updates, &p4.Update{
+			Type: p4.Update_INSERT,
+			Entity: &p4.Entity{Entity: &p4.Entity_TableEntry{TableEntry: &p4.TableEntry{
+				TableId:  table.GetPreamble().GetId(),
+				Match:    match,
+				Priority: priority,
+				Action: &p4.TableAction{Type: &p4.TableAction_Action{Action: &p4.Action{
+					ActionId: action.GetPreamble().GetId(),
+					Params:   actionParams,
+				}}},
+			}}},
+		})
+	}
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("table %s has no actions usable by entries", tableName)
+	}
+
+	results := <-c.Write(&p4.WriteRequest{
+		DeviceId:   c.deviceID,
+		ElectionId: c.ElectionID(),
+		Updates:    updates,
+	})
+
+	// Clean up whatever made it in
+	var cleanup []*p4.Update
+	for i, result := range results {
+		if result.GetCanonicalCode() == int32(codes.OK) {
+			cleanup = append(cleanup, &p4.Update{Type: p4.Update_DELETE, Entity: updates[i].Entity})
+		} else {
+			result = proto.Clone(result).(*p4.Error)
+			result.Message = fmt.Sprintf("%s: %s", actionNames[i], result.GetMessage())
+			results[i] = result
+		}
+	}
+	if len(cleanup) > 0 {
+		for _, p4Err := range <-c.Write(&p4.WriteRequest{
+			DeviceId:   c.deviceID,
+			ElectionId: c.ElectionID(),
+			Updates:    cleanup,
+		}) {
+			if p4Err.GetCanonicalCode() != int32(codes.OK) {
+				return results, fmt.Errorf("failed to delete exercised entries from %s: %s", tableName, p4Err.GetMessage())
+			}
+		}
+	}
+	return results, nil
+}
+
+// exerciseMatch returns a key for table that is distinct for each n: n goes
+// into the first match field wide enough for it and other fields are zero.
+func exerciseMatch(table *p4_config.Table, n uint64) ([]*p4.FieldMatch, error) {
+	var match []*p4.FieldMatch
+	placed := false
+	for _, mf := range table.GetMatchFields() {
+		value := "0"
+		if !placed && (mf.GetBitwidth() >= 64 || n < 1<<uint(mf.GetBitwidth())) {
+			value = fmt.Sprint(n)
+			placed = true
+		}
+		b, err := EncodeValue(value, mf.GetBitwidth())
+		if err != nil {
+			return nil, err
+		}
+		fm := &p4.FieldMatch{FieldId: mf.GetId()}
+		switch mf.GetMatchType() {
+		case p4_config.MatchField_EXACT:
+			fm.FieldMatchType = &p4.FieldMatch_Exact_{Exact: &p4.FieldMatch_Exact{Value: b}}
+		case p4_config.MatchField_LPM:
+			fm.FieldMatchType = &p4.FieldMatch_Lpm{Lpm: &p4.FieldMatch_LPM{Value: b, PrefixLen: mf.GetBitwidth()}}
+		case p4_config.MatchField_TERNARY:
+			mask := make([]byte, len(b))
+			for i := range mask {
+				mask[i] = 0xff
+			}
+			mask[0] >>= uint(len(b)*8 - int(mf.GetBitwidth()))
+			fm.FieldMatchType = &p4.FieldMatch_Ternary_{Ternary: &p4.FieldMatch_Ternary{Value: b, Mask: mask}}
+		case p4_config.MatchField_RANGE:
+			fm.FieldMatchType = &p4.FieldMatch_Range_{Range: &p4.FieldMatch_Range{Low: b, High: b}}
+		case p4_config.MatchField_OPTIONAL:
+			fm.FieldMatchType = &p4.FieldMatch_Optional_{Optional: &p4.FieldMatch_Optional{Value: b}}
+		default:
+			return nil, fmt.Errorf("unsupported match type %v for match field %s", mf.GetMatchType(), mf.GetName())
+		}
+		match = append(match, fm)
+	}
+	if !placed {
+		return nil, fmt.Errorf("match fields of table %s are too narrow for %d distinct keys", table.GetPreamble().GetName(), n+1)
+	}
+	return match, nil
+}
@@ -0,0 +1,362 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// FleetConfig lists the switches of a fleet benchmark. It is read from JSON
+// such as
+//
+//	{"devices": [
+//	  {"name": "leaf1", "address": "10.0.0.1:9559", "device_id": 1},
+//	  {"name": "spine1", "address": "10.0.1.1:9559", "device_id": 1,
+//	   "p4info": "spine.p4info.txt", "device_config": "spine.bin",
+//	   "ca_file": "ca.pem", "server_name": "spine1.lab"}
+//	]}
+type FleetConfig struct {
+	Devices []FleetDeviceConfig `json:"devices"`
+}
+
+// FleetDeviceConfig describes one switch of a FleetConfig.
+type FleetDeviceConfig struct {
+	// Name identifies the switch in results; it defaults to Address.
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	DeviceID uint64 `json:"device_id"`
+	// ElectionID defaults to 1.
+	ElectionID uint64 `json:"election_id"`
+	Role       uint64 `json:"role"`
+	// P4Info and DeviceConfig, if set, are pushed with VERIFY_AND_COMMIT
+	// before the run.
+	P4Info       string `json:"p4info"`
+	DeviceConfig string `json:"device_config"`
+
+	// TLS and credentials, as in ClientOptions
+	TLS                bool   `json:"tls"`
+	CAFile             string `json:"ca_file"`
+	CertFile           string `json:"cert_file"`
+	KeyFile            string `json:"key_file"`
+	ServerName         string `json:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	Token              string `json:"token"`
+}
+
+func (d FleetDeviceConfig) clientOptions() ClientOptions {
+	return ClientOptions{
+		TLS:                d.TLS,
+		CAFile:             d.CAFile,
+		CertFile:           d.CertFile,
+		KeyFile:            d.KeyFile,
+		ServerName:         d.ServerName,
+		InsecureSkipVerify: d.InsecureSkipVerify,
+		Token:              d.Token,
+	}
+}
+
+// LoadFleetConfig reads and checks the FleetConfig at path.
+func LoadFleetConfig(path string) (*FleetConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &FleetConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(config.Devices) == 0 {
+		return nil, fmt.Errorf("%s lists no devices", path)
+	}
+	names := make(map[string]bool)
+	for i := range config.Devices {
+		d := &config.Devices[i]
+		if d.Address == "" {
+			return nil, fmt.Errorf("%s: device %d has no address", path, i+1)
+		}
+		if d.Name == "" {
+			d.Name = d.Address
+		}
+		if d.ElectionID == 0 {
+			d.ElectionID = 1
+		}
+		if names[d.Name] {
+			return nil, fmt.Errorf("%s: device name %s is used twice", path, d.Name)
+		}
+		names[d.Name] = true
+	}
+	return config, nil
+}
+
+// FleetDevice is a connected, arbitrated switch of a Fleet.
+type FleetDevice struct {
+	Config FleetDeviceConfig
+	Client P4RuntimeClient
+}
+
+// Fleet holds one client per switch of a FleetConfig.
+type Fleet struct {
+	Devices []FleetDevice
+}
+
+// NewFleet connects to every switch of config at once, arbitrates and
+// pushes the configured pipelines. Clients are created with batchSize and
+// numThreads as in CreateOrGetP4RuntimeClient. If any switch fails, the
+// clients already set up are closed and the first error is returned.
+func NewFleet(config *FleetConfig, batchSize, numThreads int, arbitrationTimeout time.Duration) (*Fleet, error) {
+	// Options are per address, and several devices may share one
+	addressOptions := make(map[string]FleetDeviceConfig)
+	for _, d := range config.Devices {
+		if other, ok := addressOptions[d.Address]; ok {
+			if other.clientOptions() != d.clientOptions() {
+				return nil, fmt.Errorf("devices %s and %s share address %s but not TLS and credential options",
+					other.Name, d.Name, d.Address)
+			}
+			continue
+		}
+		addressOptions[d.Address] = d
+		if opts := d.clientOptions(); opts != (ClientOptions{}) {
+			if err := SetClientOptions(d.Address, opts); err != nil {
+				return nil, errors.Wrapf(err, "device %s", d.Name)
+			}
+		}
+	}
+
+	fleet := &Fleet{Devices: make([]FleetDevice, len(config.Devices))}
+	errs := make([]error, len(config.Devices))
+	var wg sync.WaitGroup
+	for i, d := range config.Devices {
+		wg.Add(1)
+		go func(i int, d FleetDeviceConfig) {
+			defer wg.Done()
+			fleet.Devices[i].Config = d
+			fleet.Devices[i].Client, errs[i] = connectFleetDevice(d, batchSize, numThreads, arbitrationTimeout)
+			if errs[i] != nil {
+				errs[i] = errors.Wrapf(errs[i], "device %s", d.Name)
+			}
+		}(i, d)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			fleet.Close()
+			return nil, err
+		}
+	}
+	return fleet, nil
+}
+
+func connectFleetDevice(d FleetDeviceConfig, batchSize, numThreads int, arbitrationTimeout time.Duration) (P4RuntimeClient, error) {
+	client, err := CreateOrGetP4RuntimeClient(d.Address, d.DeviceID, batchSize, numThreads)
+	if err != nil {
+		return nil, err
+	}
+	client.SetRole(d.Role)
+	if err := client.Arbitrate(p4.Uint128{Low: d.ElectionID}, arbitrationTimeout); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if d.P4Info != "" {
+		err := client.PushPipelineConfig(d.P4Info, d.DeviceConfig, p4.SetForwardingPipelineConfigRequest_VERIFY_AND_COMMIT)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// Close closes every client of the fleet.
+func (f *Fleet) Close() {
+	for _, d := range f.Devices {
+		if d.Client != nil {
+			d.Client.Close()
+		}
+	}
+}
+
+// FleetWorkload runs the benchmark against one switch. It must wait for the
+// responses of all the writes it submits before returning, so that their
+// traces are counted.
+type FleetWorkload func(device FleetDevice) error
+
+// DeviceResult is the outcome of a workload on one switch.
+type DeviceResult struct {
+	Name    string
+	Summary TraceSummary
+	Err     error // returned by the workload
+}
+
+// FleetResult merges the traces of a fleet run.
+type FleetResult struct {
+	Elapsed time.Duration
+	Devices []DeviceResult // in FleetConfig order
+	Fleet   TraceSummary   // all devices together
+}
+
+// Run runs workload against every switch at once and summarizes the write
+// traces of each switch and of the whole fleet. A switch's throughput is
+// over the time its own workload took; the fleet's is over the whole run.
+// The clients' write trace channels are taken over for the duration of the
+// run.
+func (f *Fleet) Run(workload FleetWorkload) FleetResult {
+	result := FleetResult{Devices: make([]DeviceResult, len(f.Devices))}
+	traces := make([][]WriteTrace, len(f.Devices))
+	elapsed := make([]time.Duration, len(f.Devices))
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i, d := range f.Devices {
+		wg.Add(1)
+		go func(i int, d FleetDevice) {
+			defer wg.Done()
+			result.Devices[i].Name = d.Config.Name
+			traces[i], result.Devices[i].Err = collectTraces(d.Client, func() error { return workload(d) })
+			elapsed[i] = time.Since(start)
+		}(i, d)
+	}
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+
+	var all []WriteTrace
+	for i := range f.Devices {
+		result.Devices[i].Summary = SummarizeTraces(traces[i], elapsed[i])
+		all = append(all, traces[i]...)
+	}
+	result.Fleet = SummarizeTraces(all, result.Elapsed)
+	return result
+}
+
+// collectTraces returns the write traces of client while run runs. Once run
+// returns it waits until there is a trace, or a dropped one, for every
+// write the client has sent since, as traces may still be on their way,
+// e.g. through the trace buffer.
+func collectTraces(client P4RuntimeClient, run func() error) ([]WriteTrace, error) {
+	traceChan := make(chan WriteTrace, 1000)
+	sentBefore, droppedBefore := tracedWrites(client.Stats())
+	done := make(chan struct{})
+	collected := make(chan []WriteTrace)
+	go func() {
+		var traces []WriteTrace
+		runDone := done
+		var recheck <-chan time.Time
+		for {
+			select {
+			case trace := <-traceChan:
+				traces = append(traces, trace)
+				continue
+			case <-runDone:
+				runDone = nil
+				ticker := time.NewTicker(10 * time.Millisecond)
+				defer ticker.Stop()
+				recheck = ticker.C
+			case <-recheck:
+			}
+			sent, dropped := tracedWrites(client.Stats())
+			if uint64(len(traces))+dropped-droppedBefore >= sent-sentBefore {
+				collected <- traces
+				return
+			}
+		}
+	}()
+	client.SetWriteTraceChan(traceChan)
+	err := run()
+	close(done)
+	traces := <-collected
+	client.SetWriteTraceChan(nil)
+	return traces, err
+}
+
+// tracedWrites returns the writes the client has sent, each of which is
+// traced, and the traces it has dropped.
+func tracedWrites(stats Stats) (sent, dropped uint64) {
+	for _, pipeline := range stats.Pipelines {
+		sent += pipeline.Requests
+	}
+	return sent, stats.TracesDropped
+}
+
+// TraceSummary aggregates a set of write traces.
+type TraceSummary struct {
+	Writes        int            // Write RPCs
+	Updates       int            // updates they carried
+	FailedUpdates int            // updates that did not return OK
+	Latency       LatencySummary // per-RPC latency
+	// Throughput is the successful updates per second over the elapsed
+	// time given to SummarizeTraces.
+	Throughput float64
+	// Codes counts the failed updates by canonical code.
+	Codes map[codes.Code]int
+}
+
+// ErrorRate returns the fraction of updates that failed.
+func (s TraceSummary) ErrorRate() float64 {
+	if s.Updates == 0 {
+		return 0
+	}
+	return float64(s.FailedUpdates) / float64(s.Updates)
+}
+
+// SummarizeTraces summarizes traces collected over elapsed.
+func SummarizeTraces(traces []WriteTrace, elapsed time.Duration) TraceSummary {
+	summary := TraceSummary{Writes: len(traces), Codes: make(map[codes.Code]int)}
+	latencies := make([]time.Duration, len(traces))
+	for i, trace := range traces {
+		latencies[i] = trace.Duration
+		summary.Updates += trace.BatchSize
+		summary.FailedUpdates += trace.ErrorCount
+		for _, p4Err := range trace.Errors {
+			if code := codes.Code(p4Err.GetCanonicalCode()); code != codes.OK {
+				summary.Codes[code]++
+			}
+		}
+	}
+	summary.Latency = SummarizeLatencies(latencies)
+	if elapsed > 0 {
+		summary.Throughput = float64(summary.Updates-summary.FailedUpdates) / elapsed.Seconds()
+	}
+	return summary
+}
+
+func (s TraceSummary) String() string {
+	result := fmt.Sprintf("%d writes, %d updates (%d failed, %.2f%%), %.1f updates/sec, latency %v",
+		s.Writes, s.Updates, s.FailedUpdates, 100*s.ErrorRate(), s.Throughput, s.Latency)
+	if len(s.Codes) > 0 {
+		failures := make([]codes.Code, 0, len(s.Codes))
+		for code := range s.Codes {
+			failures = append(failures, code)
+		}
+		sort.Slice(failures, func(i, j int) bool { return failures[i] < failures[j] })
+		result += ", failures:"
+		for _, code := range failures {
+			result += fmt.Sprintf(" %v=%d", code, s.Codes[code])
+		}
+	}
+	return result
+}
+
+// WriteReport writes one line per device and a fleet-wide line to w.
+func (r FleetResult) WriteReport(w io.Writer) error {
+	for _, d := range r.Devices {
+		line := fmt.Sprintf("%s: %v\n", d.Name, d.Summary)
+		if d.Err != nil {
+			line = fmt.Sprintf("%s: workload failed: %v; %v\n", d.Name, d.Err, d.Summary)
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "fleet (%d devices) in %v: %v\n", len(r.Devices), r.Elapsed, r.Fleet)
+	return err
+}
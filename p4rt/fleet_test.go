@@ -0,0 +1,36 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"testing"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// TestCollectTracesThroughBuffer checks that collectTraces waits for traces
+// still in the trace buffer once the workload has its responses.
+func TestCollectTracesThroughBuffer(t *testing.T) {
+	const writes = 200
+	c, _ := newTestClient(t, 1, 4)
+	c.SetTraceBuffer(1, writes)
+
+	traces, err := collectTraces(c, func() error {
+		var responses []<-chan []*p4.Error
+		for i := 0; i < writes; i++ {
+			responses = append(responses, c.Write(insertRequest(c, 1)))
+		}
+		for _, res := range responses {
+			<-res
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped := c.Stats().TracesDropped; len(traces)+int(dropped) != writes {
+		t.Errorf("collected %d traces and %d were dropped, want %d in all", len(traces), dropped, writes)
+	}
+}
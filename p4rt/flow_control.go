@@ -0,0 +1,50 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+)
+
+// SetMaxInFlightBytes caps the total proto.Size of writes that have been
+// accepted by Write but not yet answered by the switch. Write blocks until
+// the new request fits under the cap; a request larger than the cap is let
+// through alone once nothing else is in flight. Zero (the default) removes
+// the cap.
+func (c *p4rtClient) SetMaxInFlightBytes(n int64) {
+	c.flightMu.Lock()
+	defer c.flightMu.Unlock()
+	c.maxInFlightBytes = n
+	c.flightCond.Broadcast()
+}
+
+// acquireBytes blocks until n more bytes may be in flight, then counts them.
+// It gives up waiting once the client context or ctx (which may be nil) is
+// done, so that Write can fail the request instead of hanging.
+func (c *p4rtClient) acquireBytes(ctx context.Context, n int64) {
+	c.flightMu.Lock()
+	defer c.flightMu.Unlock()
+	for c.maxInFlightBytes > 0 && c.inFlightBytes > 0 && c.inFlightBytes+n > c.maxInFlightBytes &&
+		c.rootContext().Err() == nil && (ctx == nil || ctx.Err() == nil) {
+		c.flightCond.Wait()
+	}
+	c.inFlightBytes += n
+}
+
+// releaseBytes returns n bytes to the in-flight budget.
+func (c *p4rtClient) releaseBytes(n int64) {
+	c.flightMu.Lock()
+	defer c.flightMu.Unlock()
+	c.inFlightBytes -= n
+	c.flightCond.Broadcast()
+}
+
+// wakeFlowControl wakes writes waiting for in-flight budget, so they can
+// notice that the client context is done.
+func (c *p4rtClient) wakeFlowControl() {
+	c.flightMu.Lock()
+	defer c.flightMu.Unlock()
+	c.flightCond.Broadcast()
+}
@@ -0,0 +1,75 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const readyPollInterval = 500 * time.Millisecond
+
+// Ping calls the Capabilities RPC and returns nil if the switch answers
+// before ctx expires. It does not change any switch state. A server that
+// does not implement Capabilities is still considered reachable.
+func (c *p4rtClient) Ping(ctx context.Context) error {
+	return c.ping(ctx)
+}
+
+// WaitForReady blocks until the gRPC connection is up and the P4Runtime
+// server answers Ping, or until ctx is done.
+func (c *p4rtClient) WaitForReady(ctx context.Context) error {
+	for {
+		// WaitForReady makes the RPC wait for the transport instead of
+		// failing fast while the connection is still being established
+		err := c.ping(ctx, grpc.WaitForReady(true))
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(err, "switch not ready")
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
+
+// RTTStats summarizes control-plane round-trip times measured by PingRTT.
+type RTTStats struct {
+	LatencySummary
+}
+
+// PingRTT times samples consecutive Ping calls. The result is a baseline
+// round-trip time that involves no table programming, to be subtracted from
+// write latencies.
+func (c *p4rtClient) PingRTT(ctx context.Context, samples int) (RTTStats, error) {
+	if samples < 1 {
+		return RTTStats{}, fmt.Errorf("invalid number of samples %d", samples)
+	}
+	rtts := make([]time.Duration, samples)
+	for i := range rtts {
+		start := time.Now()
+		if err := c.ping(ctx); err != nil {
+			return RTTStats{}, errors.Wrapf(err, "ping %d of %d", i+1, samples)
+		}
+		rtts[i] = time.Since(start)
+	}
+	return RTTStats{SummarizeLatencies(rtts)}, nil
+}
+
+func (c *p4rtClient) ping(ctx context.Context, opts ...grpc.CallOption) error {
+	_, err := c.client.Capabilities(ctx, &p4.CapabilitiesRequest{}, opts...)
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// IDSpace partitions a match key space of a given bit width across a fixed
+// number of workers. Each worker owns the keys whose high bits equal its
+// index, so keys generated by different workers never collide.
+type IDSpace struct {
+	bitwidth   int
+	workers    int
+	workerBits int
+}
+
+// NewIDSpace returns an IDSpace over bitwidth-bit keys (at most 64) split
+// across workers partitions.
+func NewIDSpace(bitwidth int, workers int) (*IDSpace, error) {
+	if bitwidth < 1 || bitwidth > 64 {
+		return nil, fmt.Errorf("invalid key bitwidth %d", bitwidth)
+	}
+	if workers < 1 {
+		return nil, fmt.Errorf("invalid number of workers %d", workers)
+	}
+	workerBits := bits.Len(uint(workers - 1))
+	if workerBits >= bitwidth {
+		return nil, fmt.Errorf("%d-bit key space is too small for %d workers", bitwidth, workers)
+	}
+	return &IDSpace{bitwidth: bitwidth, workers: workers, workerBits: workerBits}, nil
+}
+
+// PerWorker returns the number of keys available to each worker. A 64-bit
+// space of one worker holds 2^64 keys, one more than a uint64 can count;
+// PerWorker then returns math.MaxUint64.
+func (s *IDSpace) PerWorker() uint64 {
+	if s.keyBits() >= 64 {
+		return math.MaxUint64
+	}
+	return 1 << uint(s.keyBits())
+}
+
+// keyBits is the number of low bits of a key that a worker numbers.
+func (s *IDSpace) keyBits() int {
+	return s.bitwidth - s.workerBits
+}
+
+// Key returns worker's n-th key.
+func (s *IDSpace) Key(worker int, n uint64) (uint64, error) {
+	if worker < 0 || worker >= s.workers {
+		return 0, fmt.Errorf("worker %d out of range [0, %d)", worker, s.workers)
+	}
+	// Every n is a key of a 64-bit worker
+	if s.keyBits() < 64 && n >= s.PerWorker() {
+		return 0, fmt.Errorf("worker %d exhausted its %d keys", worker, s.PerWorker())
+	}
+	return uint64(worker)<<uint(s.keyBits()) | n, nil
+}
+
+// KeyBytes returns Key encoded as a big-endian bytestring of the key
+// space's width, ready to use as a match value.
+func (s *IDSpace) KeyBytes(worker int, n uint64) ([]byte, error) {
+	key, err := s.Key(worker, n)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, (s.bitwidth+7)/8)
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = byte(key)
+		key >>= 8
+	}
+	return b, nil
+}
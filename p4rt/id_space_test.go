@@ -0,0 +1,65 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestIDSpace(t *testing.T) {
+	s, err := NewIDSpace(16, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.PerWorker(); n != 1<<14 {
+		t.Errorf("PerWorker() = %d, want %d", n, 1<<14)
+	}
+	if key, err := s.Key(2, 5); err != nil || key != 2<<14|5 {
+		t.Errorf("Key(2, 5) = %#x, %v, want %#x", key, err, 2<<14|5)
+	}
+	if b, err := s.KeyBytes(1, 1); err != nil || !bytes.Equal(b, []byte{0x40, 0x01}) {
+		t.Errorf("KeyBytes(1, 1) = %x, %v, want 4001", b, err)
+	}
+	if _, err := s.Key(0, 1<<14); err == nil {
+		t.Error("key past the worker's partition was accepted")
+	}
+	if _, err := s.Key(3, 0); err == nil {
+		t.Error("key of worker out of range was accepted")
+	}
+}
+
+// TestIDSpace64 checks a 64-bit single-worker space, whose 2^64 keys
+// overflow a uint64 count.
+func TestIDSpace64(t *testing.T) {
+	s, err := NewIDSpace(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.PerWorker(); n != math.MaxUint64 {
+		t.Errorf("PerWorker() = %d, want %d", n, uint64(math.MaxUint64))
+	}
+	for _, n := range []uint64{0, 5, math.MaxUint64} {
+		if key, err := s.Key(0, n); err != nil || key != n {
+			t.Errorf("Key(0, %d) = %d, %v, want %d", n, key, err, n)
+		}
+	}
+	if b, err := s.KeyBytes(0, 5); err != nil || !bytes.Equal(b, []byte{0, 0, 0, 0, 0, 0, 0, 5}) {
+		t.Errorf("KeyBytes(0, 5) = %x, %v", b, err)
+	}
+
+	// Two workers leave 63 bits each
+	s, err = NewIDSpace(64, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := s.Key(1, 5); err != nil || key != 1<<63|5 {
+		t.Errorf("Key(1, 5) = %#x, %v, want %#x", key, err, uint64(1<<63|5))
+	}
+	if _, err := s.Key(1, 1<<63); err == nil {
+		t.Error("key past the worker's partition was accepted")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// LatencySummary summarizes a set of latency samples.
+type LatencySummary struct {
+	Count int
+	Min   time.Duration
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// SummarizeLatencies computes a LatencySummary. Percentiles use the
+// nearest-rank method. samples is not modified.
+func SummarizeLatencies(samples []time.Duration) LatencySummary {
+	if len(samples) == 0 {
+		return LatencySummary{}
+	}
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	return LatencySummary{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Mean:  total / time.Duration(len(sorted)),
+		P50:   percentile(sorted, 50),
+		P90:   percentile(sorted, 90),
+		P95:   percentile(sorted, 95),
+		P99:   percentile(sorted, 99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted samples:
+// the smallest sample at least p percent of the samples are at or below.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	// Multiplying first keeps whole ranks exact, so they are not rounded up
+	rank := int(math.Ceil(p*float64(len(sorted))/100)) - 1
+	if rank < 0 {
+		rank = 0
+	} else if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func (s LatencySummary) String() string {
+	return fmt.Sprintf("n=%d min=%v mean=%v p50=%v p90=%v p95=%v p99=%v max=%v",
+		s.Count, s.Min, s.Mean, s.P50, s.P90, s.P95, s.P99, s.Max)
+}
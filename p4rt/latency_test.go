@@ -0,0 +1,48 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSummarizeLatenciesNearestRank pins percentiles of small sample sets,
+// where rounding the rank instead of taking its ceiling picks a sample
+// too low.
+func TestSummarizeLatenciesNearestRank(t *testing.T) {
+	samples := func(n int) []time.Duration {
+		s := make([]time.Duration, n)
+		for i := range s {
+			// Reversed, to check the samples are sorted
+			s[i] = time.Duration(n-i) * time.Millisecond
+		}
+		return s
+	}
+	for _, tc := range []struct {
+		n                  int
+		p50, p90, p95, p99 int // 1-based ranks
+	}{
+		{n: 1, p50: 1, p90: 1, p95: 1, p99: 1},
+		{n: 7, p50: 4, p90: 7, p95: 7, p99: 7},
+		{n: 10, p50: 5, p90: 9, p95: 10, p99: 10},
+		{n: 20, p50: 10, p90: 18, p95: 19, p99: 20},
+		{n: 100, p50: 50, p90: 90, p95: 95, p99: 99},
+		{n: 101, p50: 51, p90: 91, p95: 96, p99: 100},
+	} {
+		s := SummarizeLatencies(samples(tc.n))
+		rank := func(d time.Duration) int { return int(d / time.Millisecond) }
+		if rank(s.P50) != tc.p50 || rank(s.P90) != tc.p90 || rank(s.P95) != tc.p95 || rank(s.P99) != tc.p99 {
+			t.Errorf("n=%d: p50, p90, p95, p99 are samples %d, %d, %d, %d, want %d, %d, %d, %d", tc.n,
+				rank(s.P50), rank(s.P90), rank(s.P95), rank(s.P99), tc.p50, tc.p90, tc.p95, tc.p99)
+		}
+		if s.Min != time.Millisecond || s.Max != time.Duration(tc.n)*time.Millisecond {
+			t.Errorf("n=%d: min %v and max %v", tc.n, s.Min, s.Max)
+		}
+	}
+	if s := SummarizeLatencies(nil); s != (LatencySummary{}) {
+		t.Errorf("summary of no samples = %v", s)
+	}
+}
@@ -5,19 +5,242 @@
 package p4rt
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func (c *p4rtClient) SetMastership(electionID p4.Uint128) (err error) {
+	c.mu.Lock()
 	c.electionID = electionID
+	c.arbitrated = true
+	c.mu.Unlock()
+	c.streamSendMu.Lock()
+	err = c.stream.Send(c.arbitrationRequest(electionID))
+	c.streamSendMu.Unlock()
+	return
+}
+
+// arbitrationRequest returns the MasterArbitrationUpdate for electionID and
+// the client's role.
+func (c *p4rtClient) arbitrationRequest(electionID p4.Uint128) *p4.StreamMessageRequest {
 	mastershipReq := &p4.StreamMessageRequest{
 		Update: &p4.StreamMessageRequest_Arbitration{
 			Arbitration: &p4.MasterArbitrationUpdate{
-				DeviceId:   1,
+				DeviceId:   c.deviceID,
 				ElectionId: &electionID,
 			},
 		},
 	}
-	err = c.stream.Send(mastershipReq)
-	return
+	if roleID := c.getRoleID(); roleID != 0 {
+		mastershipReq.GetArbitration().Role = &p4.Role{Id: roleID}
+	}
+	return mastershipReq
+}
+
+// SetArbitrationOptional lets Arbitrate succeed against targets that do not
+// implement StreamChannel. When arbitration fails with codes.Unimplemented,
+// a warning is logged and writes are stamped with the requested election ID
+// without the switch having confirmed mastership.
+func (c *p4rtClient) SetArbitrationOptional(optional bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.arbitrationOptional = optional
+}
+
+// Arbitrate sends a MasterArbitrationUpdate for electionID and waits up to
+// timeout for the switch to answer. It returns nil only if this client is
+// now master for the device, or if the target lacks StreamChannel and
+// SetArbitrationOptional is on.
+func (c *p4rtClient) Arbitrate(electionID p4.Uint128, timeout time.Duration) error {
+	arb, err := c.arbitrate(electionID, timeout)
+	if err != nil {
+		return err
+	}
+	if arb != nil && code.Code(arb.GetStatus().GetCode()) != code.Code_OK {
+		return fmt.Errorf("client is not master for device %d: %s",
+			c.deviceID, arb.GetStatus().GetMessage())
+	}
+	return nil
+}
+
+// arbitrate is Arbitrate, returning the switch's answer whether or not it
+// made this client master. The answer is nil if arbitration was skipped.
+func (c *p4rtClient) arbitrate(electionID p4.Uint128, timeout time.Duration) (*p4.MasterArbitrationUpdate, error) {
+	// Discard any update left over from an earlier arbitration
+	select {
+	case <-c.arbitrations:
+	default:
+	}
+
+	start := time.Now()
+	if err := c.SetMastership(electionID); err != nil {
+		// A failed Send only reports io.EOF; the stream error says why
+		select {
+		case <-c.streamDone:
+			if c.skipArbitration() {
+				return nil, nil
+			}
+		default:
+		}
+		return nil, errors.Wrap(err, "error sending MasterArbitrationUpdate")
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case arb := <-c.arbitrations:
+		c.mu.Lock()
+		c.arbitrationDuration = time.Since(start)
+		c.mu.Unlock()
+		return arb, nil
+	case <-c.streamDone:
+		if c.skipArbitration() {
+			return nil, nil
+		}
+		return nil, errors.Wrap(c.streamErr, "stream closed during arbitration")
+	case <-timer.C:
+		return nil, fmt.Errorf("no MasterArbitrationUpdate received within %v; is the device ID (%d) correct?",
+			timeout, c.deviceID)
+	}
+}
+
+// skipArbitration reports whether the closed stream failed because the target
+// does not implement StreamChannel and arbitration is optional.
+func (c *p4rtClient) skipArbitration() bool {
+	c.mu.RLock()
+	optional := c.arbitrationOptional
+	c.mu.RUnlock()
+	if !optional || status.Code(c.streamErr) != codes.Unimplemented {
+		return false
+	}
+	fmt.Printf("WARNING: device %d does not implement StreamChannel; writing with election ID %v without arbitration\n",
+		c.deviceID, c.ElectionID())
+	// Nothing will ever confirm mastership, so do not hold back writes
+	c.mu.Lock()
+	c.setPrimaryLocked(true)
+	c.mu.Unlock()
+	return true
+}
+
+// MastershipChange reports that this client became primary or lost primary
+// status for its role.
+type MastershipChange struct {
+	Time    time.Time
+	Primary bool
+	// ElectionID is the highest election ID the switch has seen, i.e. the
+	// current primary's.
+	ElectionID p4.Uint128
+	// Message is the switch's status message, which says why this client
+	// is not primary.
+	Message string
+}
+
+// SetRole arbitrates for role roleID instead of the default role, and
+// stamps it on writes that do not set a role themselves. It takes effect
+// with the next Arbitrate. Zero means the default role.
+func (c *p4rtClient) SetRole(roleID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roleID = roleID
+}
+
+func (c *p4rtClient) getRoleID() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.roleID
+}
+
+// SetMastershipChan delivers a MastershipChange on ch whenever this client
+// gains or loses primary status. Sends never block the stream; a change is
+// dropped if ch is full. Passing nil stops delivery.
+func (c *p4rtClient) SetMastershipChan(ch chan MastershipChange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mastershipChan = ch
+}
+
+// IsPrimary reports whether the switch last told this client it is primary.
+func (c *p4rtClient) IsPrimary() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.primary
+}
+
+// SetQueueWritesUntilPrimary makes the client run as a backup controller:
+// while it is not primary, writes wait in the write queue instead of being
+// sent and failing with PERMISSION_DENIED. Once the queue is full, Write
+// blocks. Writes are released, in order, when the client becomes primary,
+// and fail with codes.Canceled if the client context ends first.
+func (c *p4rtClient) SetQueueWritesUntilPrimary(queue bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queueUntilPrimary = queue
+}
+
+// waitForPrimary blocks while writes are queued for a backup client. It
+// returns errStreamClosed if the stream closes for good first, since the
+// client can then never become primary.
+func (c *p4rtClient) waitForPrimary(ctx context.Context) error {
+	c.mu.RLock()
+	queue := c.queueUntilPrimary
+	becamePrimary := c.becamePrimary
+	c.mu.RUnlock()
+	if !queue {
+		return nil
+	}
+	select {
+	case <-becamePrimary:
+		return nil
+	case <-c.streamDone:
+		return errStreamClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// updateMastership records the primary status carried by arb and reports a
+// change, if any.
+func (c *p4rtClient) updateMastership(arb *p4.MasterArbitrationUpdate) {
+	primary := code.Code(arb.GetStatus().GetCode()) == code.Code_OK
+	c.mu.Lock()
+	changed := c.setPrimaryLocked(primary)
+	ch := c.mastershipChan
+	c.mu.Unlock()
+	if !changed || ch == nil {
+		return
+	}
+	change := MastershipChange{
+		Time:    time.Now(),
+		Primary: primary,
+		Message: arb.GetStatus().GetMessage(),
+	}
+	if arb.GetElectionId() != nil {
+		change.ElectionID = *arb.GetElectionId()
+	}
+	select {
+	case ch <- change:
+	default:
+	}
+}
+
+// setPrimaryLocked must be called with c.mu held. It reports whether the
+// primary status changed.
+func (c *p4rtClient) setPrimaryLocked(primary bool) bool {
+	if primary == c.primary {
+		return false
+	}
+	c.primary = primary
+	if primary {
+		close(c.becamePrimary)
+	} else {
+		c.becamePrimary = make(chan struct{})
+	}
+	return true
 }
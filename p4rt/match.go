@@ -0,0 +1,230 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// MatchValue is a match field value whose shape is given by its syntax:
+//
+//	"value"           a single value
+//	"value/len"       a prefix
+//	"value&&&mask"    a masked value
+//	"low..high"       a range
+//
+// Values are parsed with EncodeValue. The field's match type, looked up in
+// the P4Info, decides which FieldMatch is built: a single value also fits
+// LPM (full length), ternary (full mask) and range (low == high) fields,
+// but the other shapes only fit their own match type.
+type MatchValue string
+
+func (v MatchValue) shape() string {
+	s := string(v)
+	switch {
+	case strings.Contains(s, "&&&"):
+		return "masked"
+	case strings.Contains(s, ".."):
+		return "range"
+	case strings.Contains(s, "/"):
+		return "prefix"
+	default:
+		return "single"
+	}
+}
+
+// fieldMatch builds the FieldMatch of mf's match type for v.
+func (v MatchValue) fieldMatch(mf *p4_config.MatchField) (*p4.FieldMatch, error) {
+	s := strings.TrimSpace(string(v))
+	bitwidth := mf.GetBitwidth()
+	shape := v.shape()
+	mismatch := func() error {
+		return fmt.Errorf("%s value %q does not fit %v match field %s", shape, s, mf.GetMatchType(), mf.GetName())
+	}
+
+	fm := &p4.FieldMatch{FieldId: mf.GetId()}
+	switch mf.GetMatchType() {
+	case p4_config.MatchField_EXACT, p4_config.MatchField_OPTIONAL:
+		if shape != "single" {
+			return nil, mismatch()
+		}
+		value, err := EncodeValue(s, bitwidth)
+		if err != nil {
+			return nil, err
+		}
+		if mf.GetMatchType() == p4_config.MatchField_EXACT {
+			fm.FieldMatchType = &p4.FieldMatch_Exact_{Exact: &p4.FieldMatch_Exact{Value: value}}
+		} else {
+			fm.FieldMatchType = &p4.FieldMatch_Optional_{Optional: &p4.FieldMatch_Optional{Value: value}}
+		}
+	case p4_config.MatchField_LPM:
+		if shape != "single" && shape != "prefix" {
+			return nil, mismatch()
+		}
+		parts := strings.SplitN(s, "/", 2)
+		prefixLen := int64(bitwidth)
+		if len(parts) == 2 {
+			var err error
+			prefixLen, err = strconv.ParseInt(parts[1], 10, 32)
+			if err != nil || prefixLen < 0 || prefixLen > int64(bitwidth) {
+				return nil, fmt.Errorf("invalid prefix length %q for match field %s", parts[1], mf.GetName())
+			}
+		}
+		value, err := EncodeValue(parts[0], bitwidth)
+		if err != nil {
+			return nil, err
+		}
+		fm.FieldMatchType = &p4.FieldMatch_Lpm{Lpm: &p4.FieldMatch_LPM{Value: value, PrefixLen: int32(prefixLen)}}
+	case p4_config.MatchField_TERNARY:
+		if shape != "single" && shape != "masked" {
+			return nil, mismatch()
+		}
+		parts := strings.SplitN(s, "&&&", 2)
+		value, err := EncodeValue(parts[0], bitwidth)
+		if err != nil {
+			return nil, err
+		}
+		mask := make([]byte, len(value))
+		for i := range mask {
+			mask[i] = 0xff
+		}
+		mask[0] >>= uint(len(mask)*8 - int(bitwidth))
+		if len(parts) == 2 {
+			if mask, err = EncodeValue(parts[1], bitwidth); err != nil {
+				return nil, err
+			}
+		}
+		fm.FieldMatchType = &p4.FieldMatch_Ternary_{Ternary: &p4.FieldMatch_Ternary{Value: value, Mask: mask}}
+	case p4_config.MatchField_RANGE:
+		if shape != "single" && shape != "range" {
+			return nil, mismatch()
+		}
+		parts := strings.SplitN(s, "..", 2)
+		low, err := EncodeValue(parts[0], bitwidth)
+		if err != nil {
+			return nil, err
+		}
+		high := low
+		if len(parts) == 2 {
+			if high, err = EncodeValue(parts[1], bitwidth); err != nil {
+				return nil, err
+			}
+		}
+		fm.FieldMatchType = &p4.FieldMatch_Range_{Range: &p4.FieldMatch_Range{Low: low, High: high}}
+	default:
+		return nil, fmt.Errorf("unsupported match type %v for match field %s", mf.GetMatchType(), mf.GetName())
+	}
+	return fm, nil
+}
+
+// TableEntry builds an entry of tableName from names and text values. The
+// FieldMatch of each field in match is chosen from the field's match type
+// in the P4Info. Exact and LPM fields are required; ternary, range and
+// optional fields left out are wildcards. metadata, if not nil, is the
+// entry's controller cookie (TableEntry.metadata), which the switch returns
+// unchanged on reads. Parameters of actionName are parsed with EncodeValue,
+// and all of them must be given.
+func (p4infoHelper *P4InfoHelper) TableEntry(tableName string, match map[string]MatchValue, priority int32,
+	metadata []byte, actionName string, params map[string]string) (*p4.TableEntry, error) {
+	table, err := p4infoHelper.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	action, err := p4infoHelper.GetAction(actionName)
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	for _, ref := range table.GetActionRefs() {
+		if ref.GetId() == action.GetPreamble().GetId() && ref.GetScope() != p4_config.ActionRef_DEFAULT_ONLY {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("action %s cannot be used by entries of table %s", actionName, tableName)
+	}
+
+	entry := &p4.TableEntry{TableId: table.GetPreamble().GetId(), Priority: priority, Metadata: metadata}
+	if entry.Match, err = buildMatch(table, match); err != nil {
+		return nil, err
+	}
+	p4Action, err := buildAction(action, params)
+	if err != nil {
+		return nil, err
+	}
+	entry.Action = &p4.TableAction{Type: &p4.TableAction_Action{Action: p4Action}}
+	return entry, nil
+}
+
+// buildMatch builds the FieldMatches of an entry of table. Exact and LPM
+// fields are required; the others are wildcards if left out.
+func buildMatch(table *p4_config.Table, match map[string]MatchValue) ([]*p4.FieldMatch, error) {
+	var fms []*p4.FieldMatch
+	used := 0
+	for _, mf := range table.GetMatchFields() {
+		value, ok := match[mf.GetName()]
+		if !ok {
+			if mf.GetMatchType() == p4_config.MatchField_EXACT || mf.GetMatchType() == p4_config.MatchField_LPM {
+				return nil, fmt.Errorf("missing value for match field %s", mf.GetName())
+			}
+			continue
+		}
+		used++
+		fm, err := value.fieldMatch(mf)
+		if err != nil {
+			return nil, err
+		}
+		fms = append(fms, fm)
+	}
+	if used != len(match) {
+		for name := range match {
+			if !hasMatchField(table, name) {
+				return nil, fmt.Errorf("Unable to find match field %s in table %s", name, table.GetPreamble().GetName())
+			}
+		}
+	}
+	return fms, nil
+}
+
+// buildAction builds a call of action with text parameters, parsed with
+// EncodeValue. All of the action's parameters must be given, and no others.
+func buildAction(action *p4_config.Action, params map[string]string) (*p4.Action, error) {
+	actionName := action.GetPreamble().GetName()
+	encoded := make(map[string][]byte, len(params))
+	for _, param := range action.GetParams() {
+		text, ok := params[param.GetName()]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter %s for action %s", param.GetName(), actionName)
+		}
+		var err error
+		if encoded[param.GetName()], err = EncodeValue(text, param.GetBitwidth()); err != nil {
+			return nil, fmt.Errorf("parameter %s: %v", param.GetName(), err)
+		}
+	}
+	for name := range params {
+		if _, ok := encoded[name]; !ok {
+			return nil, fmt.Errorf("action %s has no parameter %s", actionName, name)
+		}
+	}
+	actionParams, err := buildActionParams(action, encoded)
+	if err != nil {
+		return nil, err
+	}
+	return &p4.Action{ActionId: action.GetPreamble().GetId(), Params: actionParams}, nil
+}
+
+func hasMatchField(table *p4_config.Table, name string) bool {
+	for _, mf := range table.GetMatchFields() {
+		if mf.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"bytes"
+	"testing"
+
+	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
+)
+
+// testP4Info has table "routes", matching an 8-bit exact "port" and a
+// 32-bit LPM "dst", with action "forward" taking an 8-bit "out".
+func testP4Info() *P4InfoHelper {
+	helper := &P4InfoHelper{}
+	helper.InitFromP4Info(p4_config.P4Info{
+		Tables: []*p4_config.Table{{
+			Preamble: &p4_config.Preamble{Id: 1, Name: "routes"},
+			MatchFields: []*p4_config.MatchField{
+				{Id: 1, Name: "port", Bitwidth: 8, Match: &p4_config.MatchField_MatchType_{MatchType: p4_config.MatchField_EXACT}},
+				{Id: 2, Name: "dst", Bitwidth: 32, Match: &p4_config.MatchField_MatchType_{MatchType: p4_config.MatchField_LPM}},
+			},
+			ActionRefs: []*p4_config.ActionRef{{Id: 10}},
+		}},
+		Actions: []*p4_config.Action{{
+			Preamble: &p4_config.Preamble{Id: 10, Name: "forward"},
+			Params:   []*p4_config.Action_Param{{Id: 1, Name: "out", Bitwidth: 8}},
+		}},
+	})
+	return helper
+}
+
+func TestTableEntryMetadata(t *testing.T) {
+	helper := testP4Info()
+	match := map[string]MatchValue{"port": "1", "dst": "10.0.0.0/8"}
+	params := map[string]string{"out": "2"}
+
+	entry, err := helper.TableEntry("routes", match, 0, []byte("gen-7"), "forward", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(entry.GetMetadata(), []byte("gen-7")) {
+		t.Errorf("metadata = %q, want %q", entry.GetMetadata(), "gen-7")
+	}
+	if entry, err = helper.TableEntry("routes", match, 0, nil, "forward", params); err != nil {
+		t.Fatal(err)
+	}
+	if entry.GetMetadata() != nil {
+		t.Errorf("metadata = %q, want none", entry.GetMetadata())
+	}
+}
@@ -3,16 +3,50 @@ package p4rt
 import (
 	"fmt"
 	"io/ioutil"
+
 	"github.com/golang/protobuf/proto"
 
 	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
 )
 
 type P4InfoHelper struct {
-	nameToP4ID	map[string]uint32  // P4 name to P4 ID.
+	p4info     p4_config.P4Info
+	nameToP4ID map[string]uint32 // P4 name to P4 ID.
+
+	tables    map[string]*p4_config.Table
+	actions   map[string]*p4_config.Action
+	actionIDs map[uint32]*p4_config.Action
+	counters  map[string]*p4_config.Counter
+	valueSets map[string]*p4_config.ValueSet
+	profiles  map[string]*p4_config.ActionProfile
+	meters    map[string]*p4_config.Meter
+	digests   map[string]*p4_config.Digest
+}
+
+// TableInfo summarizes a table declared in the P4Info.
+type TableInfo struct {
+	Name        string
+	ID          uint32
+	MatchFields []MatchFieldInfo
+	Actions     []string // names of the actions the table may use
+	Size        int64    // maximum number of entries
+}
 
+// MatchFieldInfo describes one match field of a table.
+type MatchFieldInfo struct {
+	ID        uint32
+	Name      string
+	MatchType p4_config.MatchField_MatchType
+	Bitwidth  int32
 }
 
+// MetadataField describes one field of a controller packet header
+// (packet_in or packet_out), in header layout order.
+type MetadataField struct {
+	ID       uint32
+	Name     string
+	Bitwidth int32
+}
 
 func LoadP4Info(p4infoPath string) (p4info p4_config.P4Info, err error) {
 	fmt.Printf("P4 Info: %s\n", p4infoPath)
@@ -21,32 +55,188 @@ func LoadP4Info(p4infoPath string) (p4info p4_config.P4Info, err error) {
 	if err != nil {
 		return
 	}
+	// Try text format first, as written by p4c, then binary
 	err = proto.UnmarshalText(string(p4infoBytes), &p4info)
+	if err != nil && proto.Unmarshal(p4infoBytes, &p4info) == nil {
+		err = nil
+	}
 	return
 }
 
 func (p4infoHelper *P4InfoHelper) Init(p4InfoPath string) (err error) {
-	var p4info	p4_config.P4Info
-	p4infoHelper.nameToP4ID = make(map[string]uint32)
-	p4info, err = LoadP4Info(p4InfoPath)
+	p4info, err := LoadP4Info(p4InfoPath)
 	if err != nil {
 		return
 	}
+	p4infoHelper.InitFromP4Info(p4info)
+	return
+}
+
+// InitFromP4Info initializes the helper from an already loaded P4Info.
+func (p4infoHelper *P4InfoHelper) InitFromP4Info(p4info p4_config.P4Info) {
+	p4infoHelper.p4info = p4info
+	p4infoHelper.nameToP4ID = make(map[string]uint32)
+	p4infoHelper.tables = make(map[string]*p4_config.Table)
+	p4infoHelper.actions = make(map[string]*p4_config.Action)
+	p4infoHelper.actionIDs = make(map[uint32]*p4_config.Action)
+	p4infoHelper.counters = make(map[string]*p4_config.Counter)
+	p4infoHelper.valueSets = make(map[string]*p4_config.ValueSet)
+	p4infoHelper.profiles = make(map[string]*p4_config.ActionProfile)
+	p4infoHelper.meters = make(map[string]*p4_config.Meter)
+	p4infoHelper.digests = make(map[string]*p4_config.Digest)
 
-	for _, table := range p4info.Tables {
+	for _, table := range p4infoHelper.p4info.Tables {
 		p4infoHelper.nameToP4ID[table.GetPreamble().Name] = table.GetPreamble().Id
+		p4infoHelper.tables[table.GetPreamble().GetName()] = table
 	}
 
-	for _, action := range p4info.Actions {
+	for _, action := range p4infoHelper.p4info.Actions {
 		p4infoHelper.nameToP4ID[action.GetPreamble().GetName()] = action.GetPreamble().GetId()
+		p4infoHelper.actions[action.GetPreamble().GetName()] = action
+		p4infoHelper.actionIDs[action.GetPreamble().GetId()] = action
+	}
+
+	for _, counter := range p4infoHelper.p4info.Counters {
+		p4infoHelper.nameToP4ID[counter.GetPreamble().GetName()] = counter.GetPreamble().GetId()
+		p4infoHelper.counters[counter.GetPreamble().GetName()] = counter
+	}
+
+	for _, valueSet := range p4infoHelper.p4info.ValueSets {
+		p4infoHelper.nameToP4ID[valueSet.GetPreamble().GetName()] = valueSet.GetPreamble().GetId()
+		p4infoHelper.valueSets[valueSet.GetPreamble().GetName()] = valueSet
+	}
+
+	for _, profile := range p4infoHelper.p4info.ActionProfiles {
+		p4infoHelper.nameToP4ID[profile.GetPreamble().GetName()] = profile.GetPreamble().GetId()
+		p4infoHelper.profiles[profile.GetPreamble().GetName()] = profile
+	}
+
+	for _, meter := range p4infoHelper.p4info.Meters {
+		p4infoHelper.nameToP4ID[meter.GetPreamble().GetName()] = meter.GetPreamble().GetId()
+		p4infoHelper.meters[meter.GetPreamble().GetName()] = meter
+	}
+
+	for _, digest := range p4infoHelper.p4info.Digests {
+		p4infoHelper.nameToP4ID[digest.GetPreamble().GetName()] = digest.GetPreamble().GetId()
+		p4infoHelper.digests[digest.GetPreamble().GetName()] = digest
 	}
-	return
 }
 
-func (p4infoHelper *P4InfoHelper) GetP4Id(name string) (p4ID uint32, err error){
+func (p4infoHelper *P4InfoHelper) GetP4Id(name string) (p4ID uint32, err error) {
 	p4ID, exists := p4infoHelper.nameToP4ID[name]
 	if !exists {
 		err = fmt.Errorf("Unable to find P4 ID for %s", name)
 	}
 	return
 }
+
+func (p4infoHelper *P4InfoHelper) GetTable(name string) (*p4_config.Table, error) {
+	table, exists := p4infoHelper.tables[name]
+	if !exists {
+		return nil, fmt.Errorf("Unable to find table %s", name)
+	}
+	return table, nil
+}
+
+func (p4infoHelper *P4InfoHelper) GetAction(name string) (*p4_config.Action, error) {
+	action, exists := p4infoHelper.actions[name]
+	if !exists {
+		return nil, fmt.Errorf("Unable to find action %s", name)
+	}
+	return action, nil
+}
+
+func (p4infoHelper *P4InfoHelper) GetCounter(name string) (*p4_config.Counter, error) {
+	counter, exists := p4infoHelper.counters[name]
+	if !exists {
+		return nil, fmt.Errorf("Unable to find counter %s", name)
+	}
+	return counter, nil
+}
+
+func (p4infoHelper *P4InfoHelper) GetValueSet(name string) (*p4_config.ValueSet, error) {
+	valueSet, exists := p4infoHelper.valueSets[name]
+	if !exists {
+		return nil, fmt.Errorf("Unable to find value set %s", name)
+	}
+	return valueSet, nil
+}
+
+func (p4infoHelper *P4InfoHelper) GetActionProfile(name string) (*p4_config.ActionProfile, error) {
+	profile, exists := p4infoHelper.profiles[name]
+	if !exists {
+		return nil, fmt.Errorf("Unable to find action profile %s", name)
+	}
+	return profile, nil
+}
+
+func (p4infoHelper *P4InfoHelper) GetMeter(name string) (*p4_config.Meter, error) {
+	meter, exists := p4infoHelper.meters[name]
+	if !exists {
+		return nil, fmt.Errorf("Unable to find meter %s", name)
+	}
+	return meter, nil
+}
+
+func (p4infoHelper *P4InfoHelper) GetDigest(name string) (*p4_config.Digest, error) {
+	digest, exists := p4infoHelper.digests[name]
+	if !exists {
+		return nil, fmt.Errorf("Unable to find digest %s", name)
+	}
+	return digest, nil
+}
+
+// Tables lists every table in the P4Info, in P4Info order.
+func (p4infoHelper *P4InfoHelper) Tables() []TableInfo {
+	tables := make([]TableInfo, 0, len(p4infoHelper.p4info.Tables))
+	for _, table := range p4infoHelper.p4info.Tables {
+		info := TableInfo{
+			Name: table.GetPreamble().GetName(),
+			ID:   table.GetPreamble().GetId(),
+			Size: table.GetSize(),
+		}
+		for _, mf := range table.GetMatchFields() {
+			info.MatchFields = append(info.MatchFields, MatchFieldInfo{
+				ID:        mf.GetId(),
+				Name:      mf.GetName(),
+				MatchType: mf.GetMatchType(),
+				Bitwidth:  mf.GetBitwidth(),
+			})
+		}
+		for _, ref := range table.GetActionRefs() {
+			if action, ok := p4infoHelper.actionIDs[ref.GetId()]; ok {
+				info.Actions = append(info.Actions, action.GetPreamble().GetName())
+			}
+		}
+		tables = append(tables, info)
+	}
+	return tables
+}
+
+// PacketInMetadata returns the packet_in metadata fields declared in the P4Info.
+func (p4infoHelper *P4InfoHelper) PacketInMetadata() ([]MetadataField, error) {
+	return p4infoHelper.controllerPacketMetadata("packet_in")
+}
+
+// PacketOutMetadata returns the packet_out metadata fields declared in the P4Info.
+func (p4infoHelper *P4InfoHelper) PacketOutMetadata() ([]MetadataField, error) {
+	return p4infoHelper.controllerPacketMetadata("packet_out")
+}
+
+func (p4infoHelper *P4InfoHelper) controllerPacketMetadata(header string) ([]MetadataField, error) {
+	for _, cpm := range p4infoHelper.p4info.ControllerPacketMetadata {
+		if cpm.GetPreamble().GetName() != header {
+			continue
+		}
+		fields := make([]MetadataField, len(cpm.Metadata))
+		for i, m := range cpm.Metadata {
+			fields[i] = MetadataField{
+				ID:       m.GetId(),
+				Name:     m.GetName(),
+				Bitwidth: m.GetBitwidth(),
+			}
+		}
+		return fields, nil
+	}
+	return nil, fmt.Errorf("Unable to find controller packet metadata for %s", header)
+}
@@ -0,0 +1,157 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// pacer is a token bucket measured in updates. Rather than counting tokens
+// it keeps the time at which the next update is due, so every write gets a
+// scheduled send time that does not depend on when it was dequeued.
+type pacer struct {
+	mu    sync.Mutex
+	rate  float64 // updates per second; zero disables pacing
+	burst float64 // updates that may go at once after an idle period
+	next  time.Time
+
+	// achieved rate, measured since the rate was last set
+	since time.Time
+	paced float64
+}
+
+// SetWriteRate paces Write RPCs to updatesPerSecond updates per second,
+// counting each request by its number of updates. Up to burst updates may
+// go out back to back after the client has been idle; a request with more
+// updates than burst is still sent whole. It may be called while writes
+// are running; writes already given a send time keep it. Zero (the
+// default) turns pacing off. Paced writes record WriteTrace.Scheduled,
+// RequestedRate and AchievedRate.
+func (c *p4rtClient) SetWriteRate(updatesPerSecond float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+	if updatesPerSecond < 0 {
+		updatesPerSecond = 0
+	}
+	c.pacer.set(updatesPerSecond, burst)
+}
+
+func (p *pacer) set(updatesPerSecond float64, burst int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rate = updatesPerSecond
+	p.burst = float64(burst)
+	p.since = time.Time{}
+	p.paced = 0
+}
+
+// SetRateLimitFor paces the writes made with WriteLabeled(label, ...) to
+// updatesPerSec updates per second, apart from SetWriteRate's rate: they no
+// longer count against it and it no longer holds them up. Unlabeled writes,
+// and labels without a limit of their own, keep using SetWriteRate's rate.
+// Zero removes the label's limit.
+func (c *p4rtClient) SetRateLimitFor(label string, updatesPerSec int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if updatesPerSec <= 0 {
+		delete(c.labelPacers, label)
+		return
+	}
+	p := c.labelPacers[label]
+	if p == nil {
+		if c.labelPacers == nil {
+			c.labelPacers = make(map[string]*pacer)
+		}
+		p = &pacer{}
+		c.labelPacers[label] = p
+	}
+	p.set(float64(updatesPerSec), 1)
+}
+
+// WriteLabeled is like Write, but tags the write with an operation label,
+// such as "meter" or "counter", which picks its SetRateLimitFor rate and is
+// recorded in WriteTrace.Label.
+func (c *p4rtClient) WriteLabeled(label string, req *p4.WriteRequest) <-chan []*p4.Error {
+	return c.submitWrite(nil, label, req, c.writes)
+}
+
+// settings returns the rate and burst last set with SetWriteRate.
+func (p *pacer) settings() (rate float64, burst int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rate, int(p.burst)
+}
+
+// pacing is the pacer's decision for one write.
+type pacing struct {
+	scheduled time.Time
+	requested float64
+	achieved  float64
+}
+
+// reserve books the next slot for n updates. The returned scheduled time is
+// zero if pacing is off.
+func (p *pacer) reserve(n int, now time.Time) pacing {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rate <= 0 {
+		return pacing{}
+	}
+	perUpdate := time.Duration(float64(time.Second) / p.rate)
+	// An idle client earns at most burst updates of credit
+	if earliest := now.Add(-time.Duration(p.burst) * perUpdate); p.next.Before(earliest) {
+		p.next = earliest
+	}
+	scheduled := p.next
+	p.next = p.next.Add(time.Duration(n) * perUpdate)
+
+	released := scheduled
+	if released.Before(now) {
+		released = now
+	}
+	if p.since.IsZero() {
+		p.since = released
+	}
+	p.paced += float64(n)
+	// Count this write as taking its own interval, so a steady stream at the
+	// requested rate measures exactly that rate
+	elapsed := released.Sub(p.since) + time.Duration(n)*perUpdate
+	return pacing{
+		scheduled: scheduled,
+		requested: p.rate,
+		achieved:  p.paced / elapsed.Seconds(),
+	}
+}
+
+// pace waits until the write of n updates with label is due, by the label's
+// pacer if it has one and the client's otherwise. It fails only if ctx is
+// done first.
+func (c *p4rtClient) pace(ctx context.Context, label string, n int) (pacing, error) {
+	p := &c.pacer
+	if label != "" {
+		c.mu.RLock()
+		if labelPacer := c.labelPacers[label]; labelPacer != nil {
+			p = labelPacer
+		}
+		c.mu.RUnlock()
+	}
+	now := time.Now()
+	decision := p.reserve(n, now)
+	if wait := decision.scheduled.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return decision, ctx.Err()
+		}
+	}
+	return decision, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"testing"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// TestSetRateLimitFor checks that a label's limit paces only its own
+// writes: unlabeled writes and labels without a limit use the client's.
+func TestSetRateLimitFor(t *testing.T) {
+	c, _ := newTestClient(t, 10, 4)
+	traces := make(chan WriteTrace, 100)
+	c.SetWriteTraceChan(traces)
+	c.SetRateLimitFor("meter", 500)
+
+	start := time.Now()
+	var responses []<-chan []*p4.Error
+	for i := 0; i < 5; i++ {
+		responses = append(responses, c.WriteLabeled("meter", insertRequest(c, 10)))
+		responses = append(responses, c.WriteLabeled("counter", insertRequest(c, 10)))
+		responses = append(responses, c.Write(insertRequest(c, 10)))
+	}
+	for _, res := range responses {
+		if failed := countFailed(<-res); failed != 0 {
+			t.Fatalf("%d updates failed", failed)
+		}
+	}
+	// 40 of the 50 meter updates wait for their slots
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("writes took %v, want about 80ms at 500 meter updates/sec", elapsed)
+	}
+	c.SetWriteTraceChan(nil)
+	close(traces)
+	labels := map[string]int{}
+	for trace := range traces {
+		labels[trace.Label]++
+		paced := trace.RequestedRate != 0
+		if paced != (trace.Label == "meter") {
+			t.Errorf("%q write paced at %v updates/sec", trace.Label, trace.RequestedRate)
+		}
+	}
+	if labels["meter"] != 5 || labels["counter"] != 5 || labels[""] != 5 {
+		t.Errorf("traces by label: %v, want 5 of each", labels)
+	}
+
+	c.SetRateLimitFor("meter", 0)
+	if _, ok := c.labelPacers["meter"]; ok {
+		t.Error("removing the limit left the label's pacer")
+	}
+}
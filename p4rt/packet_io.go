@@ -0,0 +1,101 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+)
+
+// PacketInTrace is a packet-in and when the stream receiver got it, before
+// any channel buffering.
+type PacketInTrace struct {
+	Received time.Time
+	Packet   *p4.PacketIn
+}
+
+// SetPacketInTraceChan delivers a PacketInTrace on ch for every packet-in,
+// in addition to the PacketIn channel. Traces are dropped, and counted in
+// Stats.PacketInsDropped, if ch is full. Passing nil stops delivery.
+func (c *p4rtClient) SetPacketInTraceChan(ch chan PacketInTrace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.packetInTraceChan = ch
+}
+
+func (c *p4rtClient) tracePacketIn(received time.Time, packet *p4.PacketIn) {
+	c.mu.RLock()
+	ch := c.packetInTraceChan
+	c.mu.RUnlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- PacketInTrace{Received: received, Packet: packet}:
+	default:
+		atomic.AddUint64(&c.packetInsDropped, 1)
+	}
+}
+
+// SendPacketOut sends payload as a packet-out on the stream channel, with
+// metadata keyed by the names of the P4Info's packet_out metadata fields.
+// Metadata values are sent as given, so they must already be encoded to the
+// field's bitwidth. It returns when the message was handed to gRPC, for
+// matching against PacketInTrace.Received.
+func (c *p4rtClient) SendPacketOut(payload []byte, metadata map[string][]byte) (time.Time, error) {
+	packet := &p4.PacketOut{Payload: payload}
+	if len(metadata) > 0 {
+		p4info, err := c.p4infoHelper()
+		if err != nil {
+			return time.Time{}, err
+		}
+		fields, err := p4info.PacketOutMetadata()
+		if err != nil {
+			return time.Time{}, err
+		}
+		packet.Metadata, err = buildPacketMetadata(fields, metadata)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	req := &p4.StreamMessageRequest{
+		Update: &p4.StreamMessageRequest_Packet{Packet: packet},
+	}
+	c.streamSendMu.Lock()
+	sent := time.Now()
+	err := c.stream.Send(req)
+	c.streamSendMu.Unlock()
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "error sending packet-out")
+	}
+	return sent, nil
+}
+
+// buildPacketMetadata maps metadata names to IDs, in P4Info order. Fields
+// not given are left for the switch to default.
+func buildPacketMetadata(fields []MetadataField, metadata map[string][]byte) ([]*p4.PacketMetadata, error) {
+	result := make([]*p4.PacketMetadata, 0, len(metadata))
+	for _, field := range fields {
+		if value, ok := metadata[field.Name]; ok {
+			result = append(result, &p4.PacketMetadata{MetadataId: field.ID, Value: value})
+		}
+	}
+	if len(result) != len(metadata) {
+		known := make(map[string]bool, len(fields))
+		for _, field := range fields {
+			known[field.Name] = true
+		}
+		for name := range metadata {
+			if !known[name] {
+				return nil, fmt.Errorf("Unable to find packet_out metadata field %s", name)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"fmt"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// Pager hands out the entries of a table read in fixed-size pages. Only the
+// current page and the read channel's buffer are held in memory.
+type Pager struct {
+	pageSize  int
+	responses <-chan *p4.ReadResponse
+	errs      <-chan error
+	cancel    func()
+	pending   []*p4.Entity
+	done      bool
+}
+
+// TablePager starts a wildcard read of tableName and returns a Pager over it.
+func (c *p4rtClient) TablePager(tableName string, pageSize int) (*Pager, error) {
+	if pageSize < 1 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+	entity, err := c.tableWildcard(tableName)
+	if err != nil {
+		return nil, err
+	}
+	responses, cancel, errs := c.ReadCancelable(context.Background(), &p4.ReadRequest{
+		DeviceId: c.deviceID,
+		Entities: []*p4.Entity{entity},
+	})
+	return &Pager{
+		pageSize:  pageSize,
+		responses: responses,
+		errs:      errs,
+		cancel:    cancel,
+	}, nil
+}
+
+// Next returns the next page of at most pageSize entries. The bool is false,
+// with no entries, once the table is exhausted. Only the last page may be
+// short.
+func (p *Pager) Next() ([]*p4.TableEntry, bool, error) {
+	page := make([]*p4.TableEntry, 0, p.pageSize)
+	for len(page) < p.pageSize {
+		if len(p.pending) == 0 {
+			if p.done {
+				break
+			}
+			res, ok := <-p.responses
+			if !ok {
+				p.done = true
+				if err := <-p.errs; err != nil {
+					return nil, false, err
+				}
+				break
+			}
+			p.pending = res.GetEntities()
+			continue
+		}
+		if entry := p.pending[0].GetTableEntry(); entry != nil {
+			page = append(page, entry)
+		}
+		p.pending = p.pending[1:]
+	}
+	return page, len(page) > 0, nil
+}
+
+// Close stops the underlying read. It is safe to call after the pager is
+// exhausted.
+func (p *Pager) Close() {
+	p.cancel()
+}
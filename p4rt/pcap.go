@@ -0,0 +1,98 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// packetInChannelDepth is the number of packet-ins buffered for PacketIn.
+const packetInChannelDepth = 1000
+
+const (
+	pcapMagic        = 0xa1b2c3d4 // microsecond timestamps
+	pcapSnapLen      = 65535
+	pcapLinkEthernet = 1
+)
+
+// PacketInCapture writes packet-in payloads to a pcap stream (Ethernet link
+// type), for inspection in Wireshark or tcpdump. Packet-in metadata has no
+// place in a pcap record; it can be logged to a sidecar writer, one line
+// per packet, keyed by the packet's 1-based number in the capture.
+type PacketInCapture struct {
+	w        io.Writer
+	metadata io.Writer
+	packets  int
+}
+
+// NewPacketInCapture writes the pcap file header to w and returns a capture
+// ready for packets. metadata may be nil.
+func NewPacketInCapture(w io.Writer, metadata io.Writer) (*PacketInCapture, error) {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(header[4:], 2) // version 2.4
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	// bytes 8-15: timezone offset and timestamp accuracy, both zero
+	binary.LittleEndian.PutUint32(header[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:], pcapLinkEthernet)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &PacketInCapture{w: w, metadata: metadata}, nil
+}
+
+// WritePacket records packet with timestamp ts.
+func (pc *PacketInCapture) WritePacket(ts time.Time, packet *p4.PacketIn) error {
+	payload := packet.GetPayload()
+	captured := payload
+	if len(captured) > pcapSnapLen {
+		captured = captured[:pcapSnapLen]
+	}
+	record := make([]byte, 16)
+	binary.LittleEndian.PutUint32(record[0:], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(captured)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(payload)))
+	if _, err := pc.w.Write(record); err != nil {
+		return err
+	}
+	if _, err := pc.w.Write(captured); err != nil {
+		return err
+	}
+	pc.packets++
+
+	if pc.metadata != nil && len(packet.GetMetadata()) > 0 {
+		fields := make([]string, len(packet.GetMetadata()))
+		for i, m := range packet.GetMetadata() {
+			fields[i] = fmt.Sprintf("%d=%x", m.GetMetadataId(), m.GetValue())
+		}
+		if _, err := fmt.Fprintf(pc.metadata, "%d %s\n", pc.packets, strings.Join(fields, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Capture writes every packet received on packets, timestamped when it is
+// taken off the channel, until the channel is closed.
+func (pc *PacketInCapture) Capture(packets <-chan *p4.PacketIn) error {
+	for packet := range packets {
+		if err := pc.WritePacket(time.Now(), packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Packets returns the number of packets written so far.
+func (pc *PacketInCapture) Packets() int {
+	return pc.packets
+}
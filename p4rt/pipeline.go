@@ -8,6 +8,8 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/binary"
+	"fmt"
+	"time"
 
 	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
 	p4 "github.com/p4lang/p4runtime/go/p4/v1"
@@ -32,12 +34,12 @@ func BuildPipelineConfig(p4info p4_config.P4Info, deviceConfigPath string) (conf
 	return
 }
 
-func getPipelineConfig(client p4.P4RuntimeClient, deviceId uint64) (*p4.ForwardingPipelineConfig, error) {
+func getPipelineConfig(ctx context.Context, client p4.P4RuntimeClient, deviceId uint64) (*p4.ForwardingPipelineConfig, error) {
 	req := &p4.GetForwardingPipelineConfigRequest{
 		DeviceId:     deviceId,
 		ResponseType: p4.GetForwardingPipelineConfigRequest_P4INFO_AND_COOKIE,
 	}
-	res, err := client.GetForwardingPipelineConfig(context.Background(), req)
+	res, err := client.GetForwardingPipelineConfig(ctx, req)
 
 	//TODO update ErrorDesc to use non-deprecated method
 	//if grpc.ErrorDesc(err) == "No forwarding pipeline config set for this device" {
@@ -50,12 +52,13 @@ func getPipelineConfig(client p4.P4RuntimeClient, deviceId uint64) (*p4.Forwardi
 	return res.GetConfig(), nil
 }
 
-func setPipelineConfig(client p4.P4RuntimeClient, deviceId uint64, electionId *p4.Uint128, config *p4.ForwardingPipelineConfig) error {
+func setPipelineConfig(client p4.P4RuntimeClient, deviceId uint64, electionId *p4.Uint128,
+	action p4.SetForwardingPipelineConfigRequest_Action, config *p4.ForwardingPipelineConfig) error {
 	req := &p4.SetForwardingPipelineConfigRequest{
 		DeviceId:   deviceId,
 		RoleId:     0, // not used
 		ElectionId: electionId,
-		Action:     p4.SetForwardingPipelineConfigRequest_VERIFY_AND_COMMIT,
+		Action:     action,
 		Config:     config,
 	}
 	_, err := client.SetForwardingPipelineConfig(context.Background(), req)
@@ -63,7 +66,23 @@ func setPipelineConfig(client p4.P4RuntimeClient, deviceId uint64, electionId *p
 	return err
 }
 
-func (c *p4rtClient) SetForwardingPipelineConfig(p4InfoPath, deviceConfigPath string) (err error) {
+func (c *p4rtClient) SetForwardingPipelineConfig(p4InfoPath, deviceConfigPath string) error {
+	return c.PushPipelineConfig(p4InfoPath, deviceConfigPath, p4.SetForwardingPipelineConfigRequest_VERIFY_AND_COMMIT)
+}
+
+// PushPipelineConfig loads a P4Info (text or binary) and a target device
+// config and sends them with action, which must be VERIFY, VERIFY_AND_SAVE
+// or VERIFY_AND_COMMIT. Only a commit changes the running pipeline, so only
+// then is the installed cookie checked and the P4Info adopted for the
+// client's name-based helpers.
+func (c *p4rtClient) PushPipelineConfig(p4InfoPath, deviceConfigPath string, action p4.SetForwardingPipelineConfigRequest_Action) (err error) {
+	switch action {
+	case p4.SetForwardingPipelineConfigRequest_VERIFY,
+		p4.SetForwardingPipelineConfigRequest_VERIFY_AND_SAVE,
+		p4.SetForwardingPipelineConfigRequest_VERIFY_AND_COMMIT:
+	default:
+		return fmt.Errorf("unsupported pipeline config action %v", action)
+	}
 	p4info, err := LoadP4Info(p4InfoPath)
 	if err != nil {
 		return
@@ -72,15 +91,87 @@ func (c *p4rtClient) SetForwardingPipelineConfig(p4InfoPath, deviceConfigPath st
 	if err != nil {
 		return
 	}
-	err = setPipelineConfig(c.client, c.deviceID, &c.electionID, &pipeline)
+	err = checkDeviceConfig(p4info, pipeline.P4DeviceConfig)
+	if err != nil {
+		return errors.Wrap(err, "P4Info does not match device config")
+	}
+	err = setPipelineConfig(c.client, c.deviceID, c.ElectionID(), action, &pipeline)
+	if err != nil || action != p4.SetForwardingPipelineConfigRequest_VERIFY_AND_COMMIT {
+		return
+	}
+	err = c.verifyPipelineCookie(pipeline.GetCookie().GetCookie())
 	if err != nil {
 		return
 	}
+	helper := &P4InfoHelper{}
+	helper.InitFromP4Info(p4info)
+	c.SetP4Info(helper)
 	return
 }
 
+// verifyPipelineCookie reads back the installed pipeline and checks that its
+// cookie is the one just pushed.
+func (c *p4rtClient) verifyPipelineCookie(cookie uint64) error {
+	installed, err := getPipelineConfig(context.Background(), c.client, c.deviceID)
+	if err != nil {
+		return errors.Wrap(err, "error verifying pushed pipeline config")
+	}
+	if installed.GetCookie() == nil {
+		fmt.Println("Switch did not report a pipeline cookie; unable to verify pushed pipeline config")
+		return nil
+	}
+	if installed.GetCookie().GetCookie() != cookie {
+		return fmt.Errorf("installed pipeline cookie %#x does not match pushed cookie %#x",
+			installed.GetCookie().GetCookie(), cookie)
+	}
+	return nil
+}
+
+// PipelinePaths names the files of one pipeline.
+type PipelinePaths struct {
+	P4Info       string
+	DeviceConfig string
+}
+
+// PipelineReconfigBenchmark pushes pipelines round-robin with
+// VERIFY_AND_COMMIT, iterations times in total, and summarizes the commit
+// latency. Giving two different pipelines forces the target to reprogram on
+// every push. All files are loaded before timing starts; the P4Info of the
+// last pipeline pushed is kept for the client's name-based helpers.
+func (c *p4rtClient) PipelineReconfigBenchmark(pipelines []PipelinePaths, iterations int) (LatencySummary, error) {
+	if len(pipelines) == 0 || iterations < 1 {
+		return LatencySummary{}, fmt.Errorf("need at least one pipeline and iteration, got %d and %d", len(pipelines), iterations)
+	}
+	configs := make([]p4.ForwardingPipelineConfig, len(pipelines))
+	for i, paths := range pipelines {
+		p4info, err := LoadP4Info(paths.P4Info)
+		if err != nil {
+			return LatencySummary{}, err
+		}
+		configs[i], err = BuildPipelineConfig(p4info, paths.DeviceConfig)
+		if err != nil {
+			return LatencySummary{}, err
+		}
+	}
+
+	latencies := make([]time.Duration, iterations)
+	for i := range latencies {
+		config := &configs[i%len(configs)]
+		start := time.Now()
+		err := setPipelineConfig(c.client, c.deviceID, c.ElectionID(), p4.SetForwardingPipelineConfigRequest_VERIFY_AND_COMMIT, config)
+		latencies[i] = time.Since(start)
+		if err != nil {
+			return LatencySummary{}, errors.Wrapf(err, "push %d of %d failed", i+1, iterations)
+		}
+	}
+	helper := &P4InfoHelper{}
+	helper.InitFromP4Info(*configs[(iterations-1)%len(configs)].P4Info)
+	c.SetP4Info(helper)
+	return SummarizeLatencies(latencies), nil
+}
+
 func (c *p4rtClient) GetForwardingPipelineConfig() (*p4.ForwardingPipelineConfig, error) {
-	return getPipelineConfig(c.client, c.deviceID)
+	return getPipelineConfig(context.Background(), c.client, c.deviceID)
 }
 
 /* FIXME(bocon)
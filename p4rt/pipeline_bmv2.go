@@ -8,9 +8,12 @@
 package p4rt
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+
+	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
 )
 
 func LoadDeviceConfig(deviceConfigPath string) (P4DeviceConfig, error) {
@@ -36,6 +39,34 @@ func LoadDeviceConfig(deviceConfigPath string) (P4DeviceConfig, error) {
 	return bin, nil
 }
 
+// checkDeviceConfig verifies that every P4Info table exists in the BMv2 JSON,
+// which catches a P4Info and JSON compiled from different programs.
+func checkDeviceConfig(p4info p4_config.P4Info, deviceConfig P4DeviceConfig) error {
+	var bmv2JSON struct {
+		Pipelines []struct {
+			Tables []struct {
+				Name string `json:"name"`
+			} `json:"tables"`
+		} `json:"pipelines"`
+	}
+	if err := json.Unmarshal(deviceConfig, &bmv2JSON); err != nil {
+		return fmt.Errorf("parse BMv2 JSON: %v", err)
+	}
+	tables := make(map[string]bool)
+	for _, pipeline := range bmv2JSON.Pipelines {
+		for _, table := range pipeline.Tables {
+			tables[table.Name] = true
+		}
+	}
+	for _, table := range p4info.Tables {
+		if !tables[table.GetPreamble().GetName()] {
+			return fmt.Errorf("P4Info table %s is not in the BMv2 JSON; "+
+				"were the P4Info and device config compiled from the same program?", table.GetPreamble().GetName())
+		}
+	}
+	return nil
+}
+
 func TestTarget() string {
 	return "bmv2"
 }
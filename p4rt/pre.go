@@ -0,0 +1,97 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// ReadMulticastGroup reads back a single multicast group from the
+// packet replication engine.
+func (c *p4rtClient) ReadMulticastGroup(groupID uint32) (*p4.MulticastGroupEntry, error) {
+	if groupID == 0 {
+		return nil, fmt.Errorf("multicast group ID 0 is the wildcard; use ReadAllMulticastGroups")
+	}
+	groups, err := c.readMulticastGroups(groupID)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("multicast group %d not found", groupID)
+	}
+	return groups[0], nil
+}
+
+// ReadAllMulticastGroups reads every multicast group programmed on the device.
+func (c *p4rtClient) ReadAllMulticastGroups() ([]*p4.MulticastGroupEntry, error) {
+	return c.readMulticastGroups(0)
+}
+
+// ReadCloneSession reads back a single clone session from the packet
+// replication engine.
+func (c *p4rtClient) ReadCloneSession(sessionID uint32) (*p4.CloneSessionEntry, error) {
+	if sessionID == 0 {
+		return nil, fmt.Errorf("clone session ID 0 is the wildcard; use ReadAllCloneSessions")
+	}
+	sessions, err := c.readCloneSessions(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, fmt.Errorf("clone session %d not found", sessionID)
+	}
+	return sessions[0], nil
+}
+
+// ReadAllCloneSessions reads every clone session programmed on the device.
+func (c *p4rtClient) ReadAllCloneSessions() ([]*p4.CloneSessionEntry, error) {
+	return c.readCloneSessions(0)
+}
+
+// readMulticastGroups reads groups matching groupID, where 0 matches all.
+func (c *p4rtClient) readMulticastGroups(groupID uint32) ([]*p4.MulticastGroupEntry, error) {
+	entities, err := c.readEntities(preEntity(&p4.PacketReplicationEngineEntry{
+		Type: &p4.PacketReplicationEngineEntry_MulticastGroupEntry{
+			MulticastGroupEntry: &p4.MulticastGroupEntry{MulticastGroupId: groupID},
+		},
+	}))
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*p4.MulticastGroupEntry, 0, len(entities))
+	for _, entity := range entities {
+		if group := entity.GetPacketReplicationEngineEntry().GetMulticastGroupEntry(); group != nil {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// readCloneSessions reads sessions matching sessionID, where 0 matches all.
+func (c *p4rtClient) readCloneSessions(sessionID uint32) ([]*p4.CloneSessionEntry, error) {
+	entities, err := c.readEntities(preEntity(&p4.PacketReplicationEngineEntry{
+		Type: &p4.PacketReplicationEngineEntry_CloneSessionEntry{
+			CloneSessionEntry: &p4.CloneSessionEntry{SessionId: sessionID},
+		},
+	}))
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]*p4.CloneSessionEntry, 0, len(entities))
+	for _, entity := range entities {
+		if session := entity.GetPacketReplicationEngineEntry().GetCloneSessionEntry(); session != nil {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func preEntity(entry *p4.PacketReplicationEngineEntry) *p4.Entity {
+	return &p4.Entity{Entity: &p4.Entity_PacketReplicationEngineEntry{
+		PacketReplicationEngineEntry: entry,
+	}}
+}
@@ -0,0 +1,131 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// RampRunner finds the write rate at which the switch starts failing. It
+// inserts entries open loop through the client's SetWriteRate pacer,
+// starting at StartRate and raising the rate by Step every StepInterval,
+// until a write returns a non-OK update or MaxRate has been held for a
+// whole step.
+type RampRunner struct {
+	Client P4RuntimeClient
+	// Entry returns the n-th entry. Distinct n must give distinct match
+	// keys. Entries are left installed, so a table that fills up also ends
+	// the ramp; RampResult.Code tells the two apart.
+	Entry        func(n int) *p4.TableEntry
+	BatchSize    int
+	StartRate    float64 // updates per second
+	Step         float64 // updates per second added every StepInterval
+	StepInterval time.Duration
+	MaxRate      float64 // updates per second
+	// MaxInFlight caps the writes submitted and not yet answered, so a
+	// switch that falls behind does not grow the client's queue without
+	// bound.
+	MaxInFlight int
+}
+
+// RampResult describes where a ramp ended.
+type RampResult struct {
+	// Failed is set if a write failed before the ramp ran out. Rate is then
+	// the paced rate when the first failure was answered, Elapsed the time
+	// from the start until then and Code the failed write's DominantCode.
+	// Otherwise Rate is MaxRate and Elapsed the length of the ramp.
+	Failed  bool
+	Rate    float64
+	Elapsed time.Duration
+	Code    codes.Code
+	Updates int // updates submitted
+}
+
+func (r RampResult) String() string {
+	if !r.Failed {
+		return fmt.Sprintf("no write failed up to %.0f updates/sec (%d updates in %v)", r.Rate, r.Updates, r.Elapsed)
+	}
+	return fmt.Sprintf("first failure (%v) at %.0f updates/sec after %v (%d updates)", r.Code, r.Rate, r.Elapsed, r.Updates)
+}
+
+// Run ramps the rate until the first failure or MaxRate. Pacing is turned
+// off when it returns, and the writes still in flight have been answered.
+func (r *RampRunner) Run() (RampResult, error) {
+	if r.BatchSize < 1 || r.MaxInFlight < 1 || r.StepInterval <= 0 {
+		return RampResult{}, fmt.Errorf("invalid batch size %d, writes in flight %d or step interval %v",
+			r.BatchSize, r.MaxInFlight, r.StepInterval)
+	}
+	if r.StartRate <= 0 || r.Step <= 0 || r.MaxRate < r.StartRate {
+		return RampResult{}, fmt.Errorf("invalid ramp from %v by %v up to %v updates/sec", r.StartRate, r.Step, r.MaxRate)
+	}
+	defer r.Client.SetWriteRate(0, 1)
+
+	var (
+		mu     sync.Mutex
+		rate   = r.StartRate
+		result RampResult
+	)
+	failed := make(chan struct{})
+	inFlight := make(chan struct{}, r.MaxInFlight)
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	r.Client.SetWriteRate(rate, r.BatchSize)
+	step := time.NewTicker(r.StepInterval)
+	defer step.Stop()
+	for n, done := 0, false; !done; {
+		select {
+		case <-failed:
+			done = true
+		case <-step.C:
+			mu.Lock()
+			if rate >= r.MaxRate {
+				// MaxRate has held for a whole step
+				done = true
+			} else if rate += r.Step; rate > r.MaxRate {
+				rate = r.MaxRate
+			}
+			r.Client.SetWriteRate(rate, r.BatchSize)
+			mu.Unlock()
+		case inFlight <- struct{}{}:
+			entries := make([]*p4.TableEntry, r.BatchSize)
+			for i := range entries {
+				entries[i] = r.Entry(n + i)
+			}
+			n += len(entries)
+			res := r.Client.Write(tableEntryRequest(r.Client, p4.Update_INSERT, entries))
+			result.Updates += len(entries)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errors := <-res
+				<-inFlight
+				if countFailed(errors) == 0 {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if !result.Failed {
+					result.Failed = true
+					result.Rate = rate
+					result.Elapsed = time.Since(start)
+					result.Code = DominantCode(errors)
+					close(failed)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	if !result.Failed {
+		result.Rate = r.MaxRate
+		result.Elapsed = time.Since(start)
+	}
+	return result, nil
+}
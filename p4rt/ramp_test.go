@@ -0,0 +1,71 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"testing"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newRampRunner(c *p4rtClient, maxRate float64) *RampRunner {
+	return &RampRunner{
+		Client: c,
+		Entry: func(n int) *p4.TableEntry {
+			return &p4.TableEntry{TableId: 1, Match: []*p4.FieldMatch{{
+				FieldId:        1,
+				FieldMatchType: &p4.FieldMatch_Exact_{Exact: &p4.FieldMatch_Exact{Value: []byte{byte(n >> 8), byte(n)}}},
+			}}}
+		},
+		BatchSize:    10,
+		StartRate:    1000,
+		Step:         1000,
+		StepInterval: 20 * time.Millisecond,
+		MaxRate:      maxRate,
+		MaxInFlight:  4,
+	}
+}
+
+func TestRampRunnerFindsFirstFailure(t *testing.T) {
+	c, fake := newTestClient(t, 10, 2)
+	fake.setFail(func(n int, req *p4.WriteRequest) error {
+		if n >= 30 {
+			return status.Error(codes.ResourceExhausted, "overloaded")
+		}
+		return nil
+	})
+	result, err := newRampRunner(c, 1e6).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Failed || result.Code != codes.ResourceExhausted {
+		t.Fatalf("ramp ended with %v, want a RESOURCE_EXHAUSTED failure", result)
+	}
+	// 290 updates take more than one step at 1000 updates/sec
+	if result.Rate < 2000 {
+		t.Errorf("first failure at %v updates/sec, want the rate raised at least once", result.Rate)
+	}
+	if rate, _ := c.pacer.settings(); rate != 0 {
+		t.Errorf("write rate left at %v, want pacing off", rate)
+	}
+}
+
+func TestRampRunnerReachesMaxRate(t *testing.T) {
+	c, _ := newTestClient(t, 10, 2)
+	result, err := newRampRunner(c, 3000).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed || result.Rate != 3000 {
+		t.Fatalf("ramp ended with %v, want no failure up to 3000 updates/sec", result)
+	}
+	// Two steps up to MaxRate, then one step holding it
+	if result.Elapsed < 60*time.Millisecond {
+		t.Errorf("ramp took %v, want at least 60ms", result.Elapsed)
+	}
+}
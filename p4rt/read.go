@@ -0,0 +1,183 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"io"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+)
+
+const defaultReadChannelDepth = 100
+
+// SetReadChannelDepth bounds how many ReadResponses Read buffers ahead of
+// the consumer. Once the buffer is full the stream reader stops receiving,
+// which back-pressures the switch instead of growing memory.
+func (c *p4rtClient) SetReadChannelDepth(n int) {
+	if n < 0 {
+		n = 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readChannelDepth = n
+}
+
+// SetReadTimeout bounds how long each read may take, from opening the
+// stream until the last response. It applies to Read, ReadCancelable,
+// ReadWithCallback and every helper built on them (snapshots, pagers,
+// counts). A deadline on a caller-supplied context still applies; whichever
+// of the two is sooner wins. Zero (the default) means no timeout.
+func (c *p4rtClient) SetReadTimeout(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readTimeout = d
+}
+
+// Read streams the responses for req on the returned channel, which is
+// closed when the stream ends. The error channel then yields the error
+// that ended the stream, if any, and is closed.
+func (c *p4rtClient) Read(req *p4.ReadRequest) (<-chan *p4.ReadResponse, <-chan error) {
+	responses, _, errs := c.ReadCancelable(context.Background(), req)
+	return responses, errs
+}
+
+// ReadCancelable is like Read, but the stream is bound to ctx and can also be
+// stopped early by calling the returned cancel function. Cancelling closes
+// the gRPC stream and the reader goroutine exits even if nobody is draining
+// the response channel.
+func (c *p4rtClient) ReadCancelable(ctx context.Context, req *p4.ReadRequest) (<-chan *p4.ReadResponse, func(), <-chan error) {
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.RLock()
+	depth := c.readChannelDepth
+	c.mu.RUnlock()
+	responses := make(chan *p4.ReadResponse, depth)
+	errs := make(chan error, 1)
+	go func() {
+		defer cancel()
+		err := c.readStream(ctx, req, func(res *p4.ReadResponse) error {
+			select {
+			case responses <- res:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(responses)
+		if err != nil {
+			errs <- err
+		}
+		close(errs)
+	}()
+	return responses, cancel, errs
+}
+
+// ReadWithCallback calls fn synchronously for each ReadResponse in the
+// stream. If fn returns an error the stream is cancelled and that error is
+// returned.
+func (c *p4rtClient) ReadWithCallback(req *p4.ReadRequest, fn func(*p4.ReadResponse) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	return c.readStream(ctx, req, fn)
+}
+
+func (c *p4rtClient) readStream(ctx context.Context, req *p4.ReadRequest, fn func(*p4.ReadResponse) error) (err error) {
+	c.mu.RLock()
+	timeout := c.readTimeout
+	c.mu.RUnlock()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx, span := c.startSpan(ctx, "p4.v1.P4Runtime/Read")
+	defer func() { endSpan(span, err) }()
+	trace := c.startReadTrace(req)
+	defer func() { c.deliverReadTrace(trace, err) }()
+
+	stream, err := c.client.Read(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "error starting read")
+	}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "error reading entities")
+		}
+		trace.addResponse(res)
+		if err := fn(res); err != nil {
+			return err
+		}
+	}
+}
+
+// readEntities reads the given entities and collects every entity returned.
+func (c *p4rtClient) readEntities(entities ...*p4.Entity) ([]*p4.Entity, error) {
+	req := &p4.ReadRequest{
+		DeviceId: c.deviceID,
+		Entities: entities,
+	}
+	var result []*p4.Entity
+	err := c.ReadWithCallback(req, func(res *p4.ReadResponse) error {
+		result = append(result, res.GetEntities()...)
+		return nil
+	})
+	return result, err
+}
+
+// EntityIterator walks the entities of a read one at a time, hiding how
+// the switch grouped them into ReadResponses.
+type EntityIterator struct {
+	responses <-chan *p4.ReadResponse
+	errs      <-chan error
+	cancel    func()
+	pending   []*p4.Entity
+	err       error
+}
+
+// ReadIterator starts req bound to ctx and returns an iterator over the
+// entities it returns.
+func (c *p4rtClient) ReadIterator(ctx context.Context, req *p4.ReadRequest) *EntityIterator {
+	responses, cancel, errs := c.ReadCancelable(ctx, req)
+	return &EntityIterator{
+		responses: responses,
+		errs:      errs,
+		cancel:    cancel,
+	}
+}
+
+// Next returns the next entity. Once the stream is exhausted it returns
+// io.EOF; if the stream failed it returns that error instead. Either way,
+// every later call returns the same error.
+func (it *EntityIterator) Next() (*p4.Entity, error) {
+	for len(it.pending) == 0 {
+		if it.err != nil {
+			return nil, it.err
+		}
+		res, ok := <-it.responses
+		if !ok {
+			it.err = io.EOF
+			if err := <-it.errs; err != nil {
+				it.err = err
+			}
+			return nil, it.err
+		}
+		it.pending = res.GetEntities()
+	}
+	entity := it.pending[0]
+	it.pending = it.pending[1:]
+	return entity, nil
+}
+
+// Close stops the underlying read. It is safe to call after the iterator is
+// exhausted.
+func (it *EntityIterator) Close() {
+	it.cancel()
+}
@@ -0,0 +1,191 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ReconnectPolicy controls what the client does when its stream channel
+// breaks, e.g. because the switch restarted. The zero policy, the default,
+// leaves the stream closed.
+type ReconnectPolicy struct {
+	// MinBackoff is the wait before the first attempt to reopen the stream;
+	// it doubles after each failed attempt, up to MaxBackoff. Reconnection
+	// is enabled when it is positive.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts bounds the consecutive failed attempts before the client
+	// gives up and the stream closes for good. Zero means no bound.
+	MaxAttempts int
+	// ReplayWrites resends writes that failed with codes.Unavailable once
+	// the stream is back and, if the client had arbitrated, it is primary
+	// again. Replays go on, backing off as above, until the write succeeds,
+	// fails otherwise, has been replayed MaxReplays times, the client
+	// context ends or the stream closes for good. Without it such writes
+	// fail at once.
+	ReplayWrites bool
+	// MaxReplays bounds how often one write is replayed before it fails
+	// with codes.Unavailable. Zero means 10.
+	MaxReplays int
+}
+
+const defaultMaxReplays = 10
+
+// errStreamClosed is returned by the wait helpers once the stream channel
+// has closed for good.
+var errStreamClosed = errors.New("stream channel closed")
+
+// SetReconnectPolicy sets how the client recovers from a broken stream
+// channel. A reopened stream repeats the last MasterArbitrationUpdate sent
+// by SetMastership or Arbitrate; until the switch answers it, the client is
+// not primary. Reconnects are counted in Stats.Reconnects.
+func (c *p4rtClient) SetReconnectPolicy(policy ReconnectPolicy) {
+	if policy.MaxBackoff < policy.MinBackoff {
+		policy.MaxBackoff = policy.MinBackoff
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reconnect = policy
+}
+
+func (c *p4rtClient) reconnectPolicy() ReconnectPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.reconnect
+}
+
+func (policy ReconnectPolicy) schedule() backoff {
+	return newBackoff(policy.MinBackoff, policy.MaxBackoff)
+}
+
+// reconnectStream is called by the stream receiver when Recv fails with
+// err. It returns a new, re-arbitrated stream, or nil if the stream should
+// stay closed.
+func (c *p4rtClient) reconnectStream(err error) p4.P4Runtime_StreamChannelClient {
+	policy := c.reconnectPolicy()
+	if policy.MinBackoff <= 0 || c.isShutDown() || status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	c.mu.Lock()
+	c.streamUp = make(chan struct{})
+	c.mu.Unlock()
+	// Without the stream the switch will not tell us we lost mastership
+	c.updateMastership(&p4.MasterArbitrationUpdate{
+		DeviceId: c.deviceID,
+		Status: &spb.Status{
+			Code:    int32(code.Code_UNAVAILABLE),
+			Message: fmt.Sprintf("stream channel broken: %v", err),
+		},
+	})
+
+	for attempt := 0; policy.MaxAttempts == 0 || attempt < policy.MaxAttempts; attempt++ {
+		if policy.schedule().wait(c.streamCtx, c.reconnectFailures) != nil {
+			return nil
+		}
+		c.reconnectFailures++
+		// Each attempt gets its own context, so a stream that fails to
+		// re-arbitrate is released without closing the client's
+		ctx, cancel := context.WithCancel(c.streamCtx)
+		stream, err := c.client.StreamChannel(ctx)
+		if err == nil {
+			err = c.rearbitrate(stream)
+		}
+		if err != nil {
+			cancel()
+			fmt.Printf("stream reconnect attempt %d failed: %v\n", attempt+1, err)
+			continue
+		}
+		c.installStream(stream, cancel)
+		atomic.AddUint64(&c.reconnects, 1)
+		fmt.Println("stream channel reconnected")
+		c.mu.Lock()
+		close(c.streamUp)
+		c.mu.Unlock()
+		return stream
+	}
+	fmt.Printf("giving up on the stream channel after %d attempts\n", policy.MaxAttempts)
+	return nil
+}
+
+// rearbitrate repeats the last MasterArbitrationUpdate, if there was one, on
+// stream, which is not installed yet.
+func (c *p4rtClient) rearbitrate(stream p4.P4Runtime_StreamChannelClient) error {
+	c.mu.RLock()
+	arbitrated := c.arbitrated
+	c.mu.RUnlock()
+	if !arbitrated {
+		return nil
+	}
+	// No one else sends on stream until it is installed
+	return errors.Wrap(stream.Send(c.arbitrationRequest(*c.ElectionID())), "error resending MasterArbitrationUpdate")
+}
+
+// installStream makes stream, which cancel ends, the client's stream, and
+// cancels the broken stream it replaces.
+func (c *p4rtClient) installStream(stream p4.P4Runtime_StreamChannelClient, cancel context.CancelFunc) {
+	c.streamSendMu.Lock()
+	defer c.streamSendMu.Unlock()
+	if c.reconnectCancel != nil {
+		c.reconnectCancel()
+	}
+	c.stream, c.reconnectCancel = stream, cancel
+}
+
+// maxReplays returns how often a write failing with codes.Unavailable may be
+// replayed; 0 if replays are off.
+func (c *p4rtClient) maxReplays() int {
+	policy := c.reconnectPolicy()
+	if policy.MinBackoff <= 0 || !policy.ReplayWrites {
+		return 0
+	}
+	if policy.MaxReplays <= 0 {
+		return defaultMaxReplays
+	}
+	return policy.MaxReplays
+}
+
+// waitReconnected waits out the backoff for replay attempt, then until the
+// stream is up and, if the client had arbitrated, the client is primary.
+func (c *p4rtClient) waitReconnected(ctx context.Context, attempt int) error {
+	if err := c.reconnectPolicy().schedule().wait(ctx, attempt); err != nil {
+		return err
+	}
+	c.mu.RLock()
+	streamUp := c.streamUp
+	arbitrated := c.arbitrated
+	c.mu.RUnlock()
+	select {
+	case <-streamUp:
+	case <-c.streamDone:
+		return errStreamClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if !arbitrated {
+		return nil
+	}
+	c.mu.RLock()
+	becamePrimary := c.becamePrimary
+	c.mu.RUnlock()
+	select {
+	case <-becamePrimary:
+		return nil
+	case <-c.streamDone:
+		return errStreamClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
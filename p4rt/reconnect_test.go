@@ -0,0 +1,103 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReplayWritesIsCapped(t *testing.T) {
+	c, fake := newTestClient(t, 1, 1)
+	c.SetReconnectPolicy(ReconnectPolicy{MinBackoff: time.Millisecond, ReplayWrites: true, MaxReplays: 3})
+	fake.setFail(func(int, *p4.WriteRequest) error {
+		return status.Error(codes.Unavailable, "restarting")
+	})
+	traces := make(chan WriteTrace, 1)
+	c.SetWriteTraceChan(traces)
+
+	if code := codes.Code((<-c.Write(insertRequest(c, 1)))[0].GetCanonicalCode()); code != codes.Unavailable {
+		t.Errorf("got %v once out of replays, want %v", code, codes.Unavailable)
+	}
+	if writes, _ := fake.counts(); writes != 4 {
+		t.Errorf("switch got %d writes, want the first and 3 replays", writes)
+	}
+	if replayed := c.Stats().WritesReplayed; replayed != 3 {
+		t.Errorf("Stats().WritesReplayed = %d, want 3", replayed)
+	}
+	if trace := <-traces; trace.PhysicalWrites != 4 {
+		t.Errorf("trace counts %d physical writes, want the first and 3 replays", trace.PhysicalWrites)
+	}
+}
+
+// breakingSwitch is a fakeSwitch that breaks the first stream channel once
+// it has received an arbitration update.
+type breakingSwitch struct {
+	fakeSwitch
+	streamsMu sync.Mutex
+	streams   int
+}
+
+func (s *breakingSwitch) StreamChannel(stream p4.P4Runtime_StreamChannelServer) error {
+	s.streamsMu.Lock()
+	s.streams++
+	first := s.streams == 1
+	s.streamsMu.Unlock()
+	if !first {
+		return s.fakeSwitch.StreamChannel(stream)
+	}
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+	return status.Error(codes.Unavailable, "restarting")
+}
+
+// TestReconnectRearbitrates checks that a broken stream is replaced by one
+// the client has re-arbitrated on.
+func TestReconnectRearbitrates(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	fake := &breakingSwitch{}
+	p4.RegisterP4RuntimeServer(server, fake)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	c, err := newP4RuntimeClient(lis.Addr().String(), 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetReconnectPolicy(ReconnectPolicy{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	changes := make(chan MastershipChange, 4)
+	c.SetMastershipChan(changes)
+	if err := c.SetMastership(p4.Uint128{Low: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for !c.IsPrimary() {
+		select {
+		case <-changes:
+		case <-timeout:
+			t.Fatal("client is not primary on the reconnected stream")
+		}
+	}
+	if reconnects := c.Stats().Reconnects; reconnects != 1 {
+		t.Errorf("Stats().Reconnects = %d, want 1", reconnects)
+	}
+	if errs := <-c.Write(insertRequest(c, 1)); countFailed(errs) != 0 {
+		t.Errorf("write after reconnecting failed: %v", errs)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// ReplayAtRate submits requests in order, paced so that updates go out at
+// updatesPerSec on average, without waiting for earlier requests to be
+// answered (open loop). The pacing is SetWriteRate's: the client's write
+// rate is set to updatesPerSec, with no burst, for the run and put back
+// afterwards, so other writes made meanwhile share the rate. Each request is
+// counted as sent at the moment its first update is due. The result reports
+// the updates, failures, total time, and per-request latency from that
+// moment to the response.
+func (c *p4rtClient) ReplayAtRate(requests []*p4.WriteRequest, updatesPerSec int) (BenchmarkResult, error) {
+	if updatesPerSec < 1 {
+		return BenchmarkResult{}, fmt.Errorf("invalid rate %d updates/sec", updatesPerSec)
+	}
+	rate, burst := c.pacer.settings()
+	c.SetWriteRate(float64(updatesPerSec), 1)
+	defer c.SetWriteRate(rate, burst)
+	interval := time.Second / time.Duration(updatesPerSec)
+
+	sent := 0 // updates submitted so far
+	return c.replayOpenLoop(len(requests), func(i int, start time.Time) (*p4.WriteRequest, time.Time) {
+		// The write threads hold the request until the pacer's slot for it
+		due := start.Add(time.Duration(sent) * interval)
+		sent += len(requests[i].GetUpdates())
+		return requests[i], due
+	}), nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"testing"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+func TestReplayAtRate(t *testing.T) {
+	c, fake := newTestClient(t, 10, 2)
+	c.SetWriteRate(50, 5)
+	requests := make([]*p4.WriteRequest, 10)
+	for i := range requests {
+		requests[i] = insertRequest(c, 10)
+	}
+	result, err := c.ReplayAtRate(requests, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Updates != 100 || result.Failed != 0 {
+		t.Errorf("replayed %d updates with %d failed, want 100 and 0", result.Updates, result.Failed)
+	}
+	// The last request is due after the first 90 updates; allow for the
+	// burst of one the pacer grants up front
+	if result.Elapsed < 80*time.Millisecond {
+		t.Errorf("replay took %v, want about 90ms at 1000 updates/sec", result.Elapsed)
+	}
+	if writes, _ := fake.counts(); writes != 10 {
+		t.Errorf("switch got %d writes, want 10", writes)
+	}
+	if rate, burst := c.pacer.settings(); rate != 50 || burst != 5 {
+		t.Errorf("write rate left at %v with burst %d, want 50 with burst 5", rate, burst)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// DefaultRetryCodes are the canonical codes targets use for transient
+// per-update failures.
+var DefaultRetryCodes = []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.Aborted}
+
+// The policy SetRetryableCodes sets
+const (
+	maxWriteAttempts         = 3
+	retryableCodesMinBackoff = 10 * time.Millisecond
+	retryableCodesMaxBackoff = time.Second
+)
+
+// RetryPolicy resends the updates of a write that failed with a transient
+// code, leaving out those that succeeded or failed for good.
+type RetryPolicy struct {
+	// Codes are the per-update canonical codes to retry; nil means
+	// DefaultRetryCodes.
+	Codes []codes.Code
+	// MaxAttempts bounds how often an update is sent, counting the first
+	// time. The policy is off unless it is above 1.
+	MaxAttempts int
+	// MinBackoff is the wait before the first retry; it doubles for each
+	// further retry, up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// retryState is a RetryPolicy ready for use by the write threads.
+type retryState struct {
+	codes       map[codes.Code]bool
+	maxAttempts int
+	backoff     backoff
+}
+
+// SetRetryPolicy retries failed updates according to policy. After the
+// Write RPC has been sent, and replayed as the ReconnectPolicy allows, the
+// updates whose p4.Error carries one of policy's codes are written again
+// on their own, as a new request of just those updates. A Write RPC that
+// fails as a whole fails each of its updates with the RPC's code, so when
+// that code is retryable the whole request is resent. The p4.Error of an
+// update that was retried says so in its Message, and WriteTrace.Retries
+// and RetriedUpdates count the retries of each write; Stats.UpdateRetries
+// counts them all. A zero policy (the default) turns retries off.
+func (c *p4rtClient) SetRetryPolicy(policy RetryPolicy) {
+	var state *retryState
+	if policy.MaxAttempts > 1 {
+		retryCodes := policy.Codes
+		if retryCodes == nil {
+			retryCodes = DefaultRetryCodes
+		}
+		state = &retryState{
+			codes:       make(map[codes.Code]bool, len(retryCodes)),
+			maxAttempts: policy.MaxAttempts,
+			backoff:     newBackoff(policy.MinBackoff, policy.MaxBackoff),
+		}
+		for _, code := range retryCodes {
+			state.codes[code] = true
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retryPolicy = state
+}
+
+// SetRetryableCodes retries the updates of writes that fail with one of
+// retryCodes, sending each up to maxWriteAttempts times in all with a
+// backoff from 10ms to 1s. It is shorthand for SetRetryPolicy and replaces
+// the policy set before. By default no code is retryable; calling it with
+// no codes turns retries off again.
+func (c *p4rtClient) SetRetryableCodes(retryCodes ...codes.Code) {
+	if len(retryCodes) == 0 {
+		c.SetRetryPolicy(RetryPolicy{})
+		return
+	}
+	c.SetRetryPolicy(RetryPolicy{
+		Codes:       retryCodes,
+		MaxAttempts: maxWriteAttempts,
+		MinBackoff:  retryableCodesMinBackoff,
+		MaxBackoff:  retryableCodesMaxBackoff,
+	})
+}
+
+func (c *p4rtClient) retryPolicySnapshot() *retryState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.retryPolicy
+}
+
+// retryUpdates resends the updates of req whose entries of errors have a
+// retryable code, using send, until none is left or they run out of
+// attempts. It returns the merged errors, the number of retry rounds and
+// the number of updates retried at least once.
+func (c *p4rtClient) retryUpdates(ctx context.Context, policy *retryState, req *p4.WriteRequest,
+	errors []*p4.Error, send func(*p4.WriteRequest) error) ([]*p4.Error, int, int) {
+	retriesOf := make([]int, len(errors))
+	rounds := 0
+	for ; rounds+1 < policy.maxAttempts; rounds++ {
+		var pending []int
+		for i, p4Err := range errors {
+			if policy.codes[codes.Code(p4Err.GetCanonicalCode())] {
+				pending = append(pending, i)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+		if policy.backoff.wait(ctx, rounds) != nil {
+			return annotateRetries(errors, retriesOf), rounds, countRetried(retriesOf)
+		}
+		retry := proto.Clone(req).(*p4.WriteRequest)
+		retry.Updates = make([]*p4.Update, len(pending))
+		for j, i := range pending {
+			retry.Updates[j] = req.Updates[i]
+			retriesOf[i]++
+		}
+		atomic.AddUint64(&c.updateRetries, uint64(len(pending)))
+		results := parseP4RuntimeWriteError(send(retry), len(pending))
+		for j, i := range pending {
+			errors[i] = results[j]
+		}
+	}
+	return annotateRetries(errors, retriesOf), rounds, countRetried(retriesOf)
+}
+
+// annotateRetries notes in the message of each retried update how often it
+// was retried. Errors may be shared between updates, so they are copied.
+func annotateRetries(errors []*p4.Error, retriesOf []int) []*p4.Error {
+	for i, retries := range retriesOf {
+		if retries == 0 {
+			continue
+		}
+		times := fmt.Sprintf("%d retries", retries)
+		if retries == 1 {
+			times = "1 retry"
+		}
+		p4Err := proto.Clone(errors[i]).(*p4.Error)
+		if p4Err.GetCanonicalCode() == int32(codes.OK) {
+			p4Err.Message = "succeeded after " + times
+		} else {
+			p4Err.Message = fmt.Sprintf("%s (after %s)", p4Err.GetMessage(), times)
+		}
+		errors[i] = p4Err
+	}
+	return errors
+}
+
+func countRetried(retriesOf []int) int {
+	retried := 0
+	for _, retries := range retriesOf {
+		if retries > 0 {
+			retried++
+		}
+	}
+	return retried
+}
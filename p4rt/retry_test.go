@@ -0,0 +1,71 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failFirst fails the first n writes as a whole with code.
+func failFirst(n int, code codes.Code) func(int, *p4.WriteRequest) error {
+	return func(write int, req *p4.WriteRequest) error {
+		if write <= n {
+			return status.Error(code, "transient")
+		}
+		return nil
+	}
+}
+
+func TestSetRetryableCodes(t *testing.T) {
+	c, fake := newTestClient(t, 1, 1)
+	c.SetRetryableCodes(codes.Unavailable)
+
+	fake.setFail(failFirst(2, codes.Unavailable))
+	start := time.Now()
+	p4Err := (<-c.Write(insertRequest(c, 1)))[0]
+	elapsed := time.Since(start)
+	if code := codes.Code(p4Err.GetCanonicalCode()); code != codes.OK || !strings.Contains(p4Err.GetMessage(), "2 retries") {
+		t.Errorf("got %v %q, want OK after 2 retries", code, p4Err.GetMessage())
+	}
+	if writes, _ := fake.counts(); writes != 3 {
+		t.Errorf("switch got %d writes, want 3", writes)
+	}
+	// Backing off 10ms, then 20ms
+	if min := retryableCodesMinBackoff * 3; elapsed < min {
+		t.Errorf("retries took %v, want at least %v of backoff", elapsed, min)
+	}
+
+	// Retries stop after maxWriteAttempts
+	fake.setFail(failFirst(maxWriteAttempts, codes.Unavailable))
+	if code := codes.Code((<-c.Write(insertRequest(c, 1)))[0].GetCanonicalCode()); code != codes.Unavailable {
+		t.Errorf("got %v once out of attempts, want %v", code, codes.Unavailable)
+	}
+	if writes, _ := fake.counts(); writes != maxWriteAttempts {
+		t.Errorf("switch got %d writes, want %d", writes, maxWriteAttempts)
+	}
+
+	// Other codes are not retried
+	fake.setFail(failFirst(1, codes.ResourceExhausted))
+	if code := codes.Code((<-c.Write(insertRequest(c, 1)))[0].GetCanonicalCode()); code != codes.ResourceExhausted {
+		t.Errorf("got %v, want %v", code, codes.ResourceExhausted)
+	}
+	if writes, _ := fake.counts(); writes != 1 {
+		t.Errorf("switch got %d writes of a non-retryable failure, want 1", writes)
+	}
+
+	// No codes turns retries off
+	c.SetRetryableCodes()
+	fake.setFail(failFirst(1, codes.Unavailable))
+	<-c.Write(insertRequest(c, 1))
+	if writes, _ := fake.counts(); writes != 1 {
+		t.Errorf("switch got %d writes with retries off, want 1", writes)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// RunMetadata describes the context of a benchmark run (switch model,
+// firmware, pipeline, test revision, a free-form label, ...) so that
+// archived results are self-describing. It implements flag.Value, taking
+// repeated key=value arguments.
+type RunMetadata map[string]string
+
+func (m RunMetadata) String() string {
+	keys := m.keys()
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + m[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set adds a key=value pair.
+func (m RunMetadata) Set(pair string) error {
+	kv := strings.SplitN(pair, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("metadata %q is not key=value", pair)
+	}
+	m[kv[0]] = kv[1]
+	return nil
+}
+
+// WriteComments writes the metadata to w as "# key: value" lines, sorted
+// by key, for the top of a CSV result file.
+func (m RunMetadata) WriteComments(w io.Writer) error {
+	for _, k := range m.keys() {
+		if _, err := fmt.Fprintf(w, "# %s: %s\n", k, m[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m RunMetadata) keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,105 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// ScalingRunner measures how write latency changes with table occupancy.
+// It fills the table up to each checkpoint in turn and, at each one, times
+// a fixed probe: ProbeBatches inserts of ProbeSize entries, each deleted
+// again right away so the occupancy stays at the checkpoint.
+type ScalingRunner struct {
+	Client P4RuntimeClient
+	// Entry returns the n-th entry. Distinct n must give distinct match keys.
+	// Fill entries use n below the last checkpoint; probe entries use the
+	// ProbeSize indexes right after it.
+	Entry        func(n int) *p4.TableEntry
+	BatchSize    int   // entries per fill batch
+	Checkpoints  []int // occupancies to measure at, in increasing order
+	ProbeSize    int
+	ProbeBatches int
+}
+
+// ScalingPoint is the probe latency measured at one occupancy.
+type ScalingPoint struct {
+	Occupancy int
+	Latency   LatencySummary
+}
+
+// ScalingReport holds one ScalingPoint per checkpoint, in checkpoint order.
+type ScalingReport []ScalingPoint
+
+// Run fills the table and probes at every checkpoint. It fails on the first
+// fill or probe update that does not succeed, since the occupancy would no
+// longer be known. The fill entries are left installed.
+func (r *ScalingRunner) Run() (ScalingReport, error) {
+	if r.BatchSize < 1 || r.ProbeSize < 1 || r.ProbeBatches < 1 {
+		return nil, fmt.Errorf("invalid batch size %d, probe size %d or probe batches %d",
+			r.BatchSize, r.ProbeSize, r.ProbeBatches)
+	}
+	for i := 1; i < len(r.Checkpoints); i++ {
+		if r.Checkpoints[i] <= r.Checkpoints[i-1] {
+			return nil, fmt.Errorf("checkpoints must be increasing, got %d after %d",
+				r.Checkpoints[i], r.Checkpoints[i-1])
+		}
+	}
+	if len(r.Checkpoints) == 0 {
+		return nil, nil
+	}
+	probeBase := r.Checkpoints[len(r.Checkpoints)-1]
+	probe := make([]*p4.TableEntry, r.ProbeSize)
+	for i := range probe {
+		probe[i] = r.Entry(probeBase + i)
+	}
+
+	report := make(ScalingReport, 0, len(r.Checkpoints))
+	filled := 0
+	for _, checkpoint := range r.Checkpoints {
+		for filled < checkpoint {
+			n := checkpoint - filled
+			if n > r.BatchSize {
+				n = r.BatchSize
+			}
+			entries := make([]*p4.TableEntry, n)
+			for i := range entries {
+				entries[i] = r.Entry(filled + i)
+			}
+			if _, err := r.write(p4.Update_INSERT, entries); err != nil {
+				return report, fmt.Errorf("filling to %d entries: %v", checkpoint, err)
+			}
+			filled += n
+		}
+
+		latencies := make([]time.Duration, r.ProbeBatches)
+		for i := range latencies {
+			latency, err := r.write(p4.Update_INSERT, probe)
+			if err != nil {
+				return report, fmt.Errorf("probing at %d entries: %v", checkpoint, err)
+			}
+			latencies[i] = latency
+			if _, err := r.write(p4.Update_DELETE, probe); err != nil {
+				return report, fmt.Errorf("removing probe at %d entries: %v", checkpoint, err)
+			}
+		}
+		report = append(report, ScalingPoint{Occupancy: checkpoint, Latency: SummarizeLatencies(latencies)})
+	}
+	return report, nil
+}
+
+// write sends one batch and waits for it, returning its latency.
+func (r *ScalingRunner) write(updateType p4.Update_Type, entries []*p4.TableEntry) (time.Duration, error) {
+	start := time.Now()
+	errors := <-r.Client.Write(tableEntryRequest(r.Client, updateType, entries))
+	latency := time.Since(start)
+	if failed := countFailed(errors); failed > 0 {
+		return latency, fmt.Errorf("%d of %d updates failed (%v)", failed, len(entries), DominantCode(errors))
+	}
+	return latency, nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+)
+
+// ServerInfo is what the switch reports about itself and its installed
+// pipeline, for attaching to results as reproducibility metadata.
+type ServerInfo struct {
+	P4RuntimeAPIVersion string // from Capabilities
+	PipelineName        string // P4Info pkg_info.name
+	PipelineVersion     string // P4Info pkg_info.version
+	Arch                string // P4Info pkg_info.arch
+	Cookie              uint64 // cookie of the installed pipeline config
+}
+
+// FetchServerInfo gathers ServerInfo with a Capabilities call and a
+// GetForwardingPipelineConfig call.
+func (c *p4rtClient) FetchServerInfo(ctx context.Context) (ServerInfo, error) {
+	var info ServerInfo
+	caps, err := c.client.Capabilities(ctx, &p4.CapabilitiesRequest{})
+	if err != nil {
+		return info, errors.Wrap(err, "error getting capabilities")
+	}
+	info.P4RuntimeAPIVersion = caps.GetP4RuntimeApiVersion()
+
+	config, err := getPipelineConfig(ctx, c.client, c.deviceID)
+	if err != nil {
+		return info, err
+	}
+	pkgInfo := config.GetP4Info().GetPkgInfo()
+	info.PipelineName = pkgInfo.GetName()
+	info.PipelineVersion = pkgInfo.GetVersion()
+	info.Arch = pkgInfo.GetArch()
+	info.Cookie = config.GetCookie().GetCookie()
+	return info, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+)
+
+// Shutdown stops the client. New writes fail with codes.Canceled at once.
+// Writes already accepted are given until ctx is done to be answered; then
+// the client context is cancelled, so RPCs still in flight are cancelled
+// and writes still queued fail with codes.Canceled. Every response channel
+// has been sent to by the time Shutdown returns. The write threads have
+// returned, the stream channel, the connections of extra write pipelines
+// and the trace buffer are closed, and the client is removed from the
+// CreateOrGetP4RuntimeClient cache. The error is ctx's if writes had to be cancelled.
+func (c *p4rtClient) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if c.shutDown {
+		c.mu.Unlock()
+		return nil
+	}
+	c.shutDown = true
+	cancel := c.cancelCtx
+	c.mu.Unlock()
+	c.flightMu.Lock()
+	c.draining = true
+	c.flightMu.Unlock()
+
+	// Wake the drain below when ctx ends
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.wakeFlowControl()
+		case <-stop:
+		}
+	}()
+	c.waitAnswered(ctx)
+	close(stop)
+	err := ctx.Err()
+
+	cancel()
+	c.CancelPending()
+	c.waitAnswered(context.Background())
+
+	c.streamSendMu.Lock()
+	c.stream.CloseSend()
+	c.streamSendMu.Unlock()
+	c.streamCancel()
+	<-c.streamDone
+	c.stopWriteThreads()
+	c.closeWritePipelines()
+	c.closeTraceBuffer()
+
+	p4rtClientsMu.Lock()
+	key := p4rtClientKey{host: c.host, deviceID: c.deviceID}
+	if p4rtClients[key] == P4RuntimeClient(c) {
+		delete(p4rtClients, key)
+	}
+	p4rtClientsMu.Unlock()
+	return err
+}
+
+// Close shuts the client down without waiting for outstanding writes; see
+// Shutdown.
+func (c *p4rtClient) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Shutdown(ctx)
+	return nil
+}
+
+func (c *p4rtClient) isShutDown() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.shutDown
+}
+
+// trackWrite counts a write accepted by Write until writeAnswered. It
+// returns false, and counts nothing, once Shutdown has started waiting for
+// writes, so that no write slips in behind its wait.
+func (c *p4rtClient) trackWrite() bool {
+	c.flightMu.Lock()
+	defer c.flightMu.Unlock()
+	if c.draining {
+		return false
+	}
+	c.unanswered++
+	return true
+}
+
+func (c *p4rtClient) writeAnswered() {
+	c.flightMu.Lock()
+	defer c.flightMu.Unlock()
+	c.unanswered--
+	c.flightCond.Broadcast()
+}
+
+// waitAnswered blocks until every accepted write has been answered or ctx
+// is done. Something must call wakeFlowControl when ctx ends.
+func (c *p4rtClient) waitAnswered(ctx context.Context) {
+	c.flightMu.Lock()
+	defer c.flightMu.Unlock()
+	for c.unanswered > 0 && ctx.Err() == nil {
+		c.flightCond.Wait()
+	}
+}
+
+// mergeContexts returns a context with parent's values that is done when
+// either parent or other is, and a function to release it.
+func mergeContexts(parent, other context.Context) (context.Context, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if deadline, ok := other.Deadline(); ok {
+		// Keep other's deadline so that the RPC fails with DeadlineExceeded
+		ctx, cancel = context.WithDeadline(parent, deadline)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	go func() {
+		select {
+		case <-other.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,114 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// TableSnapshot is the content of a table at one point in time, keyed by
+// EntryKey.
+type TableSnapshot struct {
+	TableName string
+	Taken     time.Time
+	entries   map[string]*p4.TableEntry
+}
+
+// Len returns the number of entries in the snapshot.
+func (s *TableSnapshot) Len() int {
+	return len(s.entries)
+}
+
+// EntryChange is an entry whose key is unchanged but whose action or
+// metadata cookie differs.
+type EntryChange struct {
+	Before *p4.TableEntry
+	After  *p4.TableEntry
+}
+
+// TableDiff lists how a table changed between two snapshots.
+type TableDiff struct {
+	Added   []*p4.TableEntry
+	Removed []*p4.TableEntry
+	Changed []EntryChange
+}
+
+// Empty reports whether the diff has no changes.
+func (d TableDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Lookup returns the snapshot's entry with the same key as entry, for
+// example to check that an entry written with a metadata cookie reads back
+// with the same cookie.
+func (s *TableSnapshot) Lookup(entry *p4.TableEntry) (*p4.TableEntry, bool) {
+	found, ok := s.entries[EntryKey(entry)]
+	return found, ok
+}
+
+// ReadTableSnapshot reads every entry of tableName.
+func (c *p4rtClient) ReadTableSnapshot(tableName string) (*TableSnapshot, error) {
+	entity, err := c.tableWildcard(tableName)
+	if err != nil {
+		return nil, err
+	}
+	taken := time.Now()
+	entities, err := c.readEntities(entity)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &TableSnapshot{
+		TableName: tableName,
+		Taken:     taken,
+		entries:   make(map[string]*p4.TableEntry, len(entities)),
+	}
+	for _, e := range entities {
+		if entry := e.GetTableEntry(); entry != nil {
+			snapshot.entries[EntryKey(entry)] = entry
+		}
+	}
+	return snapshot, nil
+}
+
+// DiffSince reads the snapshot's table again and reports the entries added,
+// removed, or whose action, parameters or metadata changed since the
+// snapshot. This is a best-effort substitute for change tracking, which
+// P4Runtime lacks.
+func (c *p4rtClient) DiffSince(snapshot *TableSnapshot) (TableDiff, error) {
+	current, err := c.ReadTableSnapshot(snapshot.TableName)
+	if err != nil {
+		return TableDiff{}, err
+	}
+	return DiffSnapshots(snapshot, current), nil
+}
+
+// DiffSnapshots compares two snapshots of the same table.
+func DiffSnapshots(before, after *TableSnapshot) TableDiff {
+	var diff TableDiff
+	for key, entry := range after.entries {
+		old, ok := before.entries[key]
+		if !ok {
+			diff.Added = append(diff.Added, entry)
+		} else if !proto.Equal(old.GetAction(), entry.GetAction()) || !sameMetadata(old, entry) {
+			diff.Changed = append(diff.Changed, EntryChange{Before: old, After: entry})
+		}
+	}
+	for key, entry := range before.entries {
+		if _, ok := after.entries[key]; !ok {
+			diff.Removed = append(diff.Removed, entry)
+		}
+	}
+	return diff
+}
+
+// sameMetadata compares the controller cookies of two entries: metadata and
+// the deprecated controller_metadata, which older controllers still set.
+func sameMetadata(a, b *p4.TableEntry) bool {
+	return bytes.Equal(a.GetMetadata(), b.GetMetadata()) && a.GetControllerMetadata() == b.GetControllerMetadata()
+}
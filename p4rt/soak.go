@@ -0,0 +1,193 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// SoakRunner keeps a table at a steady occupancy while churning it, for
+// long soak runs. It fills the table with Occupancy entries, then, until
+// cancelled, sends batches that each delete the BatchSize oldest entries
+// and insert as many new ones, so about Occupancy entries stay live.
+type SoakRunner struct {
+	Client P4RuntimeClient
+	// Entry returns the entry of key n, as EntryGenerator.Entry does.
+	// Distinct keys below Keys must give distinct match keys.
+	Entry func(n uint64) (*p4.TableEntry, error)
+	// Keys is the number of keys Entry can produce, for example
+	// EntryGenerator.Size; keys are reused from 0 once they run out. Zero
+	// means unbounded. It must leave room for the Occupancy entries live
+	// plus those of the writes in flight.
+	Keys uint64
+	// Occupancy must be at least MaxInFlight*BatchSize, so that the write
+	// that inserted the entries a churn write deletes has been answered
+	// before the churn write is sent; writes in flight may reach the switch
+	// in any order.
+	Occupancy   int
+	BatchSize   int // deletes, and inserts, per churn write
+	MaxInFlight int // churn writes submitted and not yet answered
+	// Rate paces the churn, in updates per second, with SetWriteRate; zero
+	// churns as fast as the switch takes it. The fill is never paced.
+	Rate float64
+
+	// Snapshots, if set, receives a RunSummary of the churn writes of each
+	// SnapshotInterval. A snapshot is dropped if Snapshots is full.
+	Snapshots        chan<- RunSummary
+	SnapshotInterval time.Duration
+
+	// Metadata is carried by the summary and the snapshots.
+	Metadata RunMetadata
+}
+
+// Run fills the table and churns it until ctx is done, then waits for the
+// writes in flight and returns a summary of all churn writes. The entries
+// live at the end are left installed. It fails if the fill does not
+// succeed, since the occupancy would not be known; failures during the
+// churn are only counted.
+func (r *SoakRunner) Run(ctx context.Context) (RunSummary, error) {
+	if r.Occupancy < 1 || r.BatchSize < 1 || r.MaxInFlight < 1 {
+		return RunSummary{}, fmt.Errorf("invalid occupancy %d, batch size %d or writes in flight %d",
+			r.Occupancy, r.BatchSize, r.MaxInFlight)
+	}
+	if r.Occupancy < r.MaxInFlight*r.BatchSize {
+		return RunSummary{}, fmt.Errorf("occupancy %d is below %d writes of %d in flight; deletes could overtake their inserts",
+			r.Occupancy, r.MaxInFlight, r.BatchSize)
+	}
+	if r.Keys > 0 && r.Keys < uint64(r.Occupancy+r.MaxInFlight*r.BatchSize) {
+		return RunSummary{}, fmt.Errorf("%d keys are too few for %d entries live and %d writes of %d in flight",
+			r.Keys, r.Occupancy, r.MaxInFlight, r.BatchSize)
+	}
+	if r.Snapshots != nil && r.SnapshotInterval <= 0 {
+		return RunSummary{}, fmt.Errorf("invalid snapshot interval %v", r.SnapshotInterval)
+	}
+	key := func(n uint64) uint64 {
+		if r.Keys > 0 {
+			return n % r.Keys
+		}
+		return n
+	}
+	entries := func(first uint64, n int) ([]*p4.TableEntry, error) {
+		batch := make([]*p4.TableEntry, n)
+		for i := range batch {
+			entry, err := r.Entry(key(first + uint64(i)))
+			if err != nil {
+				return nil, err
+			}
+			batch[i] = entry
+		}
+		return batch, nil
+	}
+
+	for filled := 0; filled < r.Occupancy; {
+		n := r.Occupancy - filled
+		if n > r.BatchSize {
+			n = r.BatchSize
+		}
+		batch, err := entries(uint64(filled), n)
+		if err != nil {
+			return RunSummary{}, err
+		}
+		errors := <-r.Client.Write(tableEntryRequest(r.Client, p4.Update_INSERT, batch))
+		if failed := countFailed(errors); failed > 0 {
+			return RunSummary{}, fmt.Errorf("filling to %d entries: %d of %d updates failed (%v)",
+				r.Occupancy, failed, n, DominantCode(errors))
+		}
+		filled += n
+	}
+
+	// churn deletes the batch of live entries from oldest and inserts the
+	// batch that follows the newest
+	churn := func(oldest uint64) (*p4.WriteRequest, error) {
+		deletes, err := entries(oldest, r.BatchSize)
+		if err != nil {
+			return nil, err
+		}
+		inserts, err := entries(oldest+uint64(r.Occupancy), r.BatchSize)
+		if err != nil {
+			return nil, err
+		}
+		req := tableEntryRequest(r.Client, p4.Update_DELETE, deletes)
+		req.Updates = append(req.Updates, tableEntryRequest(r.Client, p4.Update_INSERT, inserts).Updates...)
+		return req, nil
+	}
+
+	if r.Rate > 0 {
+		r.Client.SetWriteRate(r.Rate, 2*r.BatchSize)
+		defer r.Client.SetWriteRate(0, 1)
+	}
+	newAggregator := func() *TraceAggregator {
+		a := NewTraceAggregator(0)
+		a.SetMetadata(r.Metadata)
+		return a
+	}
+	total := newAggregator()
+	var mu sync.Mutex
+	interval := newAggregator() // guarded by mu
+	var snapshot <-chan time.Time
+	if r.Snapshots != nil {
+		ticker := time.NewTicker(r.SnapshotInterval)
+		defer ticker.Stop()
+		snapshot = ticker.C
+	}
+	inFlight := make(chan struct{}, r.MaxInFlight)
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	intervalStart := start
+	oldest := uint64(0) // key of the oldest live entry, before wrapping
+run:
+	for {
+		select {
+		case <-ctx.Done():
+			break run
+		case now := <-snapshot:
+			mu.Lock()
+			summary := interval.Summary(now.Sub(intervalStart))
+			interval = newAggregator()
+			mu.Unlock()
+			intervalStart = now
+			select {
+			case r.Snapshots <- summary:
+			default:
+			}
+		case inFlight <- struct{}{}:
+			req, err := churn(oldest)
+			if err != nil {
+				<-inFlight
+				wg.Wait()
+				return total.Summary(time.Since(start)), err
+			}
+			oldest += uint64(r.BatchSize)
+			submitted := time.Now()
+			res := r.Client.Write(req)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errors := <-res
+				<-inFlight
+				trace := WriteTrace{
+					Start:     submitted,
+					BatchSize: len(errors),
+					Duration:  time.Since(submitted),
+					Errors:    errors,
+				}
+				trace.ErrorCount = countFailed(errors)
+				trace.SuccessCount = len(errors) - trace.ErrorCount
+				total.Add(trace)
+				mu.Lock()
+				interval.Add(trace)
+				mu.Unlock()
+			}()
+		}
+	}
+	wg.Wait()
+	return total.Summary(time.Since(start)), nil
+}
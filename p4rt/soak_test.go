@@ -0,0 +1,89 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSoakRunner(t *testing.T) {
+	c, fake := newTestClient(t, 10, 4)
+	// The switch keeps the live keys, rejecting inserts of live keys and
+	// deletes of missing ones
+	live := map[byte]bool{}
+	fake.setFail(func(n int, req *p4.WriteRequest) error {
+		for _, update := range req.GetUpdates() {
+			key := update.GetEntity().GetTableEntry().GetMatch()[0].GetExact().GetValue()[0]
+			if (update.GetType() == p4.Update_INSERT) == live[key] {
+				return status.Errorf(codes.FailedPrecondition, "%v of key %d live as %v", update.GetType(), key, live[key])
+			}
+			live[key] = update.GetType() == p4.Update_INSERT
+		}
+		return nil
+	})
+
+	snapshots := make(chan RunSummary, 100)
+	runner := &SoakRunner{
+		Client: c,
+		Entry: func(n uint64) (*p4.TableEntry, error) {
+			return &p4.TableEntry{TableId: 1, Match: []*p4.FieldMatch{{
+				FieldId:        1,
+				FieldMatchType: &p4.FieldMatch_Exact_{Exact: &p4.FieldMatch_Exact{Value: []byte{byte(n)}}},
+			}}}, nil
+		},
+		Keys:             100,
+		Occupancy:        20,
+		BatchSize:        5,
+		MaxInFlight:      4, // the most that 20 entries allow
+		Rate:             2000,
+		Snapshots:        snapshots,
+		SnapshotInterval: 30 * time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	summary, err := runner.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Writes == 0 || summary.FailedUpdates != 0 || summary.Updates != 10*summary.Writes {
+		t.Errorf("churn summary: %v", summary)
+	}
+	occupancy := 0
+	fake.mu.Lock()
+	for _, isLive := range live {
+		if isLive {
+			occupancy++
+		}
+	}
+	fake.mu.Unlock()
+	if occupancy != 20 {
+		t.Errorf("%d entries live after the soak, want 20", occupancy)
+	}
+	if n := len(snapshots); n < 3 {
+		t.Errorf("got %d snapshots, want about 6", n)
+	}
+	// At 2000 updates/sec the keys wrap around after 100 well within the run
+	if writes := summary.Writes; writes*5+20 <= 100 {
+		t.Errorf("only %d churn writes; the keys did not wrap", writes)
+	}
+}
+
+func TestSoakRunnerRejectsOvertakingDeletes(t *testing.T) {
+	runner := &SoakRunner{
+		Entry:       func(n uint64) (*p4.TableEntry, error) { return &p4.TableEntry{}, nil },
+		Occupancy:   20,
+		BatchSize:   5,
+		MaxInFlight: 5,
+	}
+	if _, err := runner.Run(context.Background()); err == nil {
+		t.Error("soak with deletes that could overtake their inserts was run")
+	}
+}
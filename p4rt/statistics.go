@@ -0,0 +1,309 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ReadTrace describes one Read RPC, as WriteTrace does a write.
+type ReadTrace struct {
+	Start    time.Time // when the Read RPC was started
+	Duration time.Duration
+	// FirstResponse is how long the first ReadResponse took to arrive; zero
+	// if none did.
+	FirstResponse time.Duration
+	Responses     int // ReadResponses received
+	Entities      int // entities they carried
+	// Kind is the kind of entity requested, e.g. "counter_entry", taken
+	// from the first entity of the request.
+	Kind string
+	// Code is the gRPC status code that ended the read, OK if it ran to the
+	// end; Err is the error itself.
+	Code codes.Code
+	Err  error
+	// Phase is the name set with SetPhase when the read was started.
+	Phase string
+}
+
+// SetReadTraceChan sends a ReadTrace for every Read RPC the client makes,
+// including those of helpers such as ReadCounters and snapshots, to
+// traceChan. A trace is dropped if traceChan is full. Passing nil stops
+// tracing reads.
+func (c *p4rtClient) SetReadTraceChan(traceChan chan ReadTrace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readTraceChan = traceChan
+}
+
+// readTrace is a ReadTrace in progress and where to deliver it.
+type readTrace struct {
+	ReadTrace
+	traceChan chan ReadTrace
+}
+
+func (c *p4rtClient) startReadTrace(req *p4.ReadRequest) *readTrace {
+	c.mu.RLock()
+	traceChan, phase := c.readTraceChan, c.phase
+	c.mu.RUnlock()
+	if traceChan == nil {
+		return nil
+	}
+	trace := &readTrace{traceChan: traceChan}
+	trace.Start = time.Now()
+	trace.Phase = phase
+	if len(req.GetEntities()) > 0 {
+		trace.Kind = entityKind(req.GetEntities()[0])
+	}
+	return trace
+}
+
+func (t *readTrace) addResponse(res *p4.ReadResponse) {
+	if t == nil {
+		return
+	}
+	if t.Responses == 0 {
+		t.FirstResponse = time.Since(t.Start)
+	}
+	t.Responses++
+	t.Entities += len(res.GetEntities())
+}
+
+func (c *p4rtClient) deliverReadTrace(t *readTrace, err error) {
+	if t == nil {
+		return
+	}
+	t.Duration = time.Since(t.Start)
+	t.Err = err
+	t.Code = status.Code(errors.Cause(err))
+	select {
+	case t.traceChan <- t.ReadTrace:
+	default:
+		atomic.AddUint64(&c.readTracesDropped, 1)
+		fmt.Println("Read trace channel full. Discarding trace")
+	}
+}
+
+// entityKind names the kind of entity, as in the P4Runtime Entity oneof.
+func entityKind(entity *p4.Entity) string {
+	switch entity.GetEntity().(type) {
+	case *p4.Entity_TableEntry:
+		return "table_entry"
+	case *p4.Entity_ActionProfileMember:
+		return "action_profile_member"
+	case *p4.Entity_ActionProfileGroup:
+		return "action_profile_group"
+	case *p4.Entity_MeterEntry:
+		return "meter_entry"
+	case *p4.Entity_DirectMeterEntry:
+		return "direct_meter_entry"
+	case *p4.Entity_CounterEntry:
+		return "counter_entry"
+	case *p4.Entity_DirectCounterEntry:
+		return "direct_counter_entry"
+	case *p4.Entity_PacketReplicationEngineEntry:
+		return "packet_replication_engine_entry"
+	case *p4.Entity_ValueSetEntry:
+		return "value_set_entry"
+	case *p4.Entity_RegisterEntry:
+		return "register_entry"
+	case *p4.Entity_DigestEntry:
+		return "digest_entry"
+	case *p4.Entity_ExternEntry:
+		return "extern_entry"
+	}
+	return ""
+}
+
+// ReadCounters reads every index of the indirect counter array counterName
+// in one wildcard read.
+func (c *p4rtClient) ReadCounters(counterName string) ([]*p4.CounterEntry, error) {
+	p4info, err := c.p4infoHelper()
+	if err != nil {
+		return nil, err
+	}
+	counter, err := p4info.GetCounter(counterName)
+	if err != nil {
+		return nil, err
+	}
+	entities, err := c.readEntities(&p4.Entity{Entity: &p4.Entity_CounterEntry{CounterEntry: &p4.CounterEntry{
+		CounterId: counter.GetPreamble().GetId(),
+	}}})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*p4.CounterEntry, 0, len(entities))
+	for _, entity := range entities {
+		if entry := entity.GetCounterEntry(); entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// ReadDirectCounters reads the direct counter of every entry of tableName
+// in one wildcard read.
+func (c *p4rtClient) ReadDirectCounters(tableName string) ([]*p4.DirectCounterEntry, error) {
+	p4info, err := c.p4infoHelper()
+	if err != nil {
+		return nil, err
+	}
+	table, err := p4info.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(table.GetDirectResourceIds()) == 0 {
+		return nil, fmt.Errorf("table %s has no direct resources", tableName)
+	}
+	entities, err := c.readEntities(&p4.Entity{Entity: &p4.Entity_DirectCounterEntry{DirectCounterEntry: &p4.DirectCounterEntry{
+		TableEntry: &p4.TableEntry{TableId: table.GetPreamble().GetId()},
+	}}})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*p4.DirectCounterEntry, 0, len(entities))
+	for _, entity := range entities {
+		if entry := entity.GetDirectCounterEntry(); entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// WriteMeterEntries sets the config of each entry, in batches of the
+// client's batch size sent at once, and returns one p4.Error per entry, in
+// order. The writes are traced like any other.
+func (c *p4rtClient) WriteMeterEntries(entries []*p4.MeterEntry) []*p4.Error {
+	entities := make([]*p4.Entity, len(entries))
+	for i, entry := range entries {
+		entities[i] = &p4.Entity{Entity: &p4.Entity_MeterEntry{MeterEntry: entry}}
+	}
+	return c.modifyEntities(entities)
+}
+
+// WriteDirectMeterEntries sets the config of each direct meter entry, as
+// WriteMeterEntries does. Each entry's TableEntry must identify an installed
+// table entry by its match fields.
+func (c *p4rtClient) WriteDirectMeterEntries(entries []*p4.DirectMeterEntry) []*p4.Error {
+	entities := make([]*p4.Entity, len(entries))
+	for i, entry := range entries {
+		entities[i] = &p4.Entity{Entity: &p4.Entity_DirectMeterEntry{DirectMeterEntry: entry}}
+	}
+	return c.modifyEntities(entities)
+}
+
+// modifyEntities writes MODIFY updates of entities in batches and collects
+// the errors in entity order.
+func (c *p4rtClient) modifyEntities(entities []*p4.Entity) []*p4.Error {
+	batchSize := c.getBatchSize()
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	var responses []<-chan []*p4.Error
+	for start := 0; start < len(entities); start += batchSize {
+		end := start + batchSize
+		if end > len(entities) {
+			end = len(entities)
+		}
+		updates := make([]*p4.Update, 0, end-start)
+		for _, entity := range entities[start:end] {
+			updates = append(updates, &p4.Update{Type: p4.Update_MODIFY, Entity: entity})
+		}
+		responses = append(responses, c.Write(&p4.WriteRequest{
+			DeviceId:   c.deviceID,
+			ElectionId: c.ElectionID(),
+			Updates:    updates,
+		}))
+	}
+	results := make([]*p4.Error, 0, len(entities))
+	for _, res := range responses {
+		results = append(results, <-res...)
+	}
+	return results
+}
+
+// ReadBenchmarkResult describes a run of StatsReadBenchmark.
+type ReadBenchmarkResult struct {
+	Reads    int            // reads made
+	Failed   int            // reads that returned an error
+	Entities int            // entities returned by successful reads
+	Elapsed  time.Duration  // wall-clock time for the whole run
+	Latency  LatencySummary // per-read latency
+}
+
+// ReadsPerSec returns the successful reads per second over the run.
+func (r ReadBenchmarkResult) ReadsPerSec() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Reads-r.Failed) / r.Elapsed.Seconds()
+}
+
+// EntitiesPerSec returns the entities read per second over the run.
+func (r ReadBenchmarkResult) EntitiesPerSec() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Entities) / r.Elapsed.Seconds()
+}
+
+func (r ReadBenchmarkResult) String() string {
+	return fmt.Sprintf("%d reads (%d failed) of %d entities in %v, %.1f reads/sec, %.1f entities/sec, read latency %v",
+		r.Reads, r.Failed, r.Entities, r.Elapsed, r.ReadsPerSec(), r.EntitiesPerSec(), r.Latency)
+}
+
+// StatsReadBenchmark calls read back to back from readers goroutines until
+// stop is closed, and reports how fast reads were served. read returns the
+// number of entities it read, e.g. the length of what ReadCounters
+// returned. Running it while a write benchmark is in progress measures
+// statistics reads under table write load.
+func StatsReadBenchmark(read func() (int, error), readers int, stop <-chan struct{}) (ReadBenchmarkResult, error) {
+	if readers < 1 {
+		return ReadBenchmarkResult{}, fmt.Errorf("invalid number of readers %d", readers)
+	}
+	var result ReadBenchmarkResult
+	var latencies []time.Duration
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				readStart := time.Now()
+				n, err := read()
+				latency := time.Since(readStart)
+				mu.Lock()
+				result.Reads++
+				if err != nil {
+					result.Failed++
+				} else {
+					result.Entities += n
+				}
+				latencies = append(latencies, latency)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+	result.Latency = SummarizeLatencies(latencies)
+	return result, nil
+}
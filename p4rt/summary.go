@@ -0,0 +1,175 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+const defaultTraceReservoir = 10000
+
+// RunSummary summarizes a run from its write traces: a TraceSummary plus
+// the jitter of write durations.
+type RunSummary struct {
+	TraceSummary
+	// StdDev is the sample standard deviation of the write durations.
+	StdDev time.Duration
+	// Elapsed is the run time the summary's throughput is measured over.
+	Elapsed time.Duration
+	// Metadata describes the run, so that an archived summary is
+	// self-describing.
+	Metadata RunMetadata
+}
+
+func (s RunSummary) String() string {
+	if len(s.Metadata) > 0 {
+		return fmt.Sprintf("%v, stddev %v over %v (%v)", s.TraceSummary, s.StdDev, s.Elapsed, s.Metadata)
+	}
+	return fmt.Sprintf("%v, stddev %v over %v", s.TraceSummary, s.StdDev, s.Elapsed)
+}
+
+// TraceAggregator summarizes write traces as they arrive, in bounded
+// memory, for runs too long to keep every trace as SummarizeTraces needs.
+// Counts, mean, min, max and standard deviation are exact; the mean and
+// variance are kept with Welford's algorithm. Percentiles are taken from a
+// fixed-size uniform sample of the durations. It is safe for concurrent
+// use.
+type TraceAggregator struct {
+	mu        sync.Mutex
+	writes    int
+	updates   int
+	failed    int
+	codes     map[codes.Code]int
+	mean      float64 // running mean of the durations, in nanoseconds
+	m2        float64 // sum of squared distances from the mean
+	min       time.Duration
+	max       time.Duration
+	reservoir []time.Duration
+	size      int
+	rng       *rand.Rand
+	metadata  RunMetadata
+}
+
+// NewTraceAggregator returns an aggregator that keeps up to reservoir
+// durations for percentiles; 0 means 10000.
+func NewTraceAggregator(reservoir int) *TraceAggregator {
+	if reservoir <= 0 {
+		reservoir = defaultTraceReservoir
+	}
+	return &TraceAggregator{
+		codes: make(map[codes.Code]int),
+		size:  reservoir,
+		// Seeded, so the same traces give the same percentiles
+		rng: rand.New(rand.NewSource(1)),
+	}
+}
+
+// Add counts trace.
+func (a *TraceAggregator) Add(trace WriteTrace) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.writes++
+	a.updates += trace.BatchSize
+	a.failed += trace.ErrorCount
+	for _, p4Err := range trace.Errors {
+		if code := codes.Code(p4Err.GetCanonicalCode()); code != codes.OK {
+			a.codes[code]++
+		}
+	}
+
+	d := trace.Duration
+	x := float64(d)
+	delta := x - a.mean
+	a.mean += delta / float64(a.writes)
+	a.m2 += delta * (x - a.mean)
+	if a.writes == 1 || d < a.min {
+		a.min = d
+	}
+	if d > a.max {
+		a.max = d
+	}
+
+	// Reservoir sampling (Algorithm R): every duration so far is in the
+	// sample with the same probability
+	if len(a.reservoir) < a.size {
+		a.reservoir = append(a.reservoir, d)
+	} else if i := a.rng.Intn(a.writes); i < a.size {
+		a.reservoir[i] = d
+	}
+}
+
+// Consume adds every trace received on traceChan until it is closed.
+func (a *TraceAggregator) Consume(traceChan <-chan WriteTrace) {
+	for trace := range traceChan {
+		a.Add(trace)
+	}
+}
+
+// SetMetadata sets the metadata carried by every later Summary.
+func (a *TraceAggregator) SetMetadata(metadata RunMetadata) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.metadata = metadata
+}
+
+// Count returns the number of traces added.
+func (a *TraceAggregator) Count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.writes
+}
+
+// StdDev returns the sample standard deviation of the write durations, or
+// zero for fewer than two traces.
+func (a *TraceAggregator) StdDev() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stdDev()
+}
+
+func (a *TraceAggregator) stdDev() time.Duration {
+	if a.writes < 2 {
+		return 0
+	}
+	return time.Duration(math.Sqrt(a.m2 / float64(a.writes-1)))
+}
+
+// Summary summarizes the traces added so far, with throughput over elapsed.
+func (a *TraceAggregator) Summary(elapsed time.Duration) RunSummary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	summary := RunSummary{
+		TraceSummary: TraceSummary{
+			Writes:        a.writes,
+			Updates:       a.updates,
+			FailedUpdates: a.failed,
+			Codes:         make(map[codes.Code]int, len(a.codes)),
+		},
+		StdDev:   a.stdDev(),
+		Elapsed:  elapsed,
+		Metadata: a.metadata,
+	}
+	for code, n := range a.codes {
+		summary.Codes[code] = n
+	}
+	if a.writes > 0 {
+		latency := SummarizeLatencies(a.reservoir)
+		latency.Count = a.writes
+		latency.Min = a.min
+		latency.Mean = time.Duration(a.mean)
+		latency.Max = a.max
+		summary.Latency = latency
+	}
+	if elapsed > 0 {
+		summary.Throughput = float64(a.updates-a.failed) / elapsed.Seconds()
+	}
+	return summary
+}
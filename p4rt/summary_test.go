@@ -0,0 +1,77 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+)
+
+func TestTraceAggregatorStdDev(t *testing.T) {
+	a := NewTraceAggregator(0)
+	if a.StdDev() != 0 {
+		t.Errorf("stddev of no traces is %v, want 0", a.StdDev())
+	}
+	// 2, 4, 4, 4, 5, 5, 7, 9 ms: mean 5ms, sample variance 32/7 ms²
+	for _, ms := range []int{2, 4, 4, 4, 5, 5, 7, 9} {
+		a.Add(WriteTrace{Duration: time.Duration(ms) * time.Millisecond, BatchSize: 1})
+	}
+	want := time.Duration(math.Sqrt(32.0/7) * float64(time.Millisecond))
+	if got := a.StdDev(); got < want-time.Microsecond || got > want+time.Microsecond {
+		t.Errorf("stddev is %v, want %v", got, want)
+	}
+	summary := a.Summary(time.Second)
+	if summary.StdDev != a.StdDev() || summary.Latency.Mean != 5*time.Millisecond ||
+		summary.Latency.Min != 2*time.Millisecond || summary.Latency.Max != 9*time.Millisecond {
+		t.Errorf("summary is %v", summary)
+	}
+}
+
+// TestTraceAggregatorBounded checks that the aggregator keeps counts exact
+// while holding only its reservoir of durations.
+func TestTraceAggregatorBounded(t *testing.T) {
+	const traces = 100000
+	a := NewTraceAggregator(1000)
+	failed := []*p4.Error{{CanonicalCode: int32(codes.OK)}, {CanonicalCode: int32(codes.AlreadyExists)}}
+	for i := 0; i < traces; i++ {
+		trace := WriteTrace{Duration: time.Duration(i%1000+1) * time.Microsecond, BatchSize: 2}
+		if i%10 == 0 {
+			trace.Errors, trace.ErrorCount = failed, 1
+		}
+		a.Add(trace)
+	}
+	if len(a.reservoir) != 1000 {
+		t.Errorf("aggregator holds %d durations, want 1000", len(a.reservoir))
+	}
+	summary := a.Summary(10 * time.Second)
+	if summary.Writes != traces || summary.Updates != 2*traces || summary.FailedUpdates != traces/10 ||
+		summary.Codes[codes.AlreadyExists] != traces/10 || summary.Latency.Count != traces {
+		t.Errorf("summary is %v", summary)
+	}
+	if want := float64(2*traces-traces/10) / 10; summary.Throughput != want {
+		t.Errorf("throughput is %v, want %v", summary.Throughput, want)
+	}
+	// Durations are uniform over 1..1000µs
+	if p50 := summary.Latency.P50; p50 < 400*time.Microsecond || p50 > 600*time.Microsecond {
+		t.Errorf("p50 is %v, want about 500µs", p50)
+	}
+}
+
+func TestTraceAggregatorMetadata(t *testing.T) {
+	a := NewTraceAggregator(0)
+	a.Add(WriteTrace{Duration: time.Millisecond, BatchSize: 1})
+	if summary := a.Summary(time.Second); summary.Metadata != nil {
+		t.Errorf("summary without metadata carries %v", summary.Metadata)
+	}
+	a.SetMetadata(RunMetadata{"switch": "tofino", "sha": "abc123"})
+	summary := a.Summary(time.Second)
+	if summary.Metadata["switch"] != "tofino" || summary.Metadata["sha"] != "abc123" {
+		t.Errorf("summary carries metadata %v", summary.Metadata)
+	}
+}
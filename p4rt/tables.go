@@ -0,0 +1,170 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+
+	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// SetDefaultAction modifies the default entry of tableName to run actionName
+// with the given parameters (keyed by parameter name). The entry is checked
+// against the P4Info first: the table must not be const, must not have a
+// const default action, and must allow actionName as a default action.
+func (c *p4rtClient) SetDefaultAction(tableName string, actionName string, params map[string][]byte) <-chan []*p4.Error {
+	entry, err := c.buildDefaultEntry(tableName, actionName, params)
+	if err != nil {
+		return failedWrite(1, codes.InvalidArgument, err.Error())
+	}
+	return c.Write(&p4.WriteRequest{
+		DeviceId:   c.deviceID,
+		ElectionId: c.ElectionID(),
+		Updates: []*p4.Update{{
+			Type:   p4.Update_MODIFY, // the default entry always exists
+			Entity: &p4.Entity{Entity: &p4.Entity_TableEntry{TableEntry: entry}},
+		}},
+	})
+}
+
+func (c *p4rtClient) buildDefaultEntry(tableName string, actionName string, params map[string][]byte) (*p4.TableEntry, error) {
+	p4info, err := c.p4infoHelper()
+	if err != nil {
+		return nil, err
+	}
+	table, err := p4info.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	action, err := p4info.GetAction(actionName)
+	if err != nil {
+		return nil, err
+	}
+
+	if table.GetIsConstTable() {
+		return nil, fmt.Errorf("table %s is const", tableName)
+	}
+	if table.GetConstDefaultActionId() != 0 {
+		return nil, fmt.Errorf("table %s has a const default action", tableName)
+	}
+	var ref *p4_config.ActionRef
+	for _, r := range table.GetActionRefs() {
+		if r.GetId() == action.GetPreamble().GetId() {
+			ref = r
+			break
+		}
+	}
+	if ref == nil {
+		return nil, fmt.Errorf("action %s is not an action of table %s", actionName, tableName)
+	}
+	if ref.GetScope() == p4_config.ActionRef_TABLE_ONLY {
+		return nil, fmt.Errorf("action %s cannot be the default action of table %s", actionName, tableName)
+	}
+
+	actionParams, err := buildActionParams(action, params)
+	if err != nil {
+		return nil, err
+	}
+	return &p4.TableEntry{
+		TableId:         table.GetPreamble().GetId(),
+		IsDefaultAction: true,
+		Action: &p4.TableAction{Type: &p4.TableAction_Action{Action: &p4.Action{
+			ActionId: action.GetPreamble().GetId(),
+			Params:   actionParams,
+		}}},
+	}, nil
+}
+
+// tableWildcard returns an entity matching every entry of tableName.
+func (c *p4rtClient) tableWildcard(tableName string) (*p4.Entity, error) {
+	p4info, err := c.p4infoHelper()
+	if err != nil {
+		return nil, err
+	}
+	tableID, err := p4info.GetP4Id(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return &p4.Entity{Entity: &p4.Entity_TableEntry{
+		TableEntry: &p4.TableEntry{TableId: tableID},
+	}}, nil
+}
+
+// CountMethod says how CountEntries obtained its count.
+type CountMethod string
+
+// CountByStreamingRead counts the entries of a wildcard read as they
+// arrive. P4Runtime defines no cheaper way to ask a switch for a count, so
+// this is the only method today.
+const CountByStreamingRead CountMethod = "streaming-read"
+
+// CountEntries returns the number of entries installed in tableName, not
+// counting the default entry, and the method used. Entries are counted as
+// they stream in and are never held in memory together.
+func (c *p4rtClient) CountEntries(tableName string) (int, CountMethod, error) {
+	wildcard, err := c.tableWildcard(tableName)
+	if err != nil {
+		return 0, "", err
+	}
+	count := 0
+	err = c.ReadWithCallback(&p4.ReadRequest{
+		DeviceId: c.deviceID,
+		Entities: []*p4.Entity{wildcard},
+	}, func(res *p4.ReadResponse) error {
+		for _, entity := range res.GetEntities() {
+			if !entity.GetTableEntry().GetIsDefaultAction() {
+				count++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, "", errors.Wrapf(err, "reading table %s", tableName)
+	}
+	return count, CountByStreamingRead, nil
+}
+
+// TableOccupancy returns the number of entries installed in tableName, as
+// counted by CountEntries, and the table size declared in the P4Info.
+func (c *p4rtClient) TableOccupancy(tableName string) (used int, max int, err error) {
+	p4info, err := c.p4infoHelper()
+	if err != nil {
+		return 0, 0, err
+	}
+	table, err := p4info.GetTable(tableName)
+	if err != nil {
+		return 0, 0, err
+	}
+	used, _, err = c.CountEntries(tableName)
+	if err != nil {
+		return 0, 0, err
+	}
+	return used, int(table.GetSize()), nil
+}
+
+// buildActionParams maps parameter names to IDs. Every parameter of the
+// action must be given, and no others.
+func buildActionParams(action *p4_config.Action, params map[string][]byte) ([]*p4.Action_Param, error) {
+	actionName := action.GetPreamble().GetName()
+	if len(params) != len(action.GetParams()) {
+		return nil, fmt.Errorf("action %s takes %d parameters, got %d",
+			actionName, len(action.GetParams()), len(params))
+	}
+	actionParams := make([]*p4.Action_Param, 0, len(params))
+	for _, param := range action.GetParams() {
+		value, ok := params[param.GetName()]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter %s for action %s", param.GetName(), actionName)
+		}
+		actionParams = append(actionParams, &p4.Action_Param{
+			ParamId: param.GetId(),
+			Value:   value,
+		})
+	}
+	return actionParams, nil
+}
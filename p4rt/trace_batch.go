@@ -0,0 +1,139 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"sync"
+	"time"
+)
+
+// traceBatcher groups WriteTraces into slices, flushing when a batch is full
+// or when maxDelay has passed since the batch's first trace.
+type traceBatcher struct {
+	in       chan WriteTrace
+	out      chan []WriteTrace
+	quit     chan struct{} // closed by stop
+	size     int
+	maxDelay time.Duration
+
+	mu      sync.Mutex // held while pushing, so stop waits for pushes in progress
+	stopped bool
+	next    *traceBatcher // takes the traces pushed after stop, if set
+}
+
+func newTraceBatcher(out chan []WriteTrace, size int, maxDelay time.Duration) *traceBatcher {
+	if size < 1 {
+		size = 1
+	}
+	return &traceBatcher{
+		in:       make(chan WriteTrace, 10*size),
+		out:      out,
+		quit:     make(chan struct{}),
+		size:     size,
+		maxDelay: maxDelay,
+	}
+}
+
+// push queues trace, returning false if the batcher is backed up. Once the
+// batcher is stopped, trace goes to its replacement, or is refused if it has
+// none.
+func (b *traceBatcher) push(trace WriteTrace) bool {
+	b.mu.Lock()
+	if b.stopped {
+		next := b.next
+		b.mu.Unlock()
+		return next != nil && next.push(trace)
+	}
+	defer b.mu.Unlock()
+	select {
+	case b.in <- trace:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop makes run flush what was pushed and return. Later pushes go to next,
+// if it is not nil.
+func (b *traceBatcher) stop(next *traceBatcher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		return
+	}
+	b.stopped, b.next = true, next
+	close(b.quit)
+}
+
+func (b *traceBatcher) run() {
+	batch := make([]WriteTrace, 0, b.size)
+	timer := time.NewTimer(b.maxDelay)
+	timer.Stop()
+	var timeout <-chan time.Time
+
+	flush := func() {
+		if !timer.Stop() && timeout != nil {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timeout = nil
+		if len(batch) > 0 {
+			b.out <- batch
+			batch = make([]WriteTrace, 0, b.size)
+		}
+	}
+
+	for {
+		select {
+		case trace := <-b.in:
+			batch = append(batch, trace)
+			if len(batch) == 1 {
+				timer.Reset(b.maxDelay)
+				timeout = timer.C
+			}
+			if len(batch) >= b.size {
+				flush()
+			}
+		case <-timeout:
+			timeout = nil
+			flush()
+		case <-b.quit:
+			// Traces pushed before stop are still sent. stop waited for
+			// pushes in progress, so len cannot grow, and run is the only
+			// receiver, so it cannot shrink under it
+			for len(b.in) > 0 {
+				if batch = append(batch, <-b.in); len(batch) >= b.size {
+					flush()
+				}
+			}
+			flush()
+			return
+		}
+	}
+}
+
+// SetWriteTraceBatchChan delivers write traces as slices on ch. A slice is
+// sent once batchSize traces have accumulated or maxDelay after its first
+// trace, whichever comes first. Traces are dropped (and counted in Stats)
+// if the consumer falls too far behind. Passing a nil channel stops batch
+// delivery after flushing what is pending. Calling it again flushes what
+// is pending to the old channel; the traces of writes still in flight then
+// go to the new one, or are dropped if it is nil. Per-trace delivery
+// through SetWriteTraceChan is independent of this.
+func (c *p4rtClient) SetWriteTraceBatchChan(ch chan []WriteTrace, batchSize int, maxDelay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.traceBatcher
+	c.traceBatcher = nil
+	if ch != nil {
+		c.traceBatcher = newTraceBatcher(ch, batchSize, maxDelay)
+		go c.traceBatcher.run()
+	}
+	if old != nil {
+		old.stop(c.traceBatcher)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestTraceBatcherReplacedUnderLoad replaces the batch channel while writes
+// are in flight. Every trace must reach one of the channels or be counted
+// as dropped. Run it with -race.
+func TestTraceBatcherReplacedUnderLoad(t *testing.T) {
+	const (
+		writers         = 20
+		writesPerWriter = 50
+		expected        = writers * writesPerWriter
+	)
+	c, _ := newTestClient(t, 1, 4)
+
+	var delivered int64
+	var consumers sync.WaitGroup
+	batchChan := func() chan []WriteTrace {
+		ch := make(chan []WriteTrace, 1000)
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for batch := range ch {
+				atomic.AddInt64(&delivered, int64(len(batch)))
+			}
+		}()
+		return ch
+	}
+	var channels []chan []WriteTrace
+	ch := batchChan()
+	channels = append(channels, ch)
+	c.SetWriteTraceBatchChan(ch, 10, time.Millisecond)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < writesPerWriter; i++ {
+				<-c.Write(insertRequest(c, 1))
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+replace:
+	for {
+		select {
+		case <-done:
+			break replace
+		case <-time.After(time.Millisecond):
+			ch := batchChan()
+			channels = append(channels, ch)
+			c.SetWriteTraceBatchChan(ch, 10, time.Millisecond)
+		}
+	}
+	c.SetWriteTraceBatchChan(nil, 0, 0)
+
+	// The batchers flush in the background; wait for them
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n := atomic.LoadInt64(&delivered) + int64(c.Stats().TracesDropped)
+		if n == expected {
+			break
+		}
+		if n > expected || time.Now().After(deadline) {
+			t.Fatalf("%d traces delivered and %d dropped over %d channels, want %d in all",
+				atomic.LoadInt64(&delivered), c.Stats().TracesDropped, len(channels), expected)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if len(channels) < 2 {
+		t.Log("writes finished before the batch channel was replaced")
+	}
+	for _, ch := range channels {
+		close(ch)
+	}
+	consumers.Wait()
+}
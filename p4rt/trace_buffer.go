@@ -0,0 +1,197 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"sync"
+)
+
+// traceBuffer is a growable ring buffer of WriteTraces sitting in front of
+// the write trace channel. It starts with room for soft traces, grows as
+// needed up to hard traces, and drops traces beyond that. Once drained it
+// shrinks back to soft to release burst memory.
+type traceBuffer struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	ring      []WriteTrace
+	head      int
+	count     int
+	soft      int
+	hard      int
+	highWater int
+	dropped   uint64
+	stopped   bool
+	stop      chan struct{} // closed by close, to stop the forwarder
+}
+
+func newTraceBuffer(soft, hard int) *traceBuffer {
+	b := &traceBuffer{stop: make(chan struct{})}
+	b.cond = sync.NewCond(&b.mu)
+	b.setCaps(soft, hard)
+	b.ring = make([]WriteTrace, b.soft)
+	return b
+}
+
+func (b *traceBuffer) setCaps(soft, hard int) {
+	if soft < 1 {
+		soft = 1
+	}
+	if hard < soft {
+		hard = soft
+	}
+	b.soft, b.hard = soft, hard
+}
+
+// push queues trace, returning false if the buffer is at its hard cap or
+// closed.
+func (b *traceBuffer) push(trace WriteTrace) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.count >= b.hard || b.stopped {
+		b.dropped++
+		return false
+	}
+	if b.count == len(b.ring) {
+		newLen := 2 * len(b.ring)
+		if newLen > b.hard {
+			newLen = b.hard
+		}
+		b.resize(newLen)
+	}
+	b.ring[(b.head+b.count)%len(b.ring)] = trace
+	b.count++
+	if b.count > b.highWater {
+		b.highWater = b.count
+	}
+	b.cond.Signal()
+	return true
+}
+
+// pop blocks until a trace is available and removes it. It returns false
+// once the buffer is closed.
+func (b *traceBuffer) pop() (WriteTrace, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.count == 0 && !b.stopped {
+		b.cond.Wait()
+	}
+	if b.stopped {
+		return WriteTrace{}, false
+	}
+	trace := b.ring[b.head]
+	b.ring[b.head] = WriteTrace{}
+	b.head = (b.head + 1) % len(b.ring)
+	b.count--
+	if b.count == 0 && len(b.ring) > b.soft {
+		b.ring = make([]WriteTrace, b.soft)
+		b.head = 0
+	}
+	return trace, true
+}
+
+// close stops the forwarder. Traces still buffered are dropped, and so are
+// traces pushed later.
+func (b *traceBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		return
+	}
+	b.stopped = true
+	close(b.stop)
+	b.dropped += uint64(b.count)
+	b.ring = nil
+	b.head, b.count = 0, 0
+	b.cond.Broadcast()
+}
+
+// resize must be called with b.mu held and newLen >= b.count.
+func (b *traceBuffer) resize(newLen int) {
+	ring := make([]WriteTrace, newLen)
+	for i := 0; i < b.count; i++ {
+		ring[i] = b.ring[(b.head+i)%len(b.ring)]
+	}
+	b.ring = ring
+	b.head = 0
+}
+
+// drop counts a trace the forwarder had nowhere to send.
+func (b *traceBuffer) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dropped++
+}
+
+func (b *traceBuffer) stats() (highWater int, dropped uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.highWater, b.dropped
+}
+
+// SetTraceBuffer puts a growable buffer between write completions and the
+// write trace channel, so bursts are absorbed instead of dropped. The buffer
+// holds soft traces without growing and up to hard traces in a burst;
+// traces beyond hard are dropped and counted in Stats. Calling it again
+// changes the caps of the same buffer. A trace waiting to be forwarded
+// goes to the channel SetWriteTraceChan last set, and is dropped if that
+// is nil. Shutdown stops the buffer; traces it still holds then are
+// dropped.
+func (c *p4rtClient) SetTraceBuffer(soft, hard int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.traceBuffer != nil {
+		c.traceBuffer.mu.Lock()
+		c.traceBuffer.setCaps(soft, hard)
+		c.traceBuffer.mu.Unlock()
+		return
+	}
+	buffer := newTraceBuffer(soft, hard)
+	c.traceBuffer = buffer
+	if c.writeTraceChanSet == nil {
+		c.writeTraceChanSet = make(chan struct{})
+	}
+	go func() {
+		for {
+			trace, ok := buffer.pop()
+			if !ok || !c.forwardTrace(buffer, trace) {
+				return
+			}
+		}
+	}()
+}
+
+// forwardTrace sends trace from buffer to the write trace channel, following
+// SetWriteTraceChan if the channel is replaced while the send blocks. A trace
+// is dropped, and counted, if the channel is nil by then. It returns false
+// if buffer was closed.
+func (c *p4rtClient) forwardTrace(buffer *traceBuffer, trace WriteTrace) bool {
+	for {
+		c.mu.RLock()
+		traceChan := c.writeTraceChan
+		set := c.writeTraceChanSet
+		c.mu.RUnlock()
+		if traceChan == nil {
+			buffer.drop()
+			return true
+		}
+		select {
+		case traceChan <- trace:
+			return true
+		case <-set:
+		case <-buffer.stop:
+			return false
+		}
+	}
+}
+
+// closeTraceBuffer stops the trace buffer's forwarder, if there is one.
+func (c *p4rtClient) closeTraceBuffer() {
+	c.mu.RLock()
+	buffer := c.traceBuffer
+	c.mu.RUnlock()
+	if buffer != nil {
+		buffer.close()
+	}
+}
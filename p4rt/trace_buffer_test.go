@@ -0,0 +1,95 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTraceBufferGrowsAndDrops(t *testing.T) {
+	b := newTraceBuffer(2, 4)
+	for i := 0; i < 5; i++ {
+		if ok := b.push(WriteTrace{BatchSize: i}); ok != (i < 4) {
+			t.Errorf("push %d = %v, want %v", i, ok, i < 4)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if trace, ok := b.pop(); !ok || trace.BatchSize != i {
+			t.Errorf("pop = %d, %v, want %d, true", trace.BatchSize, ok, i)
+		}
+	}
+	if highWater, dropped := b.stats(); highWater != 4 || dropped != 1 {
+		t.Errorf("high water %d and %d dropped, want 4 and 1", highWater, dropped)
+	}
+	if len(b.ring) != 2 {
+		t.Errorf("drained buffer holds %d slots, want it back to 2", len(b.ring))
+	}
+}
+
+func TestTraceBufferClose(t *testing.T) {
+	b := newTraceBuffer(1, 2)
+	b.push(WriteTrace{})
+	popped := make(chan bool)
+	go func() {
+		b.pop()
+		// Blocks until close
+		_, ok := b.pop()
+		popped <- ok
+	}()
+	time.Sleep(10 * time.Millisecond)
+	b.close()
+	select {
+	case ok := <-popped:
+		if ok {
+			t.Error("pop returned a trace after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pop still blocked after close")
+	}
+	if b.push(WriteTrace{}) {
+		t.Error("push succeeded after close")
+	}
+	if _, dropped := b.stats(); dropped != 1 {
+		t.Errorf("%d dropped, want the push after close", dropped)
+	}
+}
+
+// TestTraceBufferFollowsTraceChan replaces the write trace channel while
+// the forwarder is blocked on one nobody reads, first with another channel
+// and then with nil.
+func TestTraceBufferFollowsTraceChan(t *testing.T) {
+	c, _ := newTestClient(t, 1, 1)
+	c.SetTraceBuffer(4, 8)
+	unread := make(chan WriteTrace)
+	traces := make(chan WriteTrace, 1)
+	receive := func() {
+		t.Helper()
+		select {
+		case <-traces:
+		case <-time.After(time.Second):
+			t.Fatal("no trace on the new channel")
+		}
+	}
+
+	c.SetWriteTraceChan(unread)
+	<-c.Write(insertRequest(c, 1))
+	c.SetWriteTraceChan(traces)
+	receive()
+
+	c.SetWriteTraceChan(unread)
+	<-c.Write(insertRequest(c, 1))
+	c.SetWriteTraceChan(nil)
+	deadline := time.Now().Add(time.Second)
+	for c.Stats().TracesDropped != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d traces dropped, want the one stuck when the channel was cleared", c.Stats().TracesDropped)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.SetWriteTraceChan(traces)
+	<-c.Write(insertRequest(c, 1))
+	receive()
+}
@@ -0,0 +1,306 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TraceFormat is the file format of a TraceExporter.
+type TraceFormat int
+
+const (
+	// TraceCSV writes one row per trace, under a header row.
+	TraceCSV TraceFormat = iota
+	// TraceJSON writes one JSON object per line.
+	TraceJSON
+)
+
+// ParseTraceFormat returns the TraceFormat named "csv" or "json".
+func ParseTraceFormat(name string) (TraceFormat, error) {
+	switch name {
+	case "csv":
+		return TraceCSV, nil
+	case "json":
+		return TraceJSON, nil
+	}
+	return 0, fmt.Errorf("unknown trace format %q; use csv or json", name)
+}
+
+// traceRecord is the exported form of a WriteTrace. Times are Unix
+// nanoseconds, zero if unset, and durations nanoseconds.
+type traceRecord struct {
+	Start          int64  `json:"start"`
+	Enqueued       int64  `json:"enqueued"`
+	Dequeued       int64  `json:"dequeued"`
+	End            int64  `json:"end"`
+	Duration       int64  `json:"duration_ns"`
+	QueueDelay     int64  `json:"queue_delay_ns"`
+	DispatchDelay  int64  `json:"dispatch_delay_ns"`
+	RPCTime        int64  `json:"rpc_ns"`
+	WireTime       int64  `json:"wire_time_ns"`
+	QueueDepth     int    `json:"queue_depth"`
+	BatchSize      int    `json:"batch_size"`
+	Inserts        int    `json:"inserts"`
+	Modifies       int    `json:"modifies"`
+	Deletes        int    `json:"deletes"`
+	SuccessCount   int    `json:"success_count"`
+	ErrorCount     int    `json:"error_count"`
+	DominantCode   uint32 `json:"dominant_code"`
+	Retries        int    `json:"retries"`
+	RetriedUpdates int    `json:"retried_updates"`
+	Pipeline       int    `json:"pipeline"`
+	Peer           string `json:"peer"`
+	Phase          string `json:"phase"`
+}
+
+var traceCSVHeader = []string{
+	"start", "enqueued", "dequeued", "end", "duration_ns", "queue_delay_ns", "dispatch_delay_ns",
+	"rpc_ns", "wire_time_ns", "queue_depth", "batch_size", "inserts", "modifies", "deletes",
+	"success_count", "error_count", "dominant_code", "retries", "retried_updates", "pipeline",
+	"peer", "phase",
+}
+
+func newTraceRecord(t *WriteTrace) traceRecord {
+	unixNanos := func(v time.Time) int64 {
+		if v.IsZero() {
+			return 0
+		}
+		return v.UnixNano()
+	}
+	between := func(from, to time.Time) int64 {
+		if from.IsZero() || to.IsZero() {
+			return 0
+		}
+		return int64(to.Sub(from))
+	}
+	return traceRecord{
+		Start:          unixNanos(t.Start),
+		Enqueued:       unixNanos(t.Enqueued),
+		Dequeued:       unixNanos(t.Dequeued),
+		End:            unixNanos(t.End),
+		Duration:       int64(t.Duration),
+		QueueDelay:     between(t.Enqueued, t.Dequeued),
+		DispatchDelay:  between(t.Dequeued, t.Start),
+		RPCTime:        between(t.Start, t.End),
+		WireTime:       int64(t.WireTime),
+		QueueDepth:     t.QueueDepth,
+		BatchSize:      t.BatchSize,
+		Inserts:        t.Inserts,
+		Modifies:       t.Modifies,
+		Deletes:        t.Deletes,
+		SuccessCount:   t.SuccessCount,
+		ErrorCount:     t.ErrorCount,
+		DominantCode:   uint32(t.DominantCode),
+		Retries:        t.Retries,
+		RetriedUpdates: t.RetriedUpdates,
+		Pipeline:       t.Pipeline,
+		Peer:           t.Peer,
+		Phase:          t.Phase,
+	}
+}
+
+func (r traceRecord) csvRow() []string {
+	i64 := func(v int64) string { return strconv.FormatInt(v, 10) }
+	return []string{
+		i64(r.Start), i64(r.Enqueued), i64(r.Dequeued), i64(r.End), i64(r.Duration), i64(r.QueueDelay),
+		i64(r.DispatchDelay), i64(r.RPCTime), i64(r.WireTime), strconv.Itoa(r.QueueDepth),
+		strconv.Itoa(r.BatchSize), strconv.Itoa(r.Inserts), strconv.Itoa(r.Modifies),
+		strconv.Itoa(r.Deletes), strconv.Itoa(r.SuccessCount), strconv.Itoa(r.ErrorCount),
+		strconv.FormatUint(uint64(r.DominantCode), 10), strconv.Itoa(r.Retries),
+		strconv.Itoa(r.RetriedUpdates), strconv.Itoa(r.Pipeline), r.Peer, r.Phase,
+	}
+}
+
+// TraceExporter writes WriteTraces to a file as CSV or JSON lines. Once the
+// file reaches its size limit it is rotated: path is renamed path.1, path.1
+// becomes path.2 and so on, the oldest beyond the kept count is removed,
+// and a new path is started. Every file starts with the run's metadata, if
+// any: "# key: value" comment lines above the CSV header, or a first JSON
+// line {"metadata": {...}}. It is safe for concurrent use.
+type TraceExporter struct {
+	path     string
+	format   TraceFormat
+	maxBytes int64
+	keep     int
+	metadata RunMetadata
+
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	csv     *csv.Writer
+	written int64
+	count   int
+	err     error
+}
+
+// countingWriter counts the bytes written through to the exporter's file.
+type countingWriter struct{ e *TraceExporter }
+
+func (cw countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.e.w.Write(b)
+	cw.e.written += int64(n)
+	return n, err
+}
+
+// NewTraceExporter creates, or truncates, the trace file at path, headed by
+// metadata. A file is rotated once it holds maxBytes, keeping keep rotated
+// files; maxBytes 0 never rotates.
+func NewTraceExporter(path string, format TraceFormat, maxBytes int64, keep int, metadata RunMetadata) (*TraceExporter, error) {
+	if format != TraceCSV && format != TraceJSON {
+		return nil, fmt.Errorf("invalid trace format %d", format)
+	}
+	if maxBytes < 0 || keep < 0 {
+		return nil, fmt.Errorf("invalid trace file size %d or rotated file count %d", maxBytes, keep)
+	}
+	e := &TraceExporter{path: path, format: format, maxBytes: maxBytes, keep: keep, metadata: metadata}
+	if err := e.open(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *TraceExporter) open() error {
+	file, err := os.Create(e.path)
+	if err != nil {
+		return err
+	}
+	e.file = file
+	e.w = bufio.NewWriter(file)
+	e.written = 0
+	if e.format == TraceCSV {
+		if err := e.metadata.WriteComments(countingWriter{e}); err != nil {
+			return err
+		}
+		e.csv = csv.NewWriter(countingWriter{e})
+		return e.csv.Write(traceCSVHeader)
+	}
+	if len(e.metadata) == 0 {
+		return nil
+	}
+	line, err := json.Marshal(struct {
+		Metadata RunMetadata `json:"metadata"`
+	}{e.metadata})
+	if err != nil {
+		return errors.Wrap(err, "error encoding run metadata")
+	}
+	_, err = countingWriter{e}.Write(append(line, '\n'))
+	return err
+}
+
+// Write appends trace. After the first error nothing more is written and
+// Write returns that error.
+func (e *TraceExporter) Write(trace WriteTrace) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	if e.maxBytes > 0 && e.written >= e.maxBytes {
+		if e.err = e.rotate(); e.err != nil {
+			return e.err
+		}
+	}
+	record := newTraceRecord(&trace)
+	if e.format == TraceCSV {
+		if e.err = e.csv.Write(record.csvRow()); e.err != nil {
+			return e.err
+		}
+		// Flush to the buffered writer, so that written is up to date
+		e.csv.Flush()
+		e.err = e.csv.Error()
+	} else {
+		line, err := json.Marshal(record)
+		if err != nil {
+			e.err = errors.Wrap(err, "error encoding write trace")
+			return e.err
+		}
+		_, e.err = countingWriter{e}.Write(append(line, '\n'))
+	}
+	if e.err == nil {
+		e.count++
+	}
+	return e.err
+}
+
+func (e *TraceExporter) rotate() error {
+	if err := e.closeFile(); err != nil {
+		return err
+	}
+	if e.keep == 0 {
+		return e.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", e.path, e.keep))
+	for i := e.keep - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", e.path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, fmt.Sprintf("%s.%d", e.path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.Rename(e.path, e.path+".1"); err != nil {
+		return err
+	}
+	return e.open()
+}
+
+func (e *TraceExporter) closeFile() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			e.file.Close()
+			return err
+		}
+	}
+	if err := e.w.Flush(); err != nil {
+		e.file.Close()
+		return err
+	}
+	return e.file.Close()
+}
+
+// Count returns the number of traces written.
+func (e *TraceExporter) Count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.count
+}
+
+// Err returns the error that stopped the export, if any.
+func (e *TraceExporter) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// Close flushes and closes the current file. It returns the first error
+// met while exporting or closing.
+func (e *TraceExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.file == nil {
+		return e.err
+	}
+	if err := e.closeFile(); err != nil && e.err == nil {
+		e.err = err
+	}
+	e.file = nil
+	if e.err == nil {
+		// Write after Close fails instead of writing to a closed file
+		e.err = fmt.Errorf("trace exporter is closed")
+		return nil
+	}
+	return e.err
+}
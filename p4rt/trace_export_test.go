@@ -0,0 +1,78 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTraceExporterMetadata checks that every file, rotated or not, starts
+// with the run metadata.
+func TestTraceExporterMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace_export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	metadata := RunMetadata{"switch": "tofino", "sha": "abc123"}
+	trace := WriteTrace{Start: time.Now(), Duration: time.Millisecond, BatchSize: 1, SuccessCount: 1}
+
+	for _, format := range []TraceFormat{TraceCSV, TraceJSON} {
+		path := filepath.Join(dir, "traces")
+		// Rotate after every trace
+		e, err := NewTraceExporter(path, format, 1, 2, metadata)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := e.Write(trace); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{path + ".1", path} {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+			if format == TraceCSV {
+				want := "# sha: abc123\n# switch: tofino\nstart,"
+				if !strings.HasPrefix(string(data), want) || len(lines) != 4 {
+					t.Errorf("CSV file %s is\n%s\nwant comments %q above the header and a row", file, data, want)
+				}
+				continue
+			}
+			var first struct {
+				Metadata RunMetadata `json:"metadata"`
+			}
+			if len(lines) != 2 {
+				t.Errorf("JSON file %s is\n%s\nwant the metadata and a trace", file, data)
+			} else if err := json.Unmarshal([]byte(lines[0]), &first); err != nil || first.Metadata.String() != metadata.String() {
+				t.Errorf("JSON file %s starts with %s, want metadata %v", file, lines[0], metadata)
+			}
+		}
+	}
+
+	// No metadata, no header lines
+	path := filepath.Join(dir, "plain")
+	e, err := NewTraceExporter(path, TraceJSON, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Write(trace)
+	e.Close()
+	if data, _ := ioutil.ReadFile(path); strings.Count(string(data), "\n") != 1 || strings.Contains(string(data), "metadata") {
+		t.Errorf("JSON file without metadata is\n%s", data)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Yi-Tseng/p4r-perf/p4rt/tracepb"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+const (
+	remoteTraceMinBackoff    = 500 * time.Millisecond
+	remoteTraceMaxBackoff    = 30 * time.Second
+	remoteTraceCloseWait     = 5 * time.Second
+	remoteTraceQueuedBatches = 4 // batches queued for the sender before the batcher backs up
+)
+
+// RemoteTraceSink streams WriteTraces to a collector implementing the
+// TraceCollector service of tracepb/trace_collector.proto. Traces are batched and
+// sent on one client stream, which is reopened with backoff if it fails.
+// A trace sink never blocks its caller: if the collector is unreachable or
+// slow, traces are dropped and counted in Dropped.
+type RemoteTraceSink struct {
+	conn    *grpc.ClientConn
+	source  string
+	batcher *traceBatcher
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	closed  int32  // accessed atomically
+	sent    uint64 // accessed atomically
+	dropped uint64 // accessed atomically
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// NewRemoteTraceSink connects to the collector at addr. source identifies
+// the traces of this run to the collector, for example the host name. A
+// batch is sent once batchSize traces have accumulated or maxDelay after
+// its first trace. The connection is made in the background, so an
+// unreachable collector is not an error here.
+func NewRemoteTraceSink(addr string, source string, batchSize int, maxDelay time.Duration) (*RemoteTraceSink, error) {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, errors.Wrapf(err, "error dialing trace collector %s", addr)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := make(chan []WriteTrace, remoteTraceQueuedBatches)
+	s := &RemoteTraceSink{
+		conn:    conn,
+		source:  source,
+		batcher: newTraceBatcher(batches, batchSize, maxDelay),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		s.batcher.run()
+		close(batches)
+	}()
+	go s.send(batches)
+	return s, nil
+}
+
+// Push queues trace for the collector, dropping it if the sink is backed up
+// or closed.
+func (s *RemoteTraceSink) Push(trace WriteTrace) {
+	if atomic.LoadInt32(&s.closed) != 0 || !s.batcher.push(trace) {
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// Consume pushes every trace received on traceChan until it is closed.
+func (s *RemoteTraceSink) Consume(traceChan <-chan WriteTrace) {
+	for trace := range traceChan {
+		s.Push(trace)
+	}
+}
+
+// Sent returns the number of traces handed to gRPC on an open stream.
+func (s *RemoteTraceSink) Sent() uint64 {
+	return atomic.LoadUint64(&s.sent)
+}
+
+// Dropped returns the number of traces discarded because the sink was backed
+// up or the collector could not be reached.
+func (s *RemoteTraceSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Err returns the most recent error talking to the collector, if any.
+func (s *RemoteTraceSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Close sends the traces still buffered and closes the stream, giving up
+// after a few seconds if the collector does not answer.
+func (s *RemoteTraceSink) Close() error {
+	if !atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		return nil
+	}
+	s.batcher.stop(nil)
+	select {
+	case <-s.done:
+	case <-time.After(remoteTraceCloseWait):
+		s.cancel()
+		<-s.done
+	}
+	s.cancel()
+	return s.conn.Close()
+}
+
+// send writes batches to the collector stream until batches is closed. A
+// batch that cannot be sent is dropped; while the collector is down, batches
+// are dropped without retrying until the backoff expires.
+func (s *RemoteTraceSink) send(batches <-chan []WriteTrace) {
+	defer close(s.done)
+	collector := tracepb.NewTraceCollectorClient(s.conn)
+	var stream tracepb.TraceCollector_StreamClient
+	var retryAt time.Time
+	backoff := remoteTraceMinBackoff
+
+	fail := func(err error, lost int) {
+		atomic.AddUint64(&s.dropped, uint64(lost))
+		s.mu.Lock()
+		s.lastErr = err
+		s.mu.Unlock()
+		stream = nil
+		retryAt = time.Now().Add(backoff)
+		if backoff *= 2; backoff > remoteTraceMaxBackoff {
+			backoff = remoteTraceMaxBackoff
+		}
+	}
+
+	for batch := range batches {
+		if stream == nil {
+			if time.Now().Before(retryAt) {
+				atomic.AddUint64(&s.dropped, uint64(len(batch)))
+				continue
+			}
+			var err error
+			stream, err = collector.Stream(s.ctx)
+			if err != nil {
+				fail(errors.Wrap(err, "error opening trace collector stream"), len(batch))
+				continue
+			}
+		}
+		if err := stream.Send(newTraceBatch(s.source, batch)); err != nil {
+			// The stream's status says why the send failed
+			if _, recvErr := stream.CloseAndRecv(); recvErr != nil {
+				err = recvErr
+			}
+			fail(errors.Wrap(err, "error sending traces to collector"), len(batch))
+			continue
+		}
+		atomic.AddUint64(&s.sent, uint64(len(batch)))
+		backoff = remoteTraceMinBackoff
+	}
+
+	if stream != nil {
+		if _, err := stream.CloseAndRecv(); err != nil {
+			s.mu.Lock()
+			s.lastErr = errors.Wrap(err, "error closing trace collector stream")
+			s.mu.Unlock()
+		}
+	}
+}
+
+// newTraceBatch converts traces to the TraceBatch message of
+// trace_collector.proto. Unset times are sent as zero.
+func newTraceBatch(source string, traces []WriteTrace) *tracepb.TraceBatch {
+	unixNanos := func(v time.Time) int64 {
+		if v.IsZero() {
+			return 0
+		}
+		return v.UnixNano()
+	}
+	batch := &tracepb.TraceBatch{Source: source, Traces: make([]*tracepb.WriteTrace, len(traces))}
+	for i := range traces {
+		t := &traces[i]
+		batch.Traces[i] = &tracepb.WriteTrace{
+			StartUnixNanos:     unixNanos(t.Start),
+			BatchSize:          uint32(t.BatchSize),
+			DurationNanos:      int64(t.Duration),
+			SuccessCount:       uint32(t.SuccessCount),
+			ErrorCount:         uint32(t.ErrorCount),
+			DominantCode:       uint32(t.DominantCode),
+			PhysicalWrites:     uint32(t.PhysicalWrites),
+			LogicalUpdates:     uint32(t.LogicalUpdates),
+			Peer:               t.Peer,
+			WireTimeNanos:      int64(t.WireTime),
+			Phase:              t.Phase,
+			ScheduledUnixNanos: unixNanos(t.Scheduled),
+			RequestedRate:      t.RequestedRate,
+			AchievedRate:       t.AchievedRate,
+			Pipeline:           uint32(t.Pipeline),
+			PipelineRate:       t.PipelineRate,
+			AggregateRate:      t.AggregateRate,
+			Retries:            uint32(t.Retries),
+			RetriedUpdates:     uint32(t.RetriedUpdates),
+			EnqueuedUnixNanos:  unixNanos(t.Enqueued),
+			DequeuedUnixNanos:  unixNanos(t.Dequeued),
+			EndUnixNanos:       unixNanos(t.End),
+			QueueDepth:         uint32(t.QueueDepth),
+			Inserts:            uint32(t.Inserts),
+			Modifies:           uint32(t.Modifies),
+			Deletes:            uint32(t.Deletes),
+		}
+	}
+	return batch
+}
@@ -0,0 +1,94 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Yi-Tseng/p4r-perf/p4rt/tracepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// fakeCollector is a TraceCollector that keeps every batch it receives.
+type fakeCollector struct {
+	tracepb.UnimplementedTraceCollectorServer
+
+	mu      sync.Mutex
+	batches []*tracepb.TraceBatch
+}
+
+func (c *fakeCollector) Stream(stream tracepb.TraceCollector_StreamServer) error {
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&tracepb.Ack{})
+		}
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.batches = append(c.batches, batch)
+		c.mu.Unlock()
+	}
+}
+
+func TestRemoteTraceSink(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	collector := &fakeCollector{}
+	tracepb.RegisterTraceCollectorServer(server, collector)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	sink, err := NewRemoteTraceSink(lis.Addr().String(), "host1", 2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(0, 1000)
+	sink.Push(WriteTrace{Start: start, BatchSize: 10, Duration: time.Millisecond, DominantCode: codes.AlreadyExists, Phase: "warmup"})
+	sink.Push(WriteTrace{Start: start, BatchSize: 20, RequestedRate: 500})
+	sink.Push(WriteTrace{BatchSize: 30})
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if sent, dropped := sink.Sent(), sink.Dropped(); sent != 3 || dropped != 0 {
+		t.Errorf("sink sent %d traces and dropped %d, want 3 and 0", sent, dropped)
+	}
+
+	var traces []*tracepb.WriteTrace
+	collector.mu.Lock()
+	for _, batch := range collector.batches {
+		if batch.GetSource() != "host1" {
+			t.Errorf("batch from %q, want host1", batch.GetSource())
+		}
+		traces = append(traces, batch.GetTraces()...)
+	}
+	collector.mu.Unlock()
+	if len(traces) != 3 {
+		t.Fatalf("collector got %d traces, want 3", len(traces))
+	}
+	first := traces[0]
+	if first.GetStartUnixNanos() != 1000 || first.GetBatchSize() != 10 || first.GetDurationNanos() != int64(time.Millisecond) ||
+		first.GetDominantCode() != uint32(codes.AlreadyExists) || first.GetPhase() != "warmup" {
+		t.Errorf("first trace arrived as %v", first)
+	}
+	if traces[1].GetRequestedRate() != 500 {
+		t.Errorf("second trace has requested rate %v, want 500", traces[1].GetRequestedRate())
+	}
+	if traces[2].GetStartUnixNanos() != 0 {
+		t.Errorf("unset start arrived as %d, want 0", traces[2].GetStartUnixNanos())
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const createTraceTable = `CREATE TABLE IF NOT EXISTS write_traces (
+	timestamp_us INTEGER NOT NULL,
+	batch_size   INTEGER NOT NULL,
+	duration_us  INTEGER NOT NULL,
+	error_code   INTEGER NOT NULL, -- WriteTrace.DominantCode
+	error_count  INTEGER NOT NULL,
+	retries      INTEGER NOT NULL DEFAULT 0 -- WriteTrace.Retries
+)`
+
+// Tables created before the retries column get it added
+const addRetriesColumn = `ALTER TABLE write_traces ADD COLUMN retries INTEGER NOT NULL DEFAULT 0`
+
+const insertTrace = `INSERT INTO write_traces
+	(timestamp_us, batch_size, duration_us, error_code, error_count, retries)
+	VALUES (?, ?, ?, ?, ?, ?)`
+
+// SQLiteTraceWriter stores WriteTraces as rows of the write_traces table.
+// Rows are buffered and inserted batchSize at a time in one transaction.
+//
+// The caller opens the database with the SQLite driver of its choice and
+// keeps ownership of it; Close only flushes buffered rows.
+type SQLiteTraceWriter struct {
+	db        *sql.DB
+	batchSize int
+
+	mu      sync.Mutex
+	pending []WriteTrace
+}
+
+func NewSQLiteTraceWriter(db *sql.DB, batchSize int) (*SQLiteTraceWriter, error) {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if _, err := db.Exec(createTraceTable); err != nil {
+		return nil, errors.Wrap(err, "error creating write_traces table")
+	}
+	hasRetries, err := hasTraceColumn(db, "retries")
+	if err != nil {
+		return nil, err
+	}
+	if !hasRetries {
+		if _, err := db.Exec(addRetriesColumn); err != nil {
+			return nil, errors.Wrap(err, "error adding retries column to write_traces")
+		}
+	}
+	return &SQLiteTraceWriter{
+		db:        db,
+		batchSize: batchSize,
+		pending:   make([]WriteTrace, 0, batchSize),
+	}, nil
+}
+
+func hasTraceColumn(db *sql.DB, name string) (bool, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('write_traces')`)
+	if err != nil {
+		return false, errors.Wrap(err, "error reading write_traces columns")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return false, errors.Wrap(err, "error reading write_traces columns")
+		}
+		if column == name {
+			return true, nil
+		}
+	}
+	return false, errors.Wrap(rows.Err(), "error reading write_traces columns")
+}
+
+// Write buffers trace, inserting the buffered rows once a batch is full.
+func (w *SQLiteTraceWriter) Write(trace WriteTrace) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, trace)
+	if len(w.pending) < w.batchSize {
+		return nil
+	}
+	return w.flushLocked()
+}
+
+// Consume writes every trace received on traceChan until it is closed.
+func (w *SQLiteTraceWriter) Consume(traceChan <-chan WriteTrace) error {
+	for trace := range traceChan {
+		if err := w.Write(trace); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// Flush inserts any buffered rows.
+func (w *SQLiteTraceWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flushLocked()
+}
+
+// Close flushes buffered rows. The database itself is left open.
+func (w *SQLiteTraceWriter) Close() error {
+	return w.Flush()
+}
+
+func (w *SQLiteTraceWriter) flushLocked() (err error) {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	tx, err := w.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "error starting trace transaction")
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	stmt, err := tx.Prepare(insertTrace)
+	if err != nil {
+		return errors.Wrap(err, "error preparing trace insert")
+	}
+	defer stmt.Close()
+
+	for _, trace := range w.pending {
+		_, err = stmt.Exec(trace.Start.UnixNano()/1000, trace.BatchSize,
+			trace.Duration.Microseconds(), int(trace.DominantCode), trace.ErrorCount, trace.Retries)
+		if err != nil {
+			return errors.Wrap(err, "error inserting trace")
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing traces")
+	}
+	w.pending = w.pending[:0]
+	return nil
+}
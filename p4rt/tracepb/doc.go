@@ -0,0 +1,9 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+// Package tracepb holds the generated messages and client of the
+// TraceCollector service that RemoteTraceSink streams write traces to.
+package tracepb
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. trace_collector.proto
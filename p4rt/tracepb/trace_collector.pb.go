@@ -0,0 +1,671 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+// Wire format used by RemoteTraceSink (p4rt/trace_remote.go) to stream
+// write traces to a central collector. After editing this file, regenerate
+// trace_collector.pb.go with go generate.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.25.0
+// 	protoc        (unknown)
+// source: trace_collector.proto
+
+package tracepb
+
+import (
+	context "context"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// This is a compile-time assertion that a sufficiently up-to-date version
+// of the legacy proto package is being used.
+const _ = proto.ProtoPackageIsVersion4
+
+type WriteTrace struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	StartUnixNanos     int64   `protobuf:"varint,1,opt,name=start_unix_nanos,json=startUnixNanos,proto3" json:"start_unix_nanos,omitempty"`
+	BatchSize          uint32  `protobuf:"varint,2,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
+	DurationNanos      int64   `protobuf:"varint,3,opt,name=duration_nanos,json=durationNanos,proto3" json:"duration_nanos,omitempty"`
+	SuccessCount       uint32  `protobuf:"varint,4,opt,name=success_count,json=successCount,proto3" json:"success_count,omitempty"`
+	ErrorCount         uint32  `protobuf:"varint,5,opt,name=error_count,json=errorCount,proto3" json:"error_count,omitempty"`
+	DominantCode       uint32  `protobuf:"varint,6,opt,name=dominant_code,json=dominantCode,proto3" json:"dominant_code,omitempty"` // google.rpc.Code
+	PhysicalWrites     uint32  `protobuf:"varint,7,opt,name=physical_writes,json=physicalWrites,proto3" json:"physical_writes,omitempty"`
+	LogicalUpdates     uint32  `protobuf:"varint,8,opt,name=logical_updates,json=logicalUpdates,proto3" json:"logical_updates,omitempty"`
+	Peer               string  `protobuf:"bytes,9,opt,name=peer,proto3" json:"peer,omitempty"`
+	WireTimeNanos      int64   `protobuf:"varint,10,opt,name=wire_time_nanos,json=wireTimeNanos,proto3" json:"wire_time_nanos,omitempty"`
+	Phase              string  `protobuf:"bytes,11,opt,name=phase,proto3" json:"phase,omitempty"`
+	ScheduledUnixNanos int64   `protobuf:"varint,12,opt,name=scheduled_unix_nanos,json=scheduledUnixNanos,proto3" json:"scheduled_unix_nanos,omitempty"` // unset unless the write was paced
+	RequestedRate      float64 `protobuf:"fixed64,13,opt,name=requested_rate,json=requestedRate,proto3" json:"requested_rate,omitempty"`                 // updates per second
+	AchievedRate       float64 `protobuf:"fixed64,14,opt,name=achieved_rate,json=achievedRate,proto3" json:"achieved_rate,omitempty"`
+	Pipeline           uint32  `protobuf:"varint,15,opt,name=pipeline,proto3" json:"pipeline,omitempty"`
+	PipelineRate       float64 `protobuf:"fixed64,16,opt,name=pipeline_rate,json=pipelineRate,proto3" json:"pipeline_rate,omitempty"` // updates per second
+	AggregateRate      float64 `protobuf:"fixed64,17,opt,name=aggregate_rate,json=aggregateRate,proto3" json:"aggregate_rate,omitempty"`
+	Retries            uint32  `protobuf:"varint,18,opt,name=retries,proto3" json:"retries,omitempty"`
+	RetriedUpdates     uint32  `protobuf:"varint,19,opt,name=retried_updates,json=retriedUpdates,proto3" json:"retried_updates,omitempty"`
+	EnqueuedUnixNanos  int64   `protobuf:"varint,20,opt,name=enqueued_unix_nanos,json=enqueuedUnixNanos,proto3" json:"enqueued_unix_nanos,omitempty"`
+	DequeuedUnixNanos  int64   `protobuf:"varint,21,opt,name=dequeued_unix_nanos,json=dequeuedUnixNanos,proto3" json:"dequeued_unix_nanos,omitempty"`
+	EndUnixNanos       int64   `protobuf:"varint,22,opt,name=end_unix_nanos,json=endUnixNanos,proto3" json:"end_unix_nanos,omitempty"`
+	QueueDepth         uint32  `protobuf:"varint,23,opt,name=queue_depth,json=queueDepth,proto3" json:"queue_depth,omitempty"`
+	Inserts            uint32  `protobuf:"varint,24,opt,name=inserts,proto3" json:"inserts,omitempty"`
+	Modifies           uint32  `protobuf:"varint,25,opt,name=modifies,proto3" json:"modifies,omitempty"`
+	Deletes            uint32  `protobuf:"varint,26,opt,name=deletes,proto3" json:"deletes,omitempty"`
+}
+
+func (x *WriteTrace) Reset() {
+	*x = WriteTrace{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_trace_collector_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WriteTrace) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteTrace) ProtoMessage() {}
+
+func (x *WriteTrace) ProtoReflect() protoreflect.Message {
+	mi := &file_trace_collector_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteTrace.ProtoReflect.Descriptor instead.
+func (*WriteTrace) Descriptor() ([]byte, []int) {
+	return file_trace_collector_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *WriteTrace) GetStartUnixNanos() int64 {
+	if x != nil {
+		return x.StartUnixNanos
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetBatchSize() uint32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetDurationNanos() int64 {
+	if x != nil {
+		return x.DurationNanos
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetSuccessCount() uint32 {
+	if x != nil {
+		return x.SuccessCount
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetErrorCount() uint32 {
+	if x != nil {
+		return x.ErrorCount
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetDominantCode() uint32 {
+	if x != nil {
+		return x.DominantCode
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetPhysicalWrites() uint32 {
+	if x != nil {
+		return x.PhysicalWrites
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetLogicalUpdates() uint32 {
+	if x != nil {
+		return x.LogicalUpdates
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *WriteTrace) GetWireTimeNanos() int64 {
+	if x != nil {
+		return x.WireTimeNanos
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetPhase() string {
+	if x != nil {
+		return x.Phase
+	}
+	return ""
+}
+
+func (x *WriteTrace) GetScheduledUnixNanos() int64 {
+	if x != nil {
+		return x.ScheduledUnixNanos
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetRequestedRate() float64 {
+	if x != nil {
+		return x.RequestedRate
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetAchievedRate() float64 {
+	if x != nil {
+		return x.AchievedRate
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetPipeline() uint32 {
+	if x != nil {
+		return x.Pipeline
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetPipelineRate() float64 {
+	if x != nil {
+		return x.PipelineRate
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetAggregateRate() float64 {
+	if x != nil {
+		return x.AggregateRate
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetRetries() uint32 {
+	if x != nil {
+		return x.Retries
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetRetriedUpdates() uint32 {
+	if x != nil {
+		return x.RetriedUpdates
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetEnqueuedUnixNanos() int64 {
+	if x != nil {
+		return x.EnqueuedUnixNanos
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetDequeuedUnixNanos() int64 {
+	if x != nil {
+		return x.DequeuedUnixNanos
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetEndUnixNanos() int64 {
+	if x != nil {
+		return x.EndUnixNanos
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetQueueDepth() uint32 {
+	if x != nil {
+		return x.QueueDepth
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetInserts() uint32 {
+	if x != nil {
+		return x.Inserts
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetModifies() uint32 {
+	if x != nil {
+		return x.Modifies
+	}
+	return 0
+}
+
+func (x *WriteTrace) GetDeletes() uint32 {
+	if x != nil {
+		return x.Deletes
+	}
+	return 0
+}
+
+type TraceBatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Identifies the machine or run that produced the traces.
+	Source string        `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Traces []*WriteTrace `protobuf:"bytes,2,rep,name=traces,proto3" json:"traces,omitempty"`
+}
+
+func (x *TraceBatch) Reset() {
+	*x = TraceBatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_trace_collector_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TraceBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TraceBatch) ProtoMessage() {}
+
+func (x *TraceBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_trace_collector_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TraceBatch.ProtoReflect.Descriptor instead.
+func (*TraceBatch) Descriptor() ([]byte, []int) {
+	return file_trace_collector_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TraceBatch) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *TraceBatch) GetTraces() []*WriteTrace {
+	if x != nil {
+		return x.Traces
+	}
+	return nil
+}
+
+type Ack struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *Ack) Reset() {
+	*x = Ack{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_trace_collector_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Ack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ack) ProtoMessage() {}
+
+func (x *Ack) ProtoReflect() protoreflect.Message {
+	mi := &file_trace_collector_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ack.ProtoReflect.Descriptor instead.
+func (*Ack) Descriptor() ([]byte, []int) {
+	return file_trace_collector_proto_rawDescGZIP(), []int{2}
+}
+
+var File_trace_collector_proto protoreflect.FileDescriptor
+
+var file_trace_collector_proto_rawDesc = []byte{
+	0x0a, 0x15, 0x74, 0x72, 0x61, 0x63, 0x65, 0x5f, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x6f,
+	0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x11, 0x70, 0x34, 0x72, 0x74, 0x70, 0x65, 0x72,
+	0x66, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x22, 0xab, 0x07, 0x0a, 0x0a, 0x57,
+	0x72, 0x69, 0x74, 0x65, 0x54, 0x72, 0x61, 0x63, 0x65, 0x12, 0x28, 0x0a, 0x10, 0x73, 0x74, 0x61,
+	0x72, 0x74, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x0e, 0x73, 0x74, 0x61, 0x72, 0x74, 0x55, 0x6e, 0x69, 0x78, 0x4e, 0x61,
+	0x6e, 0x6f, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x62, 0x61, 0x74, 0x63, 0x68, 0x5f, 0x73, 0x69, 0x7a,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x62, 0x61, 0x74, 0x63, 0x68, 0x53, 0x69,
+	0x7a, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6e,
+	0x61, 0x6e, 0x6f, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x64, 0x75, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x75, 0x63,
+	0x63, 0x65, 0x73, 0x73, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x0c, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x1f,
+	0x0a, 0x0b, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x0a, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12,
+	0x23, 0x0a, 0x0d, 0x64, 0x6f, 0x6d, 0x69, 0x6e, 0x61, 0x6e, 0x74, 0x5f, 0x63, 0x6f, 0x64, 0x65,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x64, 0x6f, 0x6d, 0x69, 0x6e, 0x61, 0x6e, 0x74,
+	0x43, 0x6f, 0x64, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x70, 0x68, 0x79, 0x73, 0x69, 0x63, 0x61, 0x6c,
+	0x5f, 0x77, 0x72, 0x69, 0x74, 0x65, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x70,
+	0x68, 0x79, 0x73, 0x69, 0x63, 0x61, 0x6c, 0x57, 0x72, 0x69, 0x74, 0x65, 0x73, 0x12, 0x27, 0x0a,
+	0x0f, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x5f, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x73,
+	0x18, 0x08, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x55,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x09,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x26, 0x0a, 0x0f, 0x77, 0x69,
+	0x72, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x0a, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x0d, 0x77, 0x69, 0x72, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x4e, 0x61, 0x6e,
+	0x6f, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x68, 0x61, 0x73, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x70, 0x68, 0x61, 0x73, 0x65, 0x12, 0x30, 0x0a, 0x14, 0x73, 0x63, 0x68, 0x65,
+	0x64, 0x75, 0x6c, 0x65, 0x64, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73,
+	0x18, 0x0c, 0x20, 0x01, 0x28, 0x03, 0x52, 0x12, 0x73, 0x63, 0x68, 0x65, 0x64, 0x75, 0x6c, 0x65,
+	0x64, 0x55, 0x6e, 0x69, 0x78, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x72, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64, 0x5f, 0x72, 0x61, 0x74, 0x65, 0x18, 0x0d, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x0d, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64, 0x52, 0x61, 0x74,
+	0x65, 0x12, 0x23, 0x0a, 0x0d, 0x61, 0x63, 0x68, 0x69, 0x65, 0x76, 0x65, 0x64, 0x5f, 0x72, 0x61,
+	0x74, 0x65, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0c, 0x61, 0x63, 0x68, 0x69, 0x65, 0x76,
+	0x65, 0x64, 0x52, 0x61, 0x74, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x69, 0x70, 0x65, 0x6c, 0x69,
+	0x6e, 0x65, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x70, 0x69, 0x70, 0x65, 0x6c, 0x69,
+	0x6e, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x70, 0x69, 0x70, 0x65, 0x6c, 0x69, 0x6e, 0x65, 0x5f, 0x72,
+	0x61, 0x74, 0x65, 0x18, 0x10, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0c, 0x70, 0x69, 0x70, 0x65, 0x6c,
+	0x69, 0x6e, 0x65, 0x52, 0x61, 0x74, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x61, 0x67, 0x67, 0x72, 0x65,
+	0x67, 0x61, 0x74, 0x65, 0x5f, 0x72, 0x61, 0x74, 0x65, 0x18, 0x11, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x0d, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x65, 0x52, 0x61, 0x74, 0x65, 0x12, 0x18,
+	0x0a, 0x07, 0x72, 0x65, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x12, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x07, 0x72, 0x65, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x27, 0x0a, 0x0f, 0x72, 0x65, 0x74, 0x72,
+	0x69, 0x65, 0x64, 0x5f, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x73, 0x18, 0x13, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x0e, 0x72, 0x65, 0x74, 0x72, 0x69, 0x65, 0x64, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65,
+	0x73, 0x12, 0x2e, 0x0a, 0x13, 0x65, 0x6e, 0x71, 0x75, 0x65, 0x75, 0x65, 0x64, 0x5f, 0x75, 0x6e,
+	0x69, 0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x14, 0x20, 0x01, 0x28, 0x03, 0x52, 0x11,
+	0x65, 0x6e, 0x71, 0x75, 0x65, 0x75, 0x65, 0x64, 0x55, 0x6e, 0x69, 0x78, 0x4e, 0x61, 0x6e, 0x6f,
+	0x73, 0x12, 0x2e, 0x0a, 0x13, 0x64, 0x65, 0x71, 0x75, 0x65, 0x75, 0x65, 0x64, 0x5f, 0x75, 0x6e,
+	0x69, 0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x15, 0x20, 0x01, 0x28, 0x03, 0x52, 0x11,
+	0x64, 0x65, 0x71, 0x75, 0x65, 0x75, 0x65, 0x64, 0x55, 0x6e, 0x69, 0x78, 0x4e, 0x61, 0x6e, 0x6f,
+	0x73, 0x12, 0x24, 0x0a, 0x0e, 0x65, 0x6e, 0x64, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x6e, 0x61,
+	0x6e, 0x6f, 0x73, 0x18, 0x16, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x65, 0x6e, 0x64, 0x55, 0x6e,
+	0x69, 0x78, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x71, 0x75, 0x65, 0x75, 0x65,
+	0x5f, 0x64, 0x65, 0x70, 0x74, 0x68, 0x18, 0x17, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x71, 0x75,
+	0x65, 0x75, 0x65, 0x44, 0x65, 0x70, 0x74, 0x68, 0x12, 0x18, 0x0a, 0x07, 0x69, 0x6e, 0x73, 0x65,
+	0x72, 0x74, 0x73, 0x18, 0x18, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x69, 0x6e, 0x73, 0x65, 0x72,
+	0x74, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x73, 0x18, 0x19,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x73, 0x12, 0x18,
+	0x0a, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x73, 0x18, 0x1a, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x07, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x73, 0x22, 0x5b, 0x0a, 0x0a, 0x54, 0x72, 0x61, 0x63,
+	0x65, 0x42, 0x61, 0x74, 0x63, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x35,
+	0x0a, 0x06, 0x74, 0x72, 0x61, 0x63, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d,
+	0x2e, 0x70, 0x34, 0x72, 0x74, 0x70, 0x65, 0x72, 0x66, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x2e,
+	0x76, 0x31, 0x2e, 0x57, 0x72, 0x69, 0x74, 0x65, 0x54, 0x72, 0x61, 0x63, 0x65, 0x52, 0x06, 0x74,
+	0x72, 0x61, 0x63, 0x65, 0x73, 0x22, 0x05, 0x0a, 0x03, 0x41, 0x63, 0x6b, 0x32, 0x53, 0x0a, 0x0e,
+	0x54, 0x72, 0x61, 0x63, 0x65, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12, 0x41,
+	0x0a, 0x06, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x1d, 0x2e, 0x70, 0x34, 0x72, 0x74, 0x70,
+	0x65, 0x72, 0x66, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61,
+	0x63, 0x65, 0x42, 0x61, 0x74, 0x63, 0x68, 0x1a, 0x16, 0x2e, 0x70, 0x34, 0x72, 0x74, 0x70, 0x65,
+	0x72, 0x66, 0x2e, 0x74, 0x72, 0x61, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x63, 0x6b, 0x28,
+	0x01, 0x42, 0x2b, 0x5a, 0x29, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x59, 0x69, 0x2d, 0x54, 0x73, 0x65, 0x6e, 0x67, 0x2f, 0x70, 0x34, 0x72, 0x2d, 0x70, 0x65, 0x72,
+	0x66, 0x2f, 0x70, 0x34, 0x72, 0x74, 0x2f, 0x74, 0x72, 0x61, 0x63, 0x65, 0x70, 0x62, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_trace_collector_proto_rawDescOnce sync.Once
+	file_trace_collector_proto_rawDescData = file_trace_collector_proto_rawDesc
+)
+
+func file_trace_collector_proto_rawDescGZIP() []byte {
+	file_trace_collector_proto_rawDescOnce.Do(func() {
+		file_trace_collector_proto_rawDescData = protoimpl.X.CompressGZIP(file_trace_collector_proto_rawDescData)
+	})
+	return file_trace_collector_proto_rawDescData
+}
+
+var file_trace_collector_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_trace_collector_proto_goTypes = []interface{}{
+	(*WriteTrace)(nil), // 0: p4rtperf.trace.v1.WriteTrace
+	(*TraceBatch)(nil), // 1: p4rtperf.trace.v1.TraceBatch
+	(*Ack)(nil),        // 2: p4rtperf.trace.v1.Ack
+}
+var file_trace_collector_proto_depIdxs = []int32{
+	0, // 0: p4rtperf.trace.v1.TraceBatch.traces:type_name -> p4rtperf.trace.v1.WriteTrace
+	1, // 1: p4rtperf.trace.v1.TraceCollector.Stream:input_type -> p4rtperf.trace.v1.TraceBatch
+	2, // 2: p4rtperf.trace.v1.TraceCollector.Stream:output_type -> p4rtperf.trace.v1.Ack
+	2, // [2:3] is the sub-list for method output_type
+	1, // [1:2] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_trace_collector_proto_init() }
+func file_trace_collector_proto_init() {
+	if File_trace_collector_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_trace_collector_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WriteTrace); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_trace_collector_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TraceBatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_trace_collector_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Ack); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_trace_collector_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_trace_collector_proto_goTypes,
+		DependencyIndexes: file_trace_collector_proto_depIdxs,
+		MessageInfos:      file_trace_collector_proto_msgTypes,
+	}.Build()
+	File_trace_collector_proto = out.File
+	file_trace_collector_proto_rawDesc = nil
+	file_trace_collector_proto_goTypes = nil
+	file_trace_collector_proto_depIdxs = nil
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// TraceCollectorClient is the client API for TraceCollector service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type TraceCollectorClient interface {
+	// Stream carries every batch of one client connection. The collector
+	// answers once, when the client closes the stream.
+	Stream(ctx context.Context, opts ...grpc.CallOption) (TraceCollector_StreamClient, error)
+}
+
+type traceCollectorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTraceCollectorClient(cc grpc.ClientConnInterface) TraceCollectorClient {
+	return &traceCollectorClient{cc}
+}
+
+func (c *traceCollectorClient) Stream(ctx context.Context, opts ...grpc.CallOption) (TraceCollector_StreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TraceCollector_serviceDesc.Streams[0], "/p4rtperf.trace.v1.TraceCollector/Stream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &traceCollectorStreamClient{stream}
+	return x, nil
+}
+
+type TraceCollector_StreamClient interface {
+	Send(*TraceBatch) error
+	CloseAndRecv() (*Ack, error)
+	grpc.ClientStream
+}
+
+type traceCollectorStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *traceCollectorStreamClient) Send(m *TraceBatch) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *traceCollectorStreamClient) CloseAndRecv() (*Ack, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Ack)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TraceCollectorServer is the server API for TraceCollector service.
+type TraceCollectorServer interface {
+	// Stream carries every batch of one client connection. The collector
+	// answers once, when the client closes the stream.
+	Stream(TraceCollector_StreamServer) error
+}
+
+// UnimplementedTraceCollectorServer can be embedded to have forward compatible implementations.
+type UnimplementedTraceCollectorServer struct {
+}
+
+func (*UnimplementedTraceCollectorServer) Stream(TraceCollector_StreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method Stream not implemented")
+}
+
+func RegisterTraceCollectorServer(s *grpc.Server, srv TraceCollectorServer) {
+	s.RegisterService(&_TraceCollector_serviceDesc, srv)
+}
+
+func _TraceCollector_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TraceCollectorServer).Stream(&traceCollectorStreamServer{stream})
+}
+
+type TraceCollector_StreamServer interface {
+	SendAndClose(*Ack) error
+	Recv() (*TraceBatch, error)
+	grpc.ServerStream
+}
+
+type traceCollectorStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *traceCollectorStreamServer) SendAndClose(m *Ack) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *traceCollectorStreamServer) Recv() (*TraceBatch, error) {
+	m := new(TraceBatch)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _TraceCollector_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "p4rtperf.trace.v1.TraceCollector",
+	HandlerType: (*TraceCollectorServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Stream",
+			Handler:       _TraceCollector_Stream_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "trace_collector.proto",
+}
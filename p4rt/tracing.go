@@ -0,0 +1,55 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+const tracerName = "github.com/Yi-Tseng/p4r-perf/p4rt"
+
+// SetTracerProvider enables OpenTelemetry spans around P4Runtime RPCs.
+// Passing nil disables tracing again.
+func (c *p4rtClient) SetTracerProvider(tp trace.TracerProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tp == nil {
+		c.tracer = nil
+		return
+	}
+	c.tracer = tp.Tracer(tracerName)
+}
+
+// startSpan starts a span for the given RPC if a tracer provider is set.
+// The returned span is nil when tracing is disabled.
+func (c *p4rtClient) startSpan(ctx context.Context, rpc string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	c.mu.RLock()
+	tracer := c.tracer
+	c.mu.RUnlock()
+	if tracer == nil {
+		return ctx, nil
+	}
+	attrs = append(attrs, attribute.Int64("p4rt.device_id", int64(c.deviceID)))
+	return tracer.Start(ctx, rpc, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan records the outcome of the RPC on span (if any) and ends it.
+func endSpan(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	grpcCode := status.Code(err)
+	span.SetAttributes(attribute.String("rpc.grpc.status_code", grpcCode.String()))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
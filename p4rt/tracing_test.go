@@ -0,0 +1,88 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+)
+
+// recordingTracer records the status of the spans it starts.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (r *recordingTracer) Tracer(string, ...trace.TracerOption) trace.Tracer { return r }
+
+func (r *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &recordingSpan{Span: trace.SpanFromContext(context.Background())}
+	r.mu.Lock()
+	r.spans = append(r.spans, span)
+	r.mu.Unlock()
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func (r *recordingTracer) last() *recordingSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.spans[len(r.spans)-1]
+}
+
+// recordingSpan is a no-op span that remembers its status.
+type recordingSpan struct {
+	trace.Span
+	mu     sync.Mutex
+	status otelcodes.Code
+	ended  bool
+}
+
+func (s *recordingSpan) SetStatus(code otelcodes.Code, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = code
+}
+
+func (s *recordingSpan) End(...trace.SpanEndOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+func (s *recordingSpan) outcome() (otelcodes.Code, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status, s.ended
+}
+
+// TestWriteSpanFinalOutcome checks that a write span records the outcome
+// after retries, not that of the first RPC.
+func TestWriteSpanFinalOutcome(t *testing.T) {
+	c, fake := newTestClient(t, 1, 1)
+	tracer := &recordingTracer{}
+	c.SetTracerProvider(tracer)
+	c.SetRetryableCodes(codes.Unavailable)
+
+	fake.setFail(failFirst(1, codes.Unavailable))
+	if errs := <-c.Write(insertRequest(c, 1)); countFailed(errs) != 0 {
+		t.Fatalf("write failed after its retry: %v", errs)
+	}
+	if status, ended := tracer.last().outcome(); !ended || status == otelcodes.Error {
+		t.Errorf("span of a write that succeeded on retry ended %v with status %v", ended, status)
+	}
+
+	fake.setFail(failFirst(maxWriteAttempts, codes.Unavailable))
+	if errs := <-c.Write(insertRequest(c, 1)); countFailed(errs) == 0 {
+		t.Fatal("write succeeded though every attempt failed")
+	}
+	if status, ended := tracer.last().outcome(); !ended || status != otelcodes.Error {
+		t.Errorf("span of a failed write ended %v with status %v, want %v", ended, status, otelcodes.Error)
+	}
+}
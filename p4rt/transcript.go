@@ -0,0 +1,100 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// WriteResult is the outcome of a write as seen by the caller.
+type WriteResult struct {
+	Errors   []*p4.Error // one per update, as from Write
+	Duration time.Duration
+}
+
+// Transcript records everything exchanged for one Write RPC, enough to
+// reproduce and report it without rerunning a benchmark.
+type Transcript struct {
+	Start   time.Time
+	Peer    string
+	Request []byte      // the WriteRequest exactly as marshaled
+	Status  *spb.Status // the raw gRPC status, including its details
+	Header  metadata.MD
+	Trailer metadata.MD
+}
+
+// WriteWithTranscript sends req right away, bypassing the write queue,
+// retries and the in-flight byte cap, and records the full exchange. The
+// client context and write timeout still apply.
+func (c *p4rtClient) WriteWithTranscript(req *p4.WriteRequest) (WriteResult, Transcript) {
+	raw, err := proto.Marshal(req)
+	if err != nil {
+		return WriteResult{Errors: syntheticErrors(len(req.GetUpdates()), codes.InvalidArgument, err.Error())}, Transcript{}
+	}
+	ctx := c.rootContext()
+	if timeout := c.getWriteTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	transcript := Transcript{Request: raw}
+	var p peer.Peer
+	transcript.Start = time.Now()
+	_, rpcErr := c.client.Write(ctx, req, grpc.Header(&transcript.Header), grpc.Trailer(&transcript.Trailer), grpc.Peer(&p))
+	result := WriteResult{Duration: time.Since(transcript.Start)}
+	if p.Addr != nil {
+		transcript.Peer = p.Addr.String()
+	}
+	transcript.Status = status.Convert(rpcErr).Proto()
+	result.Errors = parseP4RuntimeWriteError(rpcErr, len(req.GetUpdates()))
+	return result, transcript
+}
+
+// String renders the transcript as text for a bug report.
+func (t Transcript) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "start: %s\n", t.Start.UTC().Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "peer: %s\n", t.Peer)
+	fmt.Fprintf(&b, "request (%d bytes): %x\n", len(t.Request), t.Request)
+	req := &p4.WriteRequest{}
+	if err := proto.Unmarshal(t.Request, req); err == nil {
+		fmt.Fprintf(&b, "request (text):\n%s", proto.MarshalTextString(req))
+	}
+	fmt.Fprintf(&b, "status:\n%s", proto.MarshalTextString(t.Status))
+	for i, detail := range t.Status.GetDetails() {
+		p4Err := &p4.Error{}
+		if err := proto.Unmarshal(detail.GetValue(), p4Err); err == nil {
+			fmt.Fprintf(&b, "detail %d: %s\n", i, proto.CompactTextString(p4Err))
+		}
+	}
+	writeMD(&b, "header", t.Header)
+	writeMD(&b, "trailer", t.Trailer)
+	return b.String()
+}
+
+func writeMD(b *strings.Builder, name string, md metadata.MD) {
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s %s: %s\n", name, k, strings.Join(md[k], ", "))
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// writeTx is an open transaction: submissions buffered until CommitTx.
+type writeTx struct {
+	updates     []*p4.Update
+	submissions []txSubmission
+}
+
+// txSubmission is one TxWrite call, a range of the transaction's updates.
+type txSubmission struct {
+	start, end int
+	resp       chan []*p4.Error
+}
+
+// BeginTx opens a transaction. Updates passed to TxWrite are buffered until
+// CommitTx sends them all as one ROLLBACK_ON_ERROR write. Only one
+// transaction can be open per client at a time.
+func (c *p4rtClient) BeginTx() error {
+	c.txMu.Lock()
+	defer c.txMu.Unlock()
+	if c.tx != nil {
+		return fmt.Errorf("a transaction is already open")
+	}
+	c.tx = &writeTx{}
+	return nil
+}
+
+// TxWrite adds the updates of req to the open transaction. The returned
+// channel receives the errors of req's updates once the transaction is
+// committed, or codes.Aborted errors if it is aborted.
+func (c *p4rtClient) TxWrite(req *p4.WriteRequest) <-chan []*p4.Error {
+	c.txMu.Lock()
+	defer c.txMu.Unlock()
+	if c.tx == nil {
+		return failedWrite(len(req.GetUpdates()), codes.FailedPrecondition, "no transaction open")
+	}
+	submission := txSubmission{
+		start: len(c.tx.updates),
+		resp:  make(chan []*p4.Error, 1),
+	}
+	for _, update := range req.GetUpdates() {
+		c.tx.updates = append(c.tx.updates, proto.Clone(update).(*p4.Update))
+	}
+	submission.end = len(c.tx.updates)
+	c.tx.submissions = append(c.tx.submissions, submission)
+	return submission.resp
+}
+
+// CommitTx closes the open transaction and writes its updates as a single
+// atomic request. The returned channel receives the errors of every update
+// in the transaction, in TxWrite order; each TxWrite channel receives its
+// own share.
+func (c *p4rtClient) CommitTx() <-chan []*p4.Error {
+	c.txMu.Lock()
+	tx := c.tx
+	c.tx = nil
+	c.txMu.Unlock()
+	if tx == nil {
+		return failedWrite(1, codes.FailedPrecondition, "no transaction open")
+	}
+
+	committed := make(chan []*p4.Error, 1)
+	if len(tx.updates) == 0 {
+		for _, s := range tx.submissions {
+			s.resp <- []*p4.Error{}
+		}
+		committed <- []*p4.Error{}
+		return committed
+	}
+	res := c.Write(&p4.WriteRequest{
+		DeviceId:   c.deviceID,
+		ElectionId: c.ElectionID(),
+		Updates:    tx.updates,
+		Atomicity:  p4.WriteRequest_ROLLBACK_ON_ERROR,
+	})
+	go func() {
+		errors := <-res
+		for _, s := range tx.submissions {
+			s.resp <- errors[s.start:s.end]
+		}
+		committed <- errors
+	}()
+	return committed
+}
+
+// AbortTx discards the open transaction without writing anything. Its
+// TxWrite channels receive codes.Aborted errors.
+func (c *p4rtClient) AbortTx() {
+	c.txMu.Lock()
+	tx := c.tx
+	c.tx = nil
+	c.txMu.Unlock()
+	if tx == nil {
+		return
+	}
+	for _, s := range tx.submissions {
+		s.resp <- syntheticErrors(s.end-s.start, codes.Aborted, "transaction aborted")
+	}
+}
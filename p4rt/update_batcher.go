@@ -0,0 +1,173 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"sync"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+)
+
+type pendingUpdate struct {
+	update *p4.Update
+	resp   chan *p4.Error
+}
+
+// UpdateBatcher coalesces single updates into WriteRequests. A request is
+// written once maxBatch updates have accumulated or maxDelay after its
+// first update, whichever comes first, and each update's result is sent
+// back to its own submitter.
+type UpdateBatcher struct {
+	client   *p4rtClient
+	in       chan pendingUpdate
+	flush    chan chan struct{}
+	quit     chan struct{}
+	done     chan struct{}
+	size     int
+	maxDelay time.Duration
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewUpdateBatcher starts an UpdateBatcher writing through this client.
+// The election ID is read when each request is built.
+func (c *p4rtClient) NewUpdateBatcher(maxBatch int, maxDelay time.Duration) *UpdateBatcher {
+	if maxBatch < 1 {
+		maxBatch = 1
+	}
+	b := &UpdateBatcher{
+		client:   c,
+		in:       make(chan pendingUpdate, maxBatch),
+		flush:    make(chan chan struct{}),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+		size:     maxBatch,
+		maxDelay: maxDelay,
+	}
+	go b.run()
+	return b
+}
+
+// Submit queues update for the next request. The returned channel yields
+// the update's result once the request completes. Updates submitted after
+// Close fail with codes.Canceled.
+func (b *UpdateBatcher) Submit(update *p4.Update) <-chan *p4.Error {
+	resp := make(chan *p4.Error, 1)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		resp <- syntheticErrors(1, codes.Canceled, "update batcher is closed")[0]
+		return resp
+	}
+	b.in <- pendingUpdate{update: update, resp: resp}
+	return resp
+}
+
+// Flush writes the updates submitted so far without waiting for the batch
+// to fill or the timer to fire. It returns once they have been handed to
+// Write, not when the write completes.
+func (b *UpdateBatcher) Flush() {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	flushed := make(chan struct{})
+	b.flush <- flushed
+	<-flushed
+}
+
+// Close writes the pending updates and stops the batcher.
+func (b *UpdateBatcher) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	b.mu.Unlock()
+	close(b.quit)
+	<-b.done
+}
+
+func (b *UpdateBatcher) run() {
+	defer close(b.done)
+	batch := make([]pendingUpdate, 0, b.size)
+	timer := time.NewTimer(b.maxDelay)
+	timer.Stop()
+	var timeout <-chan time.Time
+
+	send := func() {
+		if !timer.Stop() && timeout != nil {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timeout = nil
+		if len(batch) > 0 {
+			b.write(batch)
+			batch = make([]pendingUpdate, 0, b.size)
+		}
+	}
+
+	// drain takes the updates Submit has already queued
+	drain := func() {
+		for len(b.in) > 0 {
+			batch = append(batch, <-b.in)
+			if len(batch) >= b.size {
+				send()
+			}
+		}
+	}
+
+	for {
+		select {
+		case p := <-b.in:
+			batch = append(batch, p)
+			if len(batch) == 1 {
+				timer.Reset(b.maxDelay)
+				timeout = timer.C
+			}
+			if len(batch) >= b.size {
+				send()
+			}
+		case <-timeout:
+			timeout = nil
+			send()
+		case flushed := <-b.flush:
+			drain()
+			send()
+			close(flushed)
+		case <-b.quit:
+			drain()
+			send()
+			return
+		}
+	}
+}
+
+// write submits batch as one WriteRequest and fans the results back out.
+func (b *UpdateBatcher) write(batch []pendingUpdate) {
+	c := b.client
+	req := &p4.WriteRequest{
+		DeviceId:   c.deviceID,
+		ElectionId: c.ElectionID(),
+		Updates:    make([]*p4.Update, len(batch)),
+	}
+	for i, p := range batch {
+		req.Updates[i] = p.update
+	}
+	res := c.Write(req)
+	go func() {
+		errors := <-res
+		for i, p := range batch {
+			p.resp <- errors[i]
+		}
+	}()
+}
@@ -0,0 +1,82 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// ValueSetLabel is the WriteTrace.Label of value set writes, which can also
+// be paced on their own with SetRateLimitFor.
+const ValueSetLabel = "value_set"
+
+// ValueSetMember is one member of a parser value set, given as the field
+// matches that make up its value.
+type ValueSetMember struct {
+	Match []*p4.FieldMatch
+}
+
+// WriteValueSet replaces the contents of the parser value set valueSetName
+// with members. P4Runtime only supports writing a value set as a whole, so
+// this is a single MODIFY update submitted through the write path, labeled
+// ValueSetLabel.
+func (c *p4rtClient) WriteValueSet(valueSetName string, members []ValueSetMember) <-chan []*p4.Error {
+	p4info, err := c.p4infoHelper()
+	if err != nil {
+		return failedWrite(1, codes.InvalidArgument, err.Error())
+	}
+	valueSet, err := p4info.GetValueSet(valueSetName)
+	if err != nil {
+		return failedWrite(1, codes.InvalidArgument, err.Error())
+	}
+	if len(members) > int(valueSet.GetSize()) {
+		return failedWrite(1, codes.InvalidArgument, fmt.Sprintf(
+			"value set %s holds at most %d members, got %d", valueSetName, valueSet.GetSize(), len(members)))
+	}
+
+	entry := &p4.ValueSetEntry{
+		ValueSetId: valueSet.GetPreamble().GetId(),
+		Members:    make([]*p4.ValueSetMember, len(members)),
+	}
+	for i, member := range members {
+		entry.Members[i] = &p4.ValueSetMember{Match: member.Match}
+	}
+	return c.WriteLabeled(ValueSetLabel, &p4.WriteRequest{
+		DeviceId:   c.deviceID,
+		ElectionId: c.ElectionID(),
+		Updates: []*p4.Update{{
+			Type:   p4.Update_MODIFY,
+			Entity: &p4.Entity{Entity: &p4.Entity_ValueSetEntry{ValueSetEntry: entry}},
+		}},
+	})
+}
+
+// ReadValueSet reads back the members of the parser value set valueSetName.
+func (c *p4rtClient) ReadValueSet(valueSetName string) ([]ValueSetMember, error) {
+	p4info, err := c.p4infoHelper()
+	if err != nil {
+		return nil, err
+	}
+	valueSetID, err := p4info.GetP4Id(valueSetName)
+	if err != nil {
+		return nil, err
+	}
+	entities, err := c.readEntities(&p4.Entity{Entity: &p4.Entity_ValueSetEntry{
+		ValueSetEntry: &p4.ValueSetEntry{ValueSetId: valueSetID},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	var members []ValueSetMember
+	for _, entity := range entities {
+		for _, member := range entity.GetValueSetEntry().GetMembers() {
+			members = append(members, ValueSetMember{Match: member.GetMatch()})
+		}
+	}
+	return members, nil
+}
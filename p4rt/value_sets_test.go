@@ -0,0 +1,52 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"testing"
+	"time"
+
+	p4_config "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// TestWriteValueSetLabel checks that value set writes are traced apart from
+// table writes.
+func TestWriteValueSetLabel(t *testing.T) {
+	c, fake := newTestClient(t, 1, 1)
+	helper := &P4InfoHelper{}
+	helper.InitFromP4Info(p4_config.P4Info{
+		ValueSets: []*p4_config.ValueSet{{
+			Preamble: &p4_config.Preamble{Id: 20, Name: "ports"},
+			Match:    []*p4_config.MatchField{{Id: 1, Name: "port", Bitwidth: 16}},
+			Size:     4,
+		}},
+	})
+	c.SetP4Info(helper)
+	traces := make(chan WriteTrace, 2)
+	c.SetWriteTraceChan(traces)
+
+	member := ValueSetMember{Match: []*p4.FieldMatch{{
+		FieldId:        1,
+		FieldMatchType: &p4.FieldMatch_Exact_{Exact: &p4.FieldMatch_Exact{Value: []byte{0x12, 0xb7}}},
+	}}}
+	if errs := <-c.WriteValueSet("ports", []ValueSetMember{member}); countFailed(errs) != 0 {
+		t.Fatalf("value set write failed: %v", errs)
+	}
+	<-c.Write(insertRequest(c, 1))
+	for _, want := range []string{ValueSetLabel, ""} {
+		select {
+		case trace := <-traces:
+			if trace.Label != want {
+				t.Errorf("trace labeled %q, want %q", trace.Label, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no write trace")
+		}
+	}
+	if writes, _ := fake.counts(); writes != 2 {
+		t.Errorf("switch got %d writes, want 2", writes)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+)
+
+// EncodeValue encodes s as a big-endian bytestring of bitwidth bits, padded
+// to whole bytes. s may be an IPv4 or IPv6 address, a MAC address, or an
+// unsigned integer in decimal, hex (0x), octal (0o) or binary (0b) form.
+func EncodeValue(s string, bitwidth int32) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	value := new(big.Int)
+	if mac, err := net.ParseMAC(s); err == nil && len(mac) == 6 {
+		value.SetBytes(mac)
+	} else if ip := net.ParseIP(s); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil && !strings.Contains(s, ":") {
+			value.SetBytes(ip4)
+		} else {
+			value.SetBytes(ip.To16())
+		}
+	} else if _, ok := value.SetString(s, 0); !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("cannot parse %q as an address or unsigned integer", s)
+	}
+	if value.BitLen() > int(bitwidth) {
+		return nil, fmt.Errorf("value %s does not fit in %d bits", s, bitwidth)
+	}
+	b := make([]byte, (bitwidth+7)/8)
+	raw := value.Bytes()
+	copy(b[len(b)-len(raw):], raw)
+	return b, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/stats"
+)
+
+// wireTimeHandler is the gRPC stats handler installed on every connection.
+// It only records anything for RPCs whose context carries a wireTimer.
+type wireTimeHandler struct{}
+
+type wireTimerKey struct{}
+
+// wireTimer collects the gRPC stats events of one RPC attempt.
+type wireTimer struct {
+	mu        sync.Mutex
+	sent      time.Time // request headers handed to the transport
+	received  time.Time // response message or trailers received
+	ended     time.Time // RPC finished on the client
+	gotInData bool
+}
+
+func withWireTimer(ctx context.Context, t *wireTimer) context.Context {
+	return context.WithValue(ctx, wireTimerKey{}, t)
+}
+
+func (wireTimeHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (wireTimeHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	t, ok := ctx.Value(wireTimerKey{}).(*wireTimer)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch s := s.(type) {
+	case *stats.OutHeader:
+		if t.sent.IsZero() {
+			t.sent = now
+		}
+	case *stats.InPayload:
+		t.received = s.RecvTime
+		t.gotInData = true
+	case *stats.InTrailer:
+		if !t.gotInData {
+			t.received = now
+			t.gotInData = true
+		}
+	case *stats.End:
+		t.ended = s.EndTime
+	}
+}
+
+func (wireTimeHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (wireTimeHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// duration returns the time from sending the request headers until the
+// response arrived. gRPC reports trailers-only responses (most write errors)
+// only after the RPC has returned, so for those the end of the RPC is used.
+func (t *wireTimer) duration() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	end := t.received
+	if end.IsZero() {
+		end = t.ended
+	}
+	if t.sent.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(t.sent)
+}
+
+// SetWireTimeCapture turns recording of WriteTrace.WireTime on or off.
+func (c *p4rtClient) SetWireTimeCapture(capture bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wireTimeCapture = capture
+}
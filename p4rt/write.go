@@ -7,80 +7,667 @@ package p4rt
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
 type p4Write struct {
-	req  *p4.WriteRequest
-	resp chan []*p4.Error
+	req   *p4.WriteRequest
+	resp  chan []*p4.Error
+	phase string          // phase set when the write was submitted
+	label string          // from WriteLabeled, or empty
+	size  int64           // proto.Size of req, counted against the in-flight byte cap
+	ctx   context.Context // from WriteContext, or nil
+	done  func()          // called once resp has been sent
+
+	enqueued   time.Time // when it was put on its queue
+	queueDepth int       // writes already on that queue
+
+	// Codes treated as success for DELETE updates, and where to count them
+	ignoreOnDelete map[codes.Code]bool
+	ignoredCount   *uint64
 }
 
 type WriteTrace struct {
-	BatchSize int
-	Duration  time.Duration
-	Errors    []*p4.Error
+	Start        time.Time // when the Write RPC was sent
+	BatchSize    int
+	Duration     time.Duration // from Start until the response was processed
+	Errors       []*p4.Error
+	SuccessCount int        // entries in Errors with an OK canonical code
+	ErrorCount   int        // entries in Errors with any other code
+	DominantCode codes.Code // see DominantCode
+	// PhysicalWrites is the number of Write RPCs the submission was sent as
+	// and LogicalUpdates the number of updates it contained. Submissions are
+	// never split, so PhysicalWrites is 1 plus any replays after
+	// codes.Unavailable and any per-update Retries.
+	PhysicalWrites int
+	LogicalUpdates int
+	// Peer is the address of the server that handled the write, as seen by
+	// gRPC. It tells apart connections when writes are spread across several.
+	Peer string
+	// WireTime is the part of Duration between gRPC sending the request
+	// headers and the response arriving, leaving out client-side gRPC
+	// queueing and flow control. It is zero unless SetWireTimeCapture is on.
+	WireTime time.Duration
+	// Phase is the name set with SetPhase when the write was submitted.
+	Phase string
+	// Label is the operation label given to WriteLabeled, or ValueSetLabel
+	// for WriteValueSet; empty for other writes.
+	Label string
+	// Scheduled is when the SetWriteRate pacer meant the write to go out.
+	// Measuring latency from Scheduled rather than Start gives open-loop
+	// numbers: a write held up by slow earlier writes is charged for the
+	// wait. It is zero when pacing is off.
+	Scheduled time.Time
+	// RequestedRate is the paced rate in updates per second and
+	// AchievedRate the rate the pacer has released updates at since the rate
+	// was set, counting this write. Both are zero when pacing is off.
+	RequestedRate float64
+	AchievedRate  float64
+	// Pipeline is the index of the write pipeline (connection) that sent
+	// the write; see SetWritePipelines. PipelineRate is that pipeline's
+	// throughput and AggregateRate the client's, in updates per second
+	// from the first write sent to this one's completion.
+	Pipeline      int
+	PipelineRate  float64
+	AggregateRate float64
+	// Retries is the number of rounds of per-update retries the write went
+	// through and RetriedUpdates the number of its updates resent at least
+	// once; see SetRetryPolicy. Errors holds each update's final outcome.
+	Retries        int
+	RetriedUpdates int
+	// Enqueued is when the write was queued for the write threads and
+	// Dequeued when a thread took it; End is when the last RPC for it
+	// returned. Enqueued to Dequeued is client-side queueing, Dequeued to
+	// Start the wait for mastership and pacing, and Start to End the time
+	// spent on the wire and in the switch.
+	Enqueued time.Time
+	Dequeued time.Time
+	End      time.Time
+	// QueueDepth is the number of writes already waiting on the queue when
+	// this one was submitted.
+	QueueDepth int
+	// Inserts, Modifies and Deletes count the updates of each type.
+	Inserts  int
+	Modifies int
+	Deletes  int
+	// Request is the submitted request, if SetTraceIncludeRequest is on.
+	// It is the client's private copy, not the caller's message.
+	Request *p4.WriteRequest
+}
+
+// traceConfig is the trace delivery state captured when a write is dispatched.
+type traceConfig struct {
+	traceChan      chan WriteTrace
+	buffer         *traceBuffer
+	batcher        *traceBatcher
+	includeRequest bool
+	dropped        *uint64
+}
+
+// codeSeverity ranks canonical codes for DominantCode; higher is more severe.
+// Codes pointing at the switch or transport rank above codes pointing at
+// the request contents, and OK (and any unlisted code) ranks lowest.
+var codeSeverity = map[codes.Code]int{
+	codes.NotFound:           1,
+	codes.AlreadyExists:      2,
+	codes.OutOfRange:         3,
+	codes.InvalidArgument:    4,
+	codes.Canceled:           5,
+	codes.Aborted:            6,
+	codes.FailedPrecondition: 7,
+	codes.ResourceExhausted:  8,
+	codes.DeadlineExceeded:   9,
+	codes.PermissionDenied:   10,
+	codes.Unauthenticated:    11,
+	codes.Unavailable:        12,
+	codes.Unimplemented:      13,
+	codes.Unknown:            14,
+	codes.Internal:           15,
+	codes.DataLoss:           16,
+}
+
+// DominantCode returns the most severe non-OK canonical code in errors, or
+// codes.OK if every entry succeeded.
+func DominantCode(errors []*p4.Error) codes.Code {
+	dominant := codes.OK
+	for _, p4Err := range errors {
+		code := codes.Code(p4Err.GetCanonicalCode())
+		if code == codes.OK {
+			continue
+		}
+		if dominant == codes.OK || codeSeverity[code] > codeSeverity[dominant] {
+			dominant = code
+		}
+	}
+	return dominant
 }
 
 func (c *p4rtClient) Write(req *p4.WriteRequest) <-chan []*p4.Error {
-	res := make(chan []*p4.Error, c.batchSize)
-	c.writes <- p4Write{
-		req:  proto.Clone(req).(*p4.WriteRequest),
-		resp: res,
+	return c.submitWrite(nil, "", req, c.writes)
+}
+
+// WriteContext is like Write, but ctx bounds the whole write: waiting for
+// room in the write queue, waiting to be sent and the RPC itself. A write
+// whose ctx ends first fails with codes.Canceled or codes.DeadlineExceeded.
+func (c *p4rtClient) WriteContext(ctx context.Context, req *p4.WriteRequest) <-chan []*p4.Error {
+	return c.submitWrite(ctx, "", req, c.writes)
+}
+
+// submitWrite queues req on queue for the write threads. ctx may be nil, and
+// label is empty for unlabeled writes.
+func (c *p4rtClient) submitWrite(ctx context.Context, label string, req *p4.WriteRequest, queue chan p4Write) <-chan []*p4.Error {
+	if c.isShutDown() {
+		return failedWrite(len(req.GetUpdates()), codes.Canceled, "client is shut down")
+	}
+	if c.rootContext().Err() != nil {
+		return failedWrite(len(req.GetUpdates()), codes.Canceled, "client context is done")
+	}
+	if ctx != nil && ctx.Err() != nil {
+		return failedWrite(len(req.GetUpdates()), contextCode(ctx.Err()), ctx.Err().Error())
+	}
+	// The response is a single slice, so one slot is enough for
+	// processWriteResponse never to block
+	res := make(chan []*p4.Error, 1)
+	size := int64(proto.Size(req))
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+		if done != nil {
+			// acquireBytes waits on a sync.Cond, which ctx cannot wake by itself
+			stop := make(chan struct{})
+			defer close(stop)
+			go func() {
+				select {
+				case <-done:
+					c.wakeFlowControl()
+				case <-stop:
+				}
+			}()
+		}
+	}
+	c.acquireBytes(ctx, size)
+	if ctx != nil && ctx.Err() != nil {
+		c.releaseBytes(size)
+		return failedWrite(len(req.GetUpdates()), contextCode(ctx.Err()), ctx.Err().Error())
+	}
+	if !c.trackWrite() {
+		// Shutdown started since the check above
+		c.releaseBytes(size)
+		return failedWrite(len(req.GetUpdates()), codes.Canceled, "client is shut down")
+	}
+	c.recordWrite(req)
+	write := p4Write{
+		enqueued:       time.Now(),
+		queueDepth:     len(queue),
+		req:            proto.Clone(req).(*p4.WriteRequest),
+		resp:           res,
+		phase:          c.currentPhase(),
+		label:          label,
+		size:           size,
+		ctx:            ctx,
+		done:           c.writeAnswered,
+		ignoreOnDelete: c.ignoreCodesOnDeleteSnapshot(),
+		ignoredCount:   &c.deletesIgnored,
+	}
+	select {
+	case queue <- write:
+	case <-done:
+		c.releaseBytes(size)
+		write.respond(syntheticErrors(len(req.GetUpdates()), contextCode(ctx.Err()), ctx.Err().Error()))
+	}
+	return res
+}
+
+// respond sends errors to the writer and marks the write answered.
+func (write p4Write) respond(errors []*p4.Error) {
+	write.resp <- errors
+	if write.done != nil {
+		write.done()
+	}
+}
+
+// contextCode maps a context error to the matching gRPC code.
+func contextCode(err error) codes.Code {
+	if err == context.DeadlineExceeded {
+		return codes.DeadlineExceeded
 	}
+	return codes.Canceled
+}
+
+// failedWrite returns a response channel holding a synthetic error for each of
+// numUpdates updates, for writes rejected before they reach the switch.
+func failedWrite(numUpdates int, code codes.Code, message string) <-chan []*p4.Error {
+	res := make(chan []*p4.Error, 1)
+	res <- syntheticErrors(numUpdates, code, message)
 	return res
 }
 
+// syntheticErrors builds the same client-side p4.Error for each of numUpdates updates.
+func syntheticErrors(numUpdates int, code codes.Code, message string) []*p4.Error {
+	p4Err := &p4.Error{
+		CanonicalCode: int32(code),
+		Message:       message,
+		Space:         "p4rt-go",
+	}
+	errors := make([]*p4.Error, numUpdates)
+	for i := range errors {
+		errors[i] = p4Err
+	}
+	return errors
+}
+
+// SetIgnoreCodesOnDelete makes the given canonical codes count as success
+// for DELETE updates, e.g. codes.NotFound for entries that aged out before
+// teardown. Such updates are reported with codes.OK and a message naming
+// the ignored code, and counted in Stats.DeleteErrorsIgnored. Other codes,
+// and the same codes on other update types, are reported as usual. Calling
+// it with no codes turns this off.
+func (c *p4rtClient) SetIgnoreCodesOnDelete(ignore ...codes.Code) {
+	ignored := make(map[codes.Code]bool, len(ignore))
+	for _, code := range ignore {
+		ignored[code] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ignoreCodesOnDelete = ignored
+}
+
+func (c *p4rtClient) ignoreCodesOnDeleteSnapshot() map[codes.Code]bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ignoreCodesOnDelete
+}
+
+// ignoreDeleteErrors rewrites errors of write's DELETE updates that have an
+// ignored code to OK.
+func ignoreDeleteErrors(write p4Write, errors []*p4.Error) {
+	for i, update := range write.req.GetUpdates() {
+		code := codes.Code(errors[i].GetCanonicalCode())
+		if update.GetType() != p4.Update_DELETE || code == codes.OK || !write.ignoreOnDelete[code] {
+			continue
+		}
+		errors[i] = &p4.Error{
+			CanonicalCode: int32(codes.OK),
+			Message:       fmt.Sprintf("ignored %v on delete: %s", code, errors[i].GetMessage()),
+			Space:         "p4rt-go",
+		}
+		atomic.AddUint64(write.ignoredCount, 1)
+	}
+}
+
+// SetBatchSize changes the batch size used for helpers that batch on the
+// caller's behalf (such as ResetCounters). Write error slices and traces
+// always follow each request's own update count. The write queue depth is
+// fixed when the client is created.
+func (c *p4rtClient) SetBatchSize(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batchSize = n
+}
+
+func (c *p4rtClient) getBatchSize() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.batchSize
+}
+
+// SetPhase names the phase of the run (such as "fill", "probe" or "drain")
+// that writes submitted from now on belong to. Their WriteTraces carry the
+// name in Phase. The empty string clears it.
+func (c *p4rtClient) SetPhase(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.phase = name
+}
+
+func (c *p4rtClient) currentPhase() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.phase
+}
+
 func (c *p4rtClient) SetWriteTraceChan(traceChan chan WriteTrace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.writeTraceChan = traceChan
+	// Wake a trace buffer forwarder blocked on the old channel
+	if c.writeTraceChanSet != nil {
+		close(c.writeTraceChanSet)
+	}
+	c.writeTraceChanSet = make(chan struct{})
 }
 
+// ListenForWrites sends writes from the shared write queue over the
+// client's first connection until the client is shut down.
 func (c *p4rtClient) ListenForWrites() {
+	c.listenForWrites(c.writePipelines()[0])
+}
+
+func (c *p4rtClient) listenForWrites(pipeline *writePipeline) {
 	for {
-		write := <-c.writes // wait for the first write in the batch
-		req := write.req
-		// Write the request
-		start := time.Now()
-		_, err := c.client.Write(context.Background(), req)
-		// ignore the write response; it is an empty message (details, if any, are in err)
-		go processWriteResponse(write, err, c.batchSize, start, c.writeTraceChan)
+		select {
+		case write := <-c.writes:
+			c.sendWrite(pipeline, write, false)
+		case <-c.writersDone:
+			return
+		}
 	}
 }
 
-func processWriteResponse(write p4Write, err error, batchSize int, start time.Time, traceChan chan WriteTrace) {
-	duration := time.Since(start)
-	errors := parseP4RuntimeWriteError(err, batchSize)
-	// Send p4.Errors to waiting channels
-	write.resp <- errors
+// sendWrite sends write on pipeline. The response is processed before it
+// returns if wait is set, and in the background otherwise.
+func (c *p4rtClient) sendWrite(pipeline *writePipeline, write p4Write, wait bool) {
+	dequeued := time.Now()
+	req := write.req
+	root := c.rootContext()
+	if root.Err() != nil {
+		c.releaseBytes(write.size)
+		write.respond(syntheticErrors(len(req.GetUpdates()), codes.Canceled, "client context is done"))
+		return
+	}
+	if write.ctx != nil && write.ctx.Err() != nil {
+		c.releaseBytes(write.size)
+		write.respond(syntheticErrors(len(req.GetUpdates()), contextCode(write.ctx.Err()), write.ctx.Err().Error()))
+		return
+	}
+	if err := c.waitForPrimary(root); err == errStreamClosed {
+		c.releaseBytes(write.size)
+		write.respond(syntheticErrors(len(req.GetUpdates()), codes.Unavailable, "stream channel closed before becoming primary"))
+		return
+	} else if err != nil {
+		c.releaseBytes(write.size)
+		write.respond(syntheticErrors(len(req.GetUpdates()), codes.Canceled, "client context is done before becoming primary"))
+		return
+	}
+	stopMerge := func() {}
+	if write.ctx != nil {
+		root, stopMerge = mergeContexts(root, write.ctx)
+	}
+	paced, err := c.pace(root, write.label, len(req.GetUpdates()))
+	if err != nil {
+		stopMerge()
+		c.releaseBytes(write.size)
+		write.respond(syntheticErrors(len(req.GetUpdates()), contextCode(err), "write cancelled while waiting for its pacing slot"))
+		return
+	}
+	if req.RoleId == 0 {
+		req.RoleId = c.getRoleID()
+	}
+	spanParent := root
+	if write.ctx != nil {
+		// The merged context has the client's values; the span belongs
+		// under the caller's
+		spanParent = trace.ContextWithSpan(root, trace.SpanFromContext(write.ctx))
+	}
+	ctx, span := c.startSpan(spanParent, "p4.v1.P4Runtime/Write",
+		attribute.Int("p4rt.batch_size", len(req.Updates)))
+	captureWireTime := c.captureWireTime()
+	timeout := c.getWriteTimeout()
+	var p peer.Peer
+	var timer *wireTimer
+	send := func(req *p4.WriteRequest) error {
+		callCtx := ctx
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		if captureWireTime {
+			// only the last attempt is timed
+			timer = &wireTimer{}
+			callCtx = withWireTimer(callCtx, timer)
+		}
+		_, err := pipeline.client.Write(callCtx, req, grpc.Peer(&p))
+		return err
+	}
+	// Write the request
+	start := time.Now()
+	err = send(req)
+	// The switch may be restarting; resend once the stream is back
+	maxReplays := c.maxReplays()
+	replays := 0
+	for ; err != nil && status.Code(err) == codes.Unavailable && replays < maxReplays; replays++ {
+		if c.waitReconnected(ctx, replays) != nil {
+			break
+		}
+		atomic.AddUint64(&c.writesReplayed, 1)
+		err = send(req)
+	}
+	// Updates that failed for a transient reason are resent on their own
+	var errors []*p4.Error
+	retries, retriedUpdates := 0, 0
+	if policy := c.retryPolicySnapshot(); policy != nil && err != nil {
+		errors, retries, retriedUpdates = c.retryUpdates(ctx, policy, req,
+			parseP4RuntimeWriteError(err, len(req.GetUpdates())), send)
+	}
+	// ignore the write response; it is an empty message (details, if any, are in err).
+	// P4Runtime has no way to echo server-assigned values on a write: the only
+	// per-update data a switch returns is the p4.Error (including its Details
+	// Any) packed into the gRPC status, which parseP4RuntimeWriteError surfaces.
+	// Anything the switch allocates must be read back with a ReadRequest.
+	end := time.Now()
+	// err is the last full send's; retries may have changed the outcome
+	spanErr := err
+	if errors != nil {
+		spanErr = writeOutcome(errors)
+	}
+	endSpan(span, spanErr)
+	stopMerge()
+	c.releaseBytes(write.size)
+	rpc := writeRPC{start: start, err: err, wireTimer: timer, pacing: paced, pipeline: pipeline.index,
+		errors: errors, replays: replays, retries: retries, retriedUpdates: retriedUpdates, dequeued: dequeued, end: end}
+	if p.Addr != nil {
+		rpc.peer = p.Addr.String()
+	}
+	rpc.pipelineRate, rpc.aggregateRate = c.recordThroughput(pipeline, start, len(req.GetUpdates()))
+	if wait {
+		processWriteResponse(write, rpc, c.traceConfig())
+	} else {
+		go processWriteResponse(write, rpc, c.traceConfig())
+	}
+}
+
+// SetWriteTimeout bounds each Write RPC attempt; an attempt that runs out
+// fails with codes.DeadlineExceeded. It is independent of SetReadTimeout.
+// Once the client context (SetContext) is done, writes are cancelled
+// regardless. Zero (the default) means no timeout.
+func (c *p4rtClient) SetWriteTimeout(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeTimeout = d
+}
+
+func (c *p4rtClient) getWriteTimeout() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.writeTimeout
+}
+
+func (c *p4rtClient) captureWireTime() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.wireTimeCapture
+}
+
+func (c *p4rtClient) traceConfig() traceConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return traceConfig{
+		traceChan:      c.writeTraceChan,
+		buffer:         c.traceBuffer,
+		batcher:        c.traceBatcher,
+		includeRequest: c.traceIncludeRequest,
+		dropped:        &c.tracesDropped,
+	}
+}
+
+// SetTraceIncludeRequest controls whether each WriteTrace carries the
+// WriteRequest that produced it. It is off by default because retaining
+// every request is memory-heavy.
+func (c *p4rtClient) SetTraceIncludeRequest(include bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.traceIncludeRequest = include
+}
+
+// writeRPC is the outcome of sending one write to the switch.
+type writeRPC struct {
+	start time.Time
+	err   error
+	peer  string // address of the server that answered, if known
+
+	dequeued time.Time // when the write thread took the write
+	end      time.Time // when the last RPC for it returned
+
+	wireTimer *wireTimer // nil unless wire time capture is on
+	pacing    pacing     // zero unless SetWriteRate is on
 
-	if traceChan != nil {
+	pipeline      int // index of the write pipeline that sent it
+	pipelineRate  float64
+	aggregateRate float64
+
+	// errors, if set, replaces the errors parsed from err; it holds the
+	// merged outcome of per-update retries
+	errors         []*p4.Error
+	replays        int // resends after codes.Unavailable
+	retries        int
+	retriedUpdates int
+}
+
+func processWriteResponse(write p4Write, rpc writeRPC, tc traceConfig) {
+	start, err := rpc.start, rpc.err
+	duration := time.Since(start)
+	// Size everything by this request, not the configured batch size, so the
+	// errors line up one-to-one with the submitted updates
+	batchSize := len(write.req.GetUpdates())
+	errors := rpc.errors
+	if errors == nil {
+		errors = parseP4RuntimeWriteError(err, batchSize)
+	}
+	if len(write.ignoreOnDelete) > 0 {
+		ignoreDeleteErrors(write, errors)
+	}
+	if tc.traceChan != nil || tc.batcher != nil {
 		trace := WriteTrace{
-			BatchSize: batchSize,
-			Duration:  duration,
-			Errors:    errors,
+			Start:          start,
+			BatchSize:      batchSize,
+			Duration:       duration,
+			Errors:         errors,
+			DominantCode:   DominantCode(errors),
+			PhysicalWrites: 1 + rpc.replays + rpc.retries,
+			LogicalUpdates: batchSize,
+			Peer:           rpc.peer,
+			Phase:          write.phase,
+			Label:          write.label,
+			Scheduled:      rpc.pacing.scheduled,
+			RequestedRate:  rpc.pacing.requested,
+			AchievedRate:   rpc.pacing.achieved,
+			Pipeline:       rpc.pipeline,
+			PipelineRate:   rpc.pipelineRate,
+			AggregateRate:  rpc.aggregateRate,
+			Retries:        rpc.retries,
+			RetriedUpdates: rpc.retriedUpdates,
+			Enqueued:       write.enqueued,
+			Dequeued:       rpc.dequeued,
+			End:            rpc.end,
+			QueueDepth:     write.queueDepth,
 		}
-		select {
-		case traceChan <- trace: // put trace into the channel unless it is full
-		default:
-			fmt.Println("Write trace channel full. Discarding trace")
+		for _, update := range write.req.GetUpdates() {
+			switch update.GetType() {
+			case p4.Update_INSERT:
+				trace.Inserts++
+			case p4.Update_MODIFY:
+				trace.Modifies++
+			case p4.Update_DELETE:
+				trace.Deletes++
+			}
 		}
+		if rpc.wireTimer != nil {
+			trace.WireTime = rpc.wireTimer.duration()
+		}
+		for _, p4Err := range errors {
+			if p4Err.GetCanonicalCode() == int32(codes.OK) {
+				trace.SuccessCount++
+			} else {
+				trace.ErrorCount++
+			}
+		}
+		if tc.includeRequest {
+			trace.Request = write.req
+		}
+		deliverTrace(tc, trace)
+	}
+	// Send p4.Errors to waiting channels. The trace goes first, so that a
+	// writer holding its response can count on the trace having been sent.
+	write.respond(errors)
+}
+
+func deliverTrace(tc traceConfig, trace WriteTrace) {
+	if tc.batcher != nil && !tc.batcher.push(trace) {
+		atomic.AddUint64(tc.dropped, 1)
+		fmt.Println("Write trace batch channel backed up or removed. Discarding trace")
+	}
+	if tc.traceChan == nil {
+		return
+	}
+	if tc.buffer != nil {
+		if !tc.buffer.push(trace) {
+			fmt.Println("Write trace buffer full. Discarding trace")
+		}
+		return
+	}
+	select {
+	case tc.traceChan <- trace: // put trace into the channel unless it is full
+	default:
+		atomic.AddUint64(tc.dropped, 1)
+		fmt.Println("Write trace channel full. Discarding trace")
+	}
+}
+
+// writeOutcome returns nil if every update of errors succeeded, and
+// otherwise a status error with their DominantCode.
+func writeOutcome(errors []*p4.Error) error {
+	failed := countFailed(errors)
+	if failed == 0 {
+		return nil
 	}
+	return status.Errorf(DominantCode(errors), "%d of %d updates failed", failed, len(errors))
 }
 
-func parseP4RuntimeWriteError(err error, batchSize int) []*p4.Error {
-	errors := make([]*p4.Error, batchSize)
+// parseP4RuntimeWriteError returns one p4.Error per update of a request with
+// numUpdates updates, in update order.
+func parseP4RuntimeWriteError(err error, numUpdates int) []*p4.Error {
+	errors := make([]*p4.Error, numUpdates)
 	var code int32
 	var message = ""
 	if err != nil {
 		grpcError := status.Convert(err).Proto() // TODO consider status.FromError()
-		if grpcError.GetCode() == int32(codes.Unknown) && batchSize > 0 && len(grpcError.GetDetails()) == batchSize {
-			// gRPC error may contain p4.Errors
-			for i := range grpcError.Details {
+		if grpcError.GetCode() == int32(codes.Unknown) && numUpdates > 0 && len(grpcError.GetDetails()) > 0 {
+			// gRPC error may contain p4.Errors. p4.Error carries no update
+			// index, so details are matched to updates by position. If the
+			// switch sent fewer details than updates, the updates left over
+			// get a stand-in error rather than a guessed status.
+			for i := range errors {
+				if i >= len(grpcError.Details) {
+					errors[i] = &p4.Error{
+						CanonicalCode: int32(codes.Unknown),
+						Message: fmt.Sprintf("unknown per-entry status: switch returned %d error details for %d updates: %s",
+							len(grpcError.Details), numUpdates, grpcError.GetMessage()),
+						Space: "p4rt-go",
+					}
+					continue
+				}
 				p4Err := p4.Error{}
 				unmarshallErr := ptypes.UnmarshalAny(grpcError.Details[i], &p4Err)
 				if unmarshallErr != nil {
@@ -95,6 +682,7 @@ func parseP4RuntimeWriteError(err error, batchSize int) []*p4.Error {
 			}
 			return errors
 		}
+		code = grpcError.GetCode()
 		message = grpcError.GetMessage()
 	} else {
 		code = int32(codes.OK)
@@ -111,6 +699,72 @@ func parseP4RuntimeWriteError(err error, batchSize int) []*p4.Error {
 	return errors
 }
 
+// CancelPending fails every write still waiting in the write queue with
+// codes.Canceled and returns how many were cancelled. Writes already sent to
+// the switch are not affected.
+func (c *p4rtClient) CancelPending() int {
+	cancelled := 0
+	for {
+		select {
+		case write := <-c.writes:
+			c.cancelQueued(write)
+			cancelled++
+		default:
+			for _, pipeline := range c.writePipelines() {
+				cancelled += c.cancelOrdered(pipeline)
+			}
+			return cancelled
+		}
+	}
+}
+
+func (c *p4rtClient) cancelQueued(write p4Write) {
+	c.releaseBytes(write.size)
+	write.respond(syntheticErrors(len(write.req.GetUpdates()), codes.Canceled,
+		"write cancelled before it was sent"))
+}
+
+// SetContext sets the root context for writes. Once ctx is done, in-flight
+// write RPCs are cancelled, queued writes fail with codes.Canceled and new
+// writes fail immediately. Write tracing is not affected. The previous root
+// context is cancelled, so RPCs still in flight under it are cancelled too;
+// queued writes are left for the new one.
+func (c *p4rtClient) SetContext(ctx context.Context) {
+	// Shutdown cancels the root context through cancelCtx
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	previous := c.cancelCtx
+	c.ctx = ctx
+	c.cancelCtx = cancel
+	c.mu.Unlock()
+	if previous != nil {
+		previous()
+	}
+	go func() {
+		<-ctx.Done()
+		if c.rootContext() != ctx {
+			// Replaced by a later SetContext
+			return
+		}
+		c.wakeFlowControl()
+		c.CancelPending()
+	}()
+}
+
+func (c *p4rtClient) rootContext() context.Context {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ctx
+}
+
 func (c *p4rtClient) RemainingWrites() bool {
-	return len(c.writes) > 0
+	if len(c.writes) > 0 {
+		return true
+	}
+	for _, pipeline := range c.writePipelines() {
+		if len(pipeline.ordered) > 0 {
+			return true
+		}
+	}
+	return false
 }
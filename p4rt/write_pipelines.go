@@ -0,0 +1,188 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	"google.golang.org/grpc"
+)
+
+// writePipeline is one connection's worth of write threads. Pipeline 0 uses
+// the shared connection from GetConnection; the others own theirs.
+type writePipeline struct {
+	index   int
+	client  p4.P4RuntimeClient
+	conn    *grpc.ClientConn // nil for pipeline 0
+	ordered chan p4Write     // WriteOrdered queue, sent one at a time
+
+	throughput writeThroughput
+}
+
+// writeThroughput counts the updates answered since the first write was
+// sent.
+type writeThroughput struct {
+	mu       sync.Mutex
+	first    time.Time
+	requests uint64
+	updates  uint64
+}
+
+// add counts a request of n updates sent at start and answered at end, and
+// returns the throughput so far in updates per second.
+func (t *writeThroughput) add(start, end time.Time, n int) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.first.IsZero() || start.Before(t.first) {
+		t.first = start
+	}
+	t.requests++
+	t.updates += uint64(n)
+	return t.rateLocked(end)
+}
+
+func (t *writeThroughput) rateLocked(now time.Time) float64 {
+	elapsed := now.Sub(t.first).Seconds()
+	if t.first.IsZero() || elapsed <= 0 {
+		return 0
+	}
+	return float64(t.updates) / elapsed
+}
+
+func (t *writeThroughput) stats() PipelineStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return PipelineStats{
+		Requests:   t.requests,
+		Updates:    t.updates,
+		Throughput: t.rateLocked(time.Now()),
+	}
+}
+
+// PipelineStats is the traffic one write pipeline has carried.
+type PipelineStats struct {
+	Requests uint64
+	Updates  uint64
+	// Throughput is in updates per second, from the pipeline's first write
+	// until now.
+	Throughput float64
+}
+
+func newWritePipeline(index int, client p4.P4RuntimeClient, conn *grpc.ClientConn, depth int) *writePipeline {
+	return &writePipeline{
+		index:   index,
+		client:  client,
+		conn:    conn,
+		ordered: make(chan p4Write, depth),
+	}
+}
+
+// startWritePipeline runs the pipeline's share of threads on the shared
+// write queue, and the thread for its ordered queue. They run until
+// stopWriteThreads.
+func (c *p4rtClient) startWritePipeline(pipeline *writePipeline) {
+	c.writers.Add(c.numThreads + 1)
+	for i := 0; i < c.numThreads; i++ {
+		go func() {
+			defer c.writers.Done()
+			c.listenForWrites(pipeline)
+		}()
+	}
+	go func() {
+		defer c.writers.Done()
+		for {
+			select {
+			case write := <-pipeline.ordered:
+				c.sendWrite(pipeline, write, true)
+			case <-c.writersDone:
+				return
+			}
+		}
+	}()
+}
+
+// SetWritePipelines opens connections to the switch until there are n, each
+// with its own numThreads write threads, so that writes are no longer
+// limited by one HTTP/2 connection. Writes from Write and WriteContext go
+// to whichever thread is free, on any connection. The stream channel stays
+// on the first connection. Pipelines can be added but not removed, and are
+// closed by Shutdown.
+func (c *p4rtClient) SetWritePipelines(n int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.shutDown {
+		return fmt.Errorf("client is shut down")
+	}
+	if n < len(c.pipelines) {
+		return fmt.Errorf("cannot reduce the write pipelines from %d to %d", len(c.pipelines), n)
+	}
+	for len(c.pipelines) < n {
+		conn, err := dialConnection(c.host, hostClientOptions(c.host))
+		if err != nil {
+			return err
+		}
+		pipeline := newWritePipeline(len(c.pipelines), p4.NewP4RuntimeClient(conn), conn, cap(c.writes))
+		c.pipelines = append(c.pipelines, pipeline)
+		c.startWritePipeline(pipeline)
+	}
+	return nil
+}
+
+func (c *p4rtClient) writePipelines() []*writePipeline {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.pipelines
+}
+
+// WriteOrdered is like Write, but requests with the same key are sent one
+// after another on the same pipeline, so they are applied and answered in
+// the order they were submitted. Different keys still go out in parallel.
+// Adding pipelines with SetWritePipelines remaps keys, so order is only
+// kept between requests submitted with the same number of pipelines.
+func (c *p4rtClient) WriteOrdered(key uint64, req *p4.WriteRequest) <-chan []*p4.Error {
+	pipelines := c.writePipelines()
+	return c.submitWrite(nil, "", req, pipelines[key%uint64(len(pipelines))].ordered)
+}
+
+// recordThroughput counts a request of n updates sent on pipeline at start
+// and returns the pipeline's and the client's throughput.
+func (c *p4rtClient) recordThroughput(pipeline *writePipeline, start time.Time, n int) (float64, float64) {
+	end := time.Now()
+	return pipeline.throughput.add(start, end, n), c.throughput.add(start, end, n)
+}
+
+// cancelOrdered fails the writes waiting in pipeline's ordered queue.
+func (c *p4rtClient) cancelOrdered(pipeline *writePipeline) int {
+	cancelled := 0
+	for {
+		select {
+		case write := <-pipeline.ordered:
+			c.cancelQueued(write)
+			cancelled++
+		default:
+			return cancelled
+		}
+	}
+}
+
+// stopWriteThreads stops the write threads of every pipeline and waits for
+// them to return. The queues must be empty, as they are once Shutdown has
+// seen every accepted write answered.
+func (c *p4rtClient) stopWriteThreads() {
+	close(c.writersDone)
+	c.writers.Wait()
+}
+
+// closeWritePipelines closes the connections the pipelines own.
+func (c *p4rtClient) closeWritePipelines() {
+	for _, pipeline := range c.writePipelines() {
+		if pipeline.conn != nil {
+			pipeline.conn.Close()
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// WriteRequestDelimiter separates requests in a multi-request text file.
+// It must appear alone on its own line.
+const WriteRequestDelimiter = "---"
+
+// LoadWriteRequests parses text-format WriteRequests from path. The file may
+// hold a single request, or several separated by WriteRequestDelimiter lines.
+func LoadWriteRequests(path string) ([]*p4.WriteRequest, error) {
+	fmt.Printf("Write requests: %s\n", path)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []*p4.WriteRequest
+	var chunk []string
+	lineNum, chunkStart := 0, 1
+	parseChunk := func() error {
+		text := strings.Join(chunk, "\n")
+		chunk = chunk[:0]
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		req := &p4.WriteRequest{}
+		if err := proto.UnmarshalText(text, req); err != nil {
+			return fmt.Errorf("%s: request starting at line %d: %v", path, chunkStart, err)
+		}
+		requests = append(requests, req)
+		return nil
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		lineNum++
+		if strings.TrimSpace(line) == WriteRequestDelimiter {
+			if err := parseChunk(); err != nil {
+				return nil, err
+			}
+			chunkStart = lineNum + 1
+			continue
+		}
+		chunk = append(chunk, line)
+	}
+	if err := parseChunk(); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
@@ -0,0 +1,275 @@
+// Copyright 2020-present Brian O'Connor
+// Copyright 2020-present Open Networking Foundation
+// SPDX-License-Identifier: Apache-2.0
+
+package p4rt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	p4 "github.com/p4lang/p4runtime/go/p4/v1"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeSwitch is a P4Runtime server that accepts every write, unless fail
+// says otherwise, and grants mastership to every arbitration request.
+type fakeSwitch struct {
+	p4.UnimplementedP4RuntimeServer
+
+	mu      sync.Mutex
+	writes  int
+	updates int
+	// fail, if set, returns the error for the nth write (counting from 1).
+	fail func(n int, req *p4.WriteRequest) error
+}
+
+func (s *fakeSwitch) Write(ctx context.Context, req *p4.WriteRequest) (*p4.WriteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	s.updates += len(req.GetUpdates())
+	if s.fail != nil {
+		if err := s.fail(s.writes, req); err != nil {
+			return nil, err
+		}
+	}
+	return &p4.WriteResponse{}, nil
+}
+
+func (s *fakeSwitch) setFail(fail func(n int, req *p4.WriteRequest) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+	s.writes, s.updates = 0, 0
+}
+
+func (s *fakeSwitch) StreamChannel(stream p4.P4Runtime_StreamChannelServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if arb := req.GetArbitration(); arb != nil {
+			err = stream.Send(&p4.StreamMessageResponse{Update: &p4.StreamMessageResponse_Arbitration{
+				Arbitration: &p4.MasterArbitrationUpdate{
+					DeviceId:   arb.GetDeviceId(),
+					ElectionId: arb.GetElectionId(),
+					Status:     &spb.Status{},
+				},
+			}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *fakeSwitch) counts() (writes, updates int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writes, s.updates
+}
+
+// newTestClient starts a fakeSwitch and returns a primary client of it.
+// Both are stopped when the test ends.
+func newTestClient(tb testing.TB, batchSize, numThreads int) (*p4rtClient, *fakeSwitch) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	server := grpc.NewServer()
+	fake := &fakeSwitch{}
+	p4.RegisterP4RuntimeServer(server, fake)
+	go server.Serve(lis)
+
+	client, err := CreateOrGetP4RuntimeClient(lis.Addr().String(), 1, batchSize, numThreads)
+	if err != nil {
+		server.Stop()
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+	if err = client.Arbitrate(p4.Uint128{Low: 1}, 5*time.Second); err != nil {
+		tb.Fatal(err)
+	}
+	return client.(*p4rtClient), fake
+}
+
+func insertRequest(c *p4rtClient, updates int) *p4.WriteRequest {
+	req := &p4.WriteRequest{DeviceId: c.deviceID, ElectionId: c.ElectionID()}
+	for i := 0; i < updates; i++ {
+		req.Updates = append(req.Updates, &p4.Update{
+			Type: p4.Update_INSERT,
+			Entity: &p4.Entity{Entity: &p4.Entity_TableEntry{TableEntry: &p4.TableEntry{
+				TableId: 1,
+				Match: []*p4.FieldMatch{{
+					FieldId:        1,
+					FieldMatchType: &p4.FieldMatch_Exact_{Exact: &p4.FieldMatch_Exact{Value: []byte{byte(i)}}},
+				}},
+			}}},
+		})
+	}
+	return req
+}
+
+// TestWriteConcurrent writes from many goroutines at once while reading
+// the client's statistics and draining its traces. Run it with -race.
+func TestWriteConcurrent(t *testing.T) {
+	const (
+		writers         = 100
+		writesPerWriter = 20
+		updatesPerWrite = 10
+		expectedWrites  = writers * writesPerWriter
+		expectedUpdates = expectedWrites * updatesPerWrite
+	)
+	c, fake := newTestClient(t, updatesPerWrite, 4)
+
+	traces := make(chan WriteTrace, 100)
+	c.SetWriteTraceChan(traces)
+	traced := make(chan int)
+	go func() {
+		n := 0
+		for range traces {
+			n++
+		}
+		traced <- n
+	}()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < writesPerWriter; i++ {
+				for _, p4Err := range <-c.Write(insertRequest(c, updatesPerWrite)) {
+					if p4Err.GetCanonicalCode() != 0 {
+						mu.Lock()
+						failed++
+						mu.Unlock()
+					}
+				}
+				c.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+	c.SetWriteTraceChan(nil)
+
+	if failed != 0 {
+		t.Errorf("%d updates failed", failed)
+	}
+	if writes, updates := fake.counts(); writes != expectedWrites || updates != expectedUpdates {
+		t.Errorf("switch got %d writes of %d updates, want %d of %d", writes, updates, expectedWrites, expectedUpdates)
+	}
+	stats := c.Stats()
+	if pipeline := stats.Pipelines[0]; pipeline.Requests != expectedWrites || pipeline.Updates != expectedUpdates {
+		t.Errorf("pipeline sent %d requests of %d updates, want %d of %d",
+			pipeline.Requests, pipeline.Updates, expectedWrites, expectedUpdates)
+	}
+	close(traces)
+	if n := <-traced + int(stats.TracesDropped); n != expectedWrites {
+		t.Errorf("%d traces delivered or dropped, want %d", n, expectedWrites)
+	}
+}
+
+// BenchmarkWrite measures one write of a batch, from Write to its response,
+// against the fake switch. Its allocations per write should not grow with
+// the client's batch size beyond the request itself; the response channel
+// used to be sized by it.
+func BenchmarkWrite(b *testing.B) {
+	for _, batchSize := range []int{1, 100, 1000} {
+		b.Run(fmt.Sprintf("batch=%d", batchSize), func(b *testing.B) {
+			c, _ := newTestClient(b, batchSize, 1)
+			req := insertRequest(c, batchSize)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				<-c.Write(req)
+			}
+		})
+	}
+}
+
+// writeError builds the error a switch returns for a batch whose updates
+// did not all succeed.
+func writeError(details ...*any.Any) error {
+	return status.ErrorProto(&spb.Status{Code: int32(codes.Unknown), Message: "batch failed", Details: details})
+}
+
+func p4ErrorDetail(t *testing.T, code codes.Code) *any.Any {
+	detail, err := ptypes.MarshalAny(&p4.Error{CanonicalCode: int32(code), Message: code.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return detail
+}
+
+func TestParseP4RuntimeWriteError(t *testing.T) {
+	notAnError, err := ptypes.MarshalAny(&p4.Uint128{Low: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, exists, invalid := p4ErrorDetail(t, codes.OK), p4ErrorDetail(t, codes.AlreadyExists), p4ErrorDetail(t, codes.InvalidArgument)
+
+	tests := []struct {
+		name       string
+		err        error
+		numUpdates int
+		want       []codes.Code
+	}{
+		{"success", nil, 2, []codes.Code{codes.OK, codes.OK}},
+		{"no details", status.Error(codes.Unavailable, "switch down"), 2, []codes.Code{codes.Unavailable, codes.Unavailable}},
+		{"not a gRPC status", errors.New("broken"), 1, []codes.Code{codes.Unknown}},
+		{"one detail per update", writeError(ok, exists), 2, []codes.Code{codes.OK, codes.AlreadyExists}},
+		// The updates without a detail get a stand-in error, not a guess
+		{"fewer details than updates", writeError(exists), 3, []codes.Code{codes.AlreadyExists, codes.Unknown, codes.Unknown}},
+		// Details beyond the last update are ignored
+		{"more details than updates", writeError(ok, invalid, exists), 2, []codes.Code{codes.OK, codes.InvalidArgument}},
+		{"detail that is not a p4.Error", writeError(ok, notAnError), 2, []codes.Code{codes.OK, codes.Internal}},
+		{"no updates", writeError(exists), 0, []codes.Code{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseP4RuntimeWriteError(test.err, test.numUpdates)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d errors, want %d", len(got), len(test.want))
+			}
+			for i, p4Err := range got {
+				if code := codes.Code(p4Err.GetCanonicalCode()); code != test.want[i] {
+					t.Errorf("update %d: got %v (%q), want %v", i, code, p4Err.GetMessage(), test.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestSetContextReplaces checks that SetContext cancels the root context it
+// replaces without failing the writes that follow.
+func TestSetContextReplaces(t *testing.T) {
+	c, _ := newTestClient(t, 1, 1)
+	previous := c.rootContext()
+	c.SetContext(context.Background())
+	select {
+	case <-previous.Done():
+	case <-time.After(time.Second):
+		t.Fatal("previous root context was not cancelled")
+	}
+	if p4Err := (<-c.Write(insertRequest(c, 1)))[0]; p4Err.GetCanonicalCode() != int32(codes.OK) {
+		t.Errorf("write after SetContext failed: %v", p4Err)
+	}
+}